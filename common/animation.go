@@ -0,0 +1,126 @@
+package common
+
+import (
+	"fmt"
+	"gitlocal/gome"
+	"gitlocal/gome/common/graphics"
+	"math"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	AnimationComponent
+*/
+
+// An AnimationComponent plays animations that move, rotate and scale other
+// entities, e.g. the nodes of a model.
+type AnimationComponent struct {
+	Animations []*graphics.Animation
+
+	// Targets are the space components the nodes of the animations refer to,
+	// nil for nodes that have no entity.
+	Targets []*SpaceComponent
+
+	// Speed scales the playback time. 0 pauses the animation.
+	Speed float32
+
+	// Loop restarts the animation when it reaches its end.
+	Loop bool
+
+	current *graphics.Animation
+	time    float32
+}
+
+func (*AnimationComponent) Name() string { return "Animation" }
+
+// Play starts the animation with a name from the beginning.
+func (ac *AnimationComponent) Play(name string) error {
+	for _, animation := range ac.Animations {
+		if animation.Name == name {
+			ac.current = animation
+			ac.time = 0
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no animation named %q", name)
+}
+
+// Stop stops the current animation. The targets keep their current transforms.
+func (ac *AnimationComponent) Stop() { ac.current = nil }
+
+// Playing returns the name of the current animation, or false if none is playing.
+func (ac *AnimationComponent) Playing() (string, bool) {
+	if ac.current == nil {
+		return "", false
+	}
+	return ac.current.Name, true
+}
+
+// Time returns the playback position of the current animation in seconds.
+func (ac *AnimationComponent) Time() float32 { return ac.time }
+
+// apply sets the transforms of the targets to the ones at the current time.
+func (ac *AnimationComponent) apply() {
+	for i := range ac.current.Channels {
+		channel := &ac.current.Channels[i]
+		// the node is not part of the scene
+		if channel.Node >= len(ac.Targets) || ac.Targets[channel.Node] == nil {
+			continue
+		}
+
+		target := ac.Targets[channel.Node]
+		value := channel.Sample(ac.time)
+
+		switch channel.Path {
+		case graphics.AnimateTranslation:
+			target.SetPosition(gome.FloatVector3{X: value[0], Y: value[1], Z: value[2]})
+		case graphics.AnimateRotation:
+			target.SetOrientation(mgl32.Quat{W: value[3], V: mgl32.Vec3{value[0], value[1], value[2]}})
+		case graphics.AnimateScale:
+			target.SetSize(gome.FloatVector3{X: value[0], Y: value[1], Z: value[2]})
+		}
+	}
+}
+
+/*
+	AnimationSystem
+*/
+
+// An AnimationSystem advances the animations of AnimationComponents.
+type AnimationSystem struct {
+	gome.MultiSystem
+}
+
+func (*AnimationSystem) RequiredComponents() []string { return []string{"Animation"} }
+
+func (as *AnimationSystem) Update(delta time.Duration) {
+	for _, components := range as.MultiSystem.Entities {
+		animator := components[0].(*AnimationComponent)
+		if animator.current == nil {
+			continue
+		}
+
+		duration := animator.current.Duration
+		animator.time += float32(delta.Seconds()) * animator.Speed
+
+		finished := false
+		if animator.time > duration {
+			if animator.Loop && duration > 0 {
+				animator.time = float32(math.Mod(float64(animator.time), float64(duration)))
+			} else {
+				animator.time = duration
+				finished = true
+			}
+		}
+
+		animator.apply()
+		if finished {
+			animator.Stop()
+		}
+	}
+}
+
+func (*AnimationSystem) Name() string { return "Animation" }
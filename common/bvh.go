@@ -0,0 +1,282 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	Frustum
+*/
+
+// A Frustum is the volume a camera or light sees, as six planes with their
+// normals pointing inwards.
+type Frustum [6]mgl32.Vec4
+
+// NewFrustum returns the frustum of a projection view matrix.
+func NewFrustum(matrix mgl32.Mat4) Frustum {
+	r0, r1, r2, r3 := matrix.Row(0), matrix.Row(1), matrix.Row(2), matrix.Row(3)
+	frustum := Frustum{
+		r3.Add(r0), r3.Sub(r0), // left, right
+		r3.Add(r1), r3.Sub(r1), // bottom, top
+		r3.Add(r2), r3.Sub(r2), // near, far
+	}
+
+	for i, plane := range frustum {
+		if length := plane.Vec3().Len(); length > 0 {
+			frustum[i] = plane.Mul(1 / length)
+		}
+	}
+	return frustum
+}
+
+// IntersectsAABB returns whether a box is at least partly inside the frustum.
+// Boxes just outside of its corners count as inside as well.
+func (f Frustum) IntersectsAABB(box gome.AABB) bool {
+	for _, plane := range f {
+		// the corner of the box furthest along the normal of the plane
+		x, y, z := box.Min.X, box.Min.Y, box.Min.Z
+		if plane.X() > 0 {
+			x = box.Max.X
+		}
+		if plane.Y() > 0 {
+			y = box.Max.Y
+		}
+		if plane.Z() > 0 {
+			z = box.Max.Z
+		}
+
+		if plane.X()*x+plane.Y()*y+plane.Z()*z+plane.W() < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSphere returns whether a sphere is at least partly inside the
+// frustum, with the same tolerance as IntersectsAABB.
+func (f Frustum) IntersectsSphere(sphere gome.Sphere) bool {
+	for _, plane := range f {
+		center := sphere.Center
+		if plane.X()*center.X+plane.Y()*center.Y+plane.Z()*center.Z+plane.W() < -sphere.Radius {
+			return false
+		}
+	}
+	return true
+}
+
+// transformAABB returns the bounding box of a box transformed by a matrix.
+func transformAABB(box gome.AABB, matrix mgl32.Mat4) gome.AABB {
+	center, size := box.Center(), box.Size()
+	c := matrix.Mul4x1(mgl32.Vec4{center.X, center.Y, center.Z, 1})
+
+	// the extent along each axis is the sum of the rotated and scaled half
+	// sizes projected onto it
+	extent := [3]float32{}
+	for row := 0; row < 3; row++ {
+		extent[row] = float32(math.Abs(float64(matrix.At(row, 0))))*size.X/2 +
+			float32(math.Abs(float64(matrix.At(row, 1))))*size.Y/2 +
+			float32(math.Abs(float64(matrix.At(row, 2))))*size.Z/2
+	}
+
+	return gome.AABB{
+		Min: gome.FloatVector3{X: c.X() - extent[0], Y: c.Y() - extent[1], Z: c.Z() - extent[2]},
+		Max: gome.FloatVector3{X: c.X() + extent[0], Y: c.Y() + extent[1], Z: c.Z() + extent[2]},
+	}
+}
+
+/*
+	BVH
+*/
+
+// A BVH is a bounding volume hierarchy: a binary tree of boxes, each one
+// containing the boxes below it, with the boxes of items at the leaves. It
+// finds the items in a region or along a ray without testing all of them.
+// The RenderSystem keeps one of its entities, which other systems can use as
+// well, e.g. for physics.
+type BVH struct {
+	nodes  []bvhNode
+	leaves map[uint]int
+
+	// cost is the area of the inner nodes after the last build. Moving items
+	// make the tree worse over time, so it gets rebuilt when that doubles.
+	cost float32
+}
+
+type bvhNode struct {
+	box gome.AABB
+
+	// left and right are the indices of the children, -1 for leaves, which
+	// hold the ID of an item instead
+	left, right int
+	id          uint
+}
+
+// Build builds the tree from the boxes of items by ID.
+func (b *BVH) Build(boxes map[uint]gome.AABB) {
+	items := make([]bvhNode, 0, len(boxes))
+	for id, box := range boxes {
+		items = append(items, bvhNode{box: box, left: -1, right: -1, id: id})
+	}
+
+	b.nodes = make([]bvhNode, 0, 2*len(items))
+	b.leaves = make(map[uint]int, len(items))
+	if len(items) > 0 {
+		b.build(items)
+	}
+	b.cost = b.innerArea()
+}
+
+// build adds the nodes of a subtree with items at its leaves and returns the
+// index of its root. Children always come after their parent.
+func (b *BVH) build(items []bvhNode) int {
+	index := len(b.nodes)
+	if len(items) == 1 {
+		b.nodes = append(b.nodes, items[0])
+		b.leaves[items[0].id] = index
+		return index
+	}
+
+	bounds, centers := items[0].box, gome.NewAABB(items[0].box.Center())
+	for _, item := range items[1:] {
+		bounds = bounds.Union(item.box)
+		centers = centers.Extend(item.box.Center())
+	}
+
+	// split in half along the axis the centers spread the most on
+	size := centers.Size().ToArray()
+	axis := 0
+	for i := 1; i < 3; i++ {
+		if size[i] > size[axis] {
+			axis = i
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].box.Center().ToArray()[axis] < items[j].box.Center().ToArray()[axis]
+	})
+
+	b.nodes = append(b.nodes, bvhNode{box: bounds})
+	half := len(items) / 2
+	left := b.build(items[:half])
+	right := b.build(items[half:])
+	b.nodes[index].left, b.nodes[index].right = left, right
+
+	return index
+}
+
+// innerArea returns the summed surface area of the inner nodes.
+func (b *BVH) innerArea() float32 {
+	area := float32(0)
+	for _, node := range b.nodes {
+		if node.left >= 0 {
+			area += node.box.SurfaceArea()
+		}
+	}
+	return area
+}
+
+// Len returns the number of items.
+func (b *BVH) Len() int { return len(b.leaves) }
+
+// Set changes the box of an item. It returns false if the item is not in the
+// tree. Call Refit after changing boxes.
+func (b *BVH) Set(id uint, box gome.AABB) bool {
+	leaf, ok := b.leaves[id]
+	if ok {
+		b.nodes[leaf].box = box
+	}
+	return ok
+}
+
+// Refit updates the inner boxes after items changed their boxes, rebuilding
+// the tree if it got a lot worse.
+func (b *BVH) Refit() {
+	for i := len(b.nodes) - 1; i >= 0; i-- {
+		node := &b.nodes[i]
+		if node.left >= 0 {
+			node.box = b.nodes[node.left].box.Union(b.nodes[node.right].box)
+		}
+	}
+
+	if b.innerArea() > 2*b.cost {
+		boxes := make(map[uint]gome.AABB, len(b.leaves))
+		for id, leaf := range b.leaves {
+			boxes[id] = b.nodes[leaf].box
+		}
+		b.Build(boxes)
+	}
+}
+
+// QueryAABB calls fn with the items whose box intersects a box, until it
+// returns false.
+func (b *BVH) QueryAABB(box gome.AABB, fn func(id uint) bool) {
+	b.query(box.Intersects, fn)
+}
+
+// QueryFrustum calls fn with the items whose box is at least partly inside
+// a frustum, until it returns false.
+func (b *BVH) QueryFrustum(frustum Frustum, fn func(id uint) bool) {
+	b.query(frustum.IntersectsAABB, fn)
+}
+
+// query calls fn with the items in the subtrees whose boxes pass a test.
+func (b *BVH) query(test func(box gome.AABB) bool, fn func(id uint) bool) {
+	if len(b.nodes) == 0 {
+		return
+	}
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if !test(node.box) {
+			continue
+		}
+
+		if node.left < 0 {
+			if !fn(node.id) {
+				return
+			}
+			continue
+		}
+		stack = append(stack, node.left, node.right)
+	}
+}
+
+// Raycast returns the closest item a ray hits before maxDistance, which is
+// unlimited if 0. hit is called for the items whose box the ray hits, with
+// the distance of the closest hit so far, and returns where the ray hits
+// the item itself, e.g. its triangles.
+func (b *BVH) Raycast(ray gome.Ray, maxDistance float32, hit func(id uint, maxDistance float32) (distance float32, ok bool)) (id uint, distance float32, ok bool) {
+	distance = maxDistance
+	if distance <= 0 {
+		distance = float32(math.Inf(1))
+	}
+	if len(b.nodes) == 0 {
+		return 0, distance, false
+	}
+
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+
+		// skip subtrees behind the closest hit
+		if t, hits := ray.IntersectAABB(node.box); !hits || t > distance {
+			continue
+		}
+
+		if node.left >= 0 {
+			stack = append(stack, node.left, node.right)
+			continue
+		}
+		if t, hits := hit(node.id, distance); hits && t <= distance {
+			id, distance, ok = node.id, t, true
+		}
+	}
+
+	return id, distance, ok
+}
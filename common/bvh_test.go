@@ -0,0 +1,193 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// randomBoxes returns n small boxes scattered in a cube of size 100 around
+// the origin.
+func randomBoxes(n int, random *rand.Rand) map[uint]gome.AABB {
+	boxes := make(map[uint]gome.AABB, n)
+	for id := uint(0); id < uint(n); id++ {
+		low := gome.FloatVector3{X: random.Float32()*100 - 50, Y: random.Float32()*100 - 50, Z: random.Float32()*100 - 50}
+		size := random.Float32()*4 + 0.1
+		boxes[id] = gome.AABB{Min: low, Max: gome.FloatVector3{X: low.X + size, Y: low.Y + size, Z: low.Z + size}}
+	}
+	return boxes
+}
+
+// collect returns the sorted IDs a query finds.
+func collect(query func(fn func(id uint) bool)) []uint {
+	ids := []uint{}
+	query(func(id uint) bool {
+		ids = append(ids, id)
+		return true
+	})
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// filter returns the sorted IDs of the boxes passing a test.
+func filter(boxes map[uint]gome.AABB, test func(box gome.AABB) bool) []uint {
+	ids := []uint{}
+	for id, box := range boxes {
+		if test(box) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBVHQuery(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	boxes := randomBoxes(500, random)
+
+	bvh := BVH{}
+	bvh.Build(boxes)
+	if bvh.Len() != len(boxes) {
+		t.Fatalf("got %d items, want %d", bvh.Len(), len(boxes))
+	}
+
+	regions := []struct {
+		name string
+		box  gome.AABB
+	}{
+		{"everything", gome.AABB{Min: gome.FloatVector3{X: -100, Y: -100, Z: -100}, Max: gome.FloatVector3{X: 100, Y: 100, Z: 100}}},
+		{"nothing", gome.AABB{Min: gome.FloatVector3{X: 200, Y: 200, Z: 200}, Max: gome.FloatVector3{X: 300, Y: 300, Z: 300}}},
+		{"corner", gome.AABB{Min: gome.FloatVector3{X: -50, Y: -50, Z: -50}, Max: gome.FloatVector3{X: -20, Y: -20, Z: -20}}},
+		{"slab", gome.AABB{Min: gome.FloatVector3{X: -100, Y: -1, Z: -100}, Max: gome.FloatVector3{X: 100, Y: 1, Z: 100}}},
+		{"point", gome.AABB{}},
+	}
+	for _, region := range regions {
+		got := collect(func(fn func(id uint) bool) { bvh.QueryAABB(region.box, fn) })
+		if want := filter(boxes, region.box.Intersects); !equalIDs(got, want) {
+			t.Errorf("%s: got %d items, want %d", region.name, len(got), len(want))
+		}
+	}
+
+	frustums := []struct {
+		name   string
+		matrix mgl32.Mat4
+	}{
+		{"perspective", mgl32.Perspective(mgl32.DegToRad(60), 1.5, 0.1, 60).Mul4(mgl32.LookAtV(mgl32.Vec3{0, 0, 40}, mgl32.Vec3{}, mgl32.Vec3{0, 1, 0}))},
+		{"narrow", mgl32.Perspective(mgl32.DegToRad(10), 1, 1, 200).Mul4(mgl32.LookAtV(mgl32.Vec3{60, 60, 60}, mgl32.Vec3{}, mgl32.Vec3{0, 1, 0}))},
+		{"orthographic", mgl32.Ortho(-10, 10, -5, 5, 0, 100).Mul4(mgl32.LookAtV(mgl32.Vec3{0, 60, 0}, mgl32.Vec3{}, mgl32.Vec3{0, 0, -1}))},
+		{"looking away", mgl32.Perspective(mgl32.DegToRad(60), 1, 0.1, 10).Mul4(mgl32.LookAtV(mgl32.Vec3{0, 0, 80}, mgl32.Vec3{0, 0, 90}, mgl32.Vec3{0, 1, 0}))},
+	}
+	for _, test := range frustums {
+		frustum := NewFrustum(test.matrix)
+		got := collect(func(fn func(id uint) bool) { bvh.QueryFrustum(frustum, fn) })
+		if want := filter(boxes, frustum.IntersectsAABB); !equalIDs(got, want) {
+			t.Errorf("%s: got %d items, want %d", test.name, len(got), len(want))
+		}
+	}
+
+	// queries stop when fn returns false
+	calls := 0
+	bvh.QueryAABB(regions[0].box, func(id uint) bool {
+		calls++
+		return calls < 3
+	})
+	if calls != 3 {
+		t.Errorf("query went on after fn returned false, %d calls", calls)
+	}
+}
+
+func TestBVHRefit(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	boxes := randomBoxes(200, random)
+
+	bvh := BVH{}
+	bvh.Build(boxes)
+
+	// move every item a little, then some items far away, which rebuilds it
+	for _, spread := range []float32{1, 200} {
+		for id := range boxes {
+			if spread > 1 && id%10 != 0 {
+				continue
+			}
+			offset := gome.FloatVector3{X: (random.Float32() - 0.5) * spread, Y: (random.Float32() - 0.5) * spread}
+			box := boxes[id]
+			box.Min = gome.FloatVector3{X: box.Min.X + offset.X, Y: box.Min.Y + offset.Y, Z: box.Min.Z}
+			box.Max = gome.FloatVector3{X: box.Max.X + offset.X, Y: box.Max.Y + offset.Y, Z: box.Max.Z}
+			boxes[id] = box
+
+			if !bvh.Set(id, box) {
+				t.Fatalf("item %d not found", id)
+			}
+		}
+		bvh.Refit()
+
+		region := gome.AABB{Min: gome.FloatVector3{X: -20, Y: -20, Z: -20}, Max: gome.FloatVector3{X: 20, Y: 20, Z: 20}}
+		got := collect(func(fn func(id uint) bool) { bvh.QueryAABB(region, fn) })
+		if want := filter(boxes, region.Intersects); !equalIDs(got, want) {
+			t.Errorf("spread %v: got %d items, want %d", spread, len(got), len(want))
+		}
+	}
+
+	if bvh.Set(1000, gome.AABB{}) {
+		t.Error("set an item that is not in the tree")
+	}
+}
+
+func TestBVHRaycast(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	boxes := randomBoxes(300, random)
+
+	bvh := BVH{}
+	bvh.Build(boxes)
+
+	// the items are their boxes
+	hitBox := func(ray gome.Ray) func(id uint, max float32) (float32, bool) {
+		return func(id uint, max float32) (float32, bool) { return ray.IntersectAABB(boxes[id]) }
+	}
+
+	for i := 0; i < 200; i++ {
+		origin := gome.FloatVector3{X: random.Float32()*140 - 70, Y: random.Float32()*140 - 70, Z: random.Float32()*140 - 70}
+		direction := gome.FloatVector3{X: -origin.X + random.Float32()*20, Y: -origin.Y + random.Float32()*20, Z: -origin.Z}
+		ray := gome.Ray{Origin: origin, Direction: direction}
+		maxDistance := float32(0)
+		if i%2 == 1 {
+			maxDistance = 0.5
+		}
+
+		// brute force
+		wantOK, wantDistance := false, float32(0)
+		for _, box := range boxes {
+			if at, ok := ray.IntersectAABB(box); ok && (maxDistance == 0 || at <= maxDistance) && (!wantOK || at < wantDistance) {
+				wantOK, wantDistance = true, at
+			}
+		}
+
+		id, distance, ok := bvh.Raycast(ray, maxDistance, hitBox(ray))
+		if ok != wantOK || (ok && distance != wantDistance) {
+			t.Fatalf("ray %d: got %v at %v, want %v at %v", i, ok, distance, wantOK, wantDistance)
+		}
+		if at, _ := ray.IntersectAABB(boxes[id]); ok && at != distance {
+			t.Errorf("ray %d: item %d is at %v, not %v", i, id, at, distance)
+		}
+	}
+
+	empty := BVH{}
+	if _, _, ok := empty.Raycast(gome.Ray{Direction: gome.FloatVector3{Z: 1}}, 0, hitBox(gome.Ray{})); ok {
+		t.Error("hit in an empty tree")
+	}
+}
@@ -2,6 +2,8 @@ package common
 
 import (
 	"gitlocal/gome"
+	"gitlocal/gome/common/graphics"
+	"sort"
 	"time"
 
 	"github.com/go-gl/mathgl/mgl32"
@@ -11,12 +13,93 @@ import (
 	CameraComponent
 */
 
+// A ClearMode decides what a camera clears before drawing.
+type ClearMode int
+
+const (
+	CLEAR_ALL   ClearMode = iota // color and depth
+	CLEAR_DEPTH                  // only depth, to draw over other cameras
+	CLEAR_NONE
+)
+
+// A Projection is how a camera projects the world onto the screen.
+type Projection int
+
+const (
+	PERSPECTIVE Projection = iota
+	ORTHOGRAPHIC
+)
+
+// A Viewport is a rectangle of the screen, in fractions of its size from the
+// bottom left corner. The zero Viewport is the whole screen.
+type Viewport struct {
+	X, Y, Width, Height float32
+}
+
+// pixels returns the viewport as x, y, width and height in pixels of a
+// rectangle of the screen.
+func (v Viewport) pixels(screen [4]int32) [4]int32 {
+	if v == (Viewport{}) {
+		return screen
+	}
+
+	width, height := float32(screen[2]), float32(screen[3])
+	return [4]int32{
+		screen[0] + int32(v.X*width),
+		screen[1] + int32(v.Y*height),
+		int32(v.Width * width),
+		int32(v.Height * height),
+	}
+}
+
 type CameraComponent struct {
-	projectionMatrix mgl32.Mat4
+	Projection Projection
+
+	// FOV is the vertical field of view of perspective cameras in radians,
+	// Size half the height of the view of orthographic ones in world units.
+	// AspectRatio is the width of the view divided by its height, or 0 to
+	// match the viewport. Near and Far are the distances of the clipping
+	// planes.
+	FOV, Size, AspectRatio, Near, Far float32
+
+	// Oriented makes the camera look along the -Z axis of its entity, like
+	// lights. Otherwise it looks at the point stored as the rotation of its
+	// SpaceComponent. Camera controllers set it.
+	Oriented bool
+
+	// Viewport is the part of the screen the camera draws into, or of Target
+	// if it's set.
+	Viewport Viewport
+
+	// Cameras with a higher Priority are drawn later, over the ones with a
+	// lower one, e.g. for picture-in-picture.
+	Priority int
+
+	// LayerMask are the render layers the camera shows, all if 0.
+	LayerMask RenderLayer
+
+	// Clear is what gets cleared in the viewport before drawing, ClearColor
+	// is the color it gets cleared with.
+	Clear      ClearMode
+	ClearColor gome.FloatVector3
+
+	// Target makes the camera draw into a render target instead of the screen,
+	// e.g. for mirrors or security cameras. Cameras with a target are drawn
+	// before the others, so they can show its textures.
+	Target *graphics.RenderTarget
+
+	// PostEffects process the image of the camera in order, e.g.
+	// graphics.Bloom followed by graphics.ToneMapping and graphics.FXAA.
+	PostEffects []*graphics.PostEffect
 }
 
 func (*CameraComponent) Name() string { return "Camera" }
 
+// shows returns whether the camera shows a render layer.
+func (cc *CameraComponent) shows(layer RenderLayer) bool {
+	return layer.matches(cc.LayerMask)
+}
+
 /*
 	CameraEntity
 */
@@ -37,7 +120,7 @@ func (ce *CameraEntity) New() error {
 
 	ce.Lens(
 		mgl32.DegToRad(100),
-		16/9,
+		0,
 		0.1,
 		100,
 	)
@@ -48,41 +131,228 @@ func (ce *CameraEntity) New() error {
 // Lens sets the perspective settings for the CameraSystem.
 //
 // fov:   The vertical Field of View, in radians: the amount of "zoom". Think "camera lens".
-// ratio: Aspect Ratio. Depends on the size of your window.
+// ratio: Aspect Ratio. Depends on the size of your window, 0 matches the viewport.
 // ncp:   Near clipping plane. Keep as big as possible, or you'll get precision issues.
 // fcp:   Far clipping plane. Keep as little as possible.
 func (ce *CameraEntity) Lens(fov, ratio, ncp, fcp float32) {
-	ce.BaseEntity.Components["Camera"] = &CameraComponent{
-		projectionMatrix: mgl32.Perspective(fov, ratio, ncp, fcp),
+	camera, ok := ce.BaseEntity.Components["Camera"].(*CameraComponent)
+	if !ok {
+		camera = &CameraComponent{}
+		ce.BaseEntity.Components["Camera"] = camera
 	}
+
+	camera.Projection = PERSPECTIVE
+	camera.FOV, camera.AspectRatio, camera.Near, camera.Far = fov, ratio, ncp, fcp
+}
+
+// Ortho makes the camera orthographic, e.g. for 2D games or top-down maps.
+//
+// size:  Half the height of the view in world units.
+// ratio: Aspect Ratio, 0 matches the viewport.
+// ncp:   Near clipping plane.
+// fcp:   Far clipping plane.
+func (ce *CameraEntity) Ortho(size, ratio, ncp, fcp float32) {
+	ce.Lens(0, ratio, ncp, fcp)
+
+	camera := ce.BaseEntity.Components["Camera"].(*CameraComponent)
+	camera.Projection = ORTHOGRAPHIC
+	camera.Size = size
 }
 
 /*
 	CameraSystem
 */
 
-// A CameraSystem defines how the world is viewed.
+// A CameraSystem defines how the world is viewed. Every camera draws the
+// world into its own viewport.
 type CameraSystem struct {
-	gome.SingleSystem
-}
-
-// viewProjectionMatrix returns the current View Projection Matrix
-func (cs *CameraSystem) projectionViewMatrix() mgl32.Mat4 {
-	if cs.SingleSystem.Active {
-		spaceComponent := cs.SingleSystem.Components[1].(*SpaceComponent)
-		position := spaceComponent.GetPosition()
-		rotation := spaceComponent.GetRotation()
-
-		projectionMatrix := cs.SingleSystem.Components[0].(*CameraComponent).projectionMatrix
-		viewMatrix := mgl32.LookAt(
-			position.X, position.Y, position.Z,
-			rotation.X, rotation.Y, rotation.Z,
-			0, 1, 0,
-		)
-		return projectionMatrix.Mul4(viewMatrix)
+	gome.MultiSystem
+
+	// screen is the rectangle the cameras without a target were last drawn
+	// into, in pixels.
+	screen [4]int32
+}
+
+func (cs *CameraSystem) Init(scene *gome.Scene) error {
+	cs.screen = [4]int32{0, 0, scene.WindowArgs.Width, scene.WindowArgs.Height}
+	return cs.MultiSystem.Init(scene)
+}
+
+// A camera is a camera entity of the system.
+type camera struct {
+	id uint
+	*CameraComponent
+	space *SpaceComponent
+}
+
+// cameras returns the cameras in the order they are drawn: the ones with a
+// target first, then by priority.
+func (cs *CameraSystem) cameras() []camera {
+	cameras := make([]camera, 0, len(cs.MultiSystem.Entities))
+	for id, components := range cs.MultiSystem.Entities {
+		cameras = append(cameras, camera{
+			id:              id,
+			CameraComponent: components[0].(*CameraComponent),
+			space:           components[1].(*SpaceComponent),
+		})
 	}
 
-	return mgl32.Ident4()
+	sort.Slice(cameras, func(i, j int) bool {
+		a, b := cameras[i], cameras[j]
+		if (a.Target != nil) != (b.Target != nil) {
+			return a.Target != nil
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		return a.id < b.id
+	})
+
+	return cameras
+}
+
+// camera returns the camera of an entity.
+func (cs *CameraSystem) camera(id uint) (camera, bool) {
+	components, ok := cs.MultiSystem.Entities[id]
+	if !ok {
+		return camera{}, false
+	}
+
+	return camera{
+		id:              id,
+		CameraComponent: components[0].(*CameraComponent),
+		space:           components[1].(*SpaceComponent),
+	}, true
+}
+
+// area returns the rectangle the viewport of a camera is a part of, in pixels.
+func (cs *CameraSystem) area(c camera) [4]int32 {
+	if c.Target != nil {
+		return [4]int32{0, 0, int32(c.Target.Width), int32(c.Target.Height)}
+	}
+	return cs.screen
+}
+
+// toViewport converts a screen position in the coordinates of mouse messages
+// to one from -1 to 1 across the viewport of a camera.
+func (cs *CameraSystem) toViewport(c camera, x, y float32) (float32, float32) {
+	area := cs.area(c)
+	viewport := c.Viewport.pixels(area)
+
+	px := float32(area[0]) + (x+1)/2*float32(area[2])
+	py := float32(area[1]) + (y+1)/2*float32(area[3])
+	return (px-float32(viewport[0]))/float32(viewport[2])*2 - 1,
+		(py-float32(viewport[1]))/float32(viewport[3])*2 - 1
+}
+
+// CameraAt returns the camera drawn on top at a position of the window, in
+// the coordinates of mouse messages. Cameras with a target are not on the
+// window.
+func (cs *CameraSystem) CameraAt(x, y float32) (id uint, ok bool) {
+	px := float32(cs.screen[0]) + (x+1)/2*float32(cs.screen[2])
+	py := float32(cs.screen[1]) + (y+1)/2*float32(cs.screen[3])
+
+	cameras := cs.cameras()
+	for i := len(cameras) - 1; i >= 0; i-- {
+		if cameras[i].Target != nil {
+			continue
+		}
+
+		viewport := cameras[i].Viewport.pixels(cs.screen)
+		if px >= float32(viewport[0]) && px < float32(viewport[0]+viewport[2]) &&
+			py >= float32(viewport[1]) && py < float32(viewport[1]+viewport[3]) {
+			return cameras[i].id, true
+		}
+	}
+
+	return 0, false
+}
+
+// ScreenToRay returns the ray from a camera through a position of the window,
+// in the coordinates of mouse messages, e.g. to find what the cursor points
+// at. The position is relative to the target for cameras with one. The
+// direction of the ray has a length of 1.
+func (cs *CameraSystem) ScreenToRay(id uint, x, y float32) (ray gome.Ray, ok bool) {
+	c, ok := cs.camera(id)
+	if !ok {
+		return gome.Ray{}, false
+	}
+
+	inverse := c.projectionViewMatrix(c.Viewport.pixels(cs.area(c))).Inv()
+	vx, vy := cs.toViewport(c, x, y)
+	near := mgl32.TransformCoordinate(mgl32.Vec3{vx, vy, -1}, inverse)
+	far := mgl32.TransformCoordinate(mgl32.Vec3{vx, vy, 1}, inverse)
+	direction := far.Sub(near).Normalize()
+
+	return gome.Ray{
+		Origin:    gome.FloatVector3{X: near.X(), Y: near.Y(), Z: near.Z()},
+		Direction: gome.FloatVector3{X: direction.X(), Y: direction.Y(), Z: direction.Z()},
+	}, true
+}
+
+// WorldToScreen returns where a point of the world appears on the window, in
+// the coordinates of mouse messages, and whether it is in front of the
+// camera. The position is relative to the target for cameras with one.
+func (cs *CameraSystem) WorldToScreen(id uint, point gome.FloatVector3) (screen gome.FloatVector2, ok bool) {
+	c, ok := cs.camera(id)
+	if !ok {
+		return gome.FloatVector2{}, false
+	}
+
+	area := cs.area(c)
+	viewport := c.Viewport.pixels(area)
+	clip := c.projectionViewMatrix(viewport).Mul4x1(mgl32.Vec4{point.X, point.Y, point.Z, 1})
+	if clip.W() <= 0 {
+		return gome.FloatVector2{}, false
+	}
+
+	px := float32(viewport[0]) + (clip.X()/clip.W()+1)/2*float32(viewport[2])
+	py := float32(viewport[1]) + (clip.Y()/clip.W()+1)/2*float32(viewport[3])
+	return gome.FloatVector2{
+		X: (px-float32(area[0]))/float32(area[2])*2 - 1,
+		Y: (py-float32(area[1]))/float32(area[3])*2 - 1,
+	}, true
+}
+
+// projectionViewMatrix returns the projection view matrix of the camera for
+// a viewport of a size in pixels.
+func (c camera) projectionViewMatrix(viewport [4]int32) mgl32.Mat4 {
+	return c.projectionMatrix(viewport).Mul4(c.viewMatrix())
+}
+
+// projectionMatrix returns the projection matrix of the camera for a viewport
+// of a size in pixels.
+func (c camera) projectionMatrix(viewport [4]int32) mgl32.Mat4 {
+	ratio := c.AspectRatio
+	if ratio == 0 && viewport[3] > 0 {
+		ratio = float32(viewport[2]) / float32(viewport[3])
+	}
+
+	if c.Projection == ORTHOGRAPHIC {
+		return mgl32.Ortho(-c.Size*ratio, c.Size*ratio, -c.Size, c.Size, c.Near, c.Far)
+	}
+	return mgl32.Perspective(c.FOV, ratio, c.Near, c.Far)
+}
+
+// viewMatrix returns the view matrix of the camera.
+func (c camera) viewMatrix() mgl32.Mat4 {
+	if c.Oriented {
+		return c.space.worldMatrix().Inv()
+	}
+
+	position := c.space.GetPosition()
+	rotation := c.space.GetRotation()
+	return mgl32.LookAt(
+		position.X, position.Y, position.Z,
+		rotation.X, rotation.Y, rotation.Z,
+		0, 1, 0,
+	)
+}
+
+// position returns the position of the camera in the world.
+func (c camera) position() gome.FloatVector3 {
+	position := c.space.worldMatrix().Col(3)
+	return gome.FloatVector3{X: position.X(), Y: position.Y(), Z: position.Z()}
 }
 
 func (*CameraSystem) Name() string { return "Camera" }
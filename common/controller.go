@@ -0,0 +1,378 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+/*
+	Input
+*/
+
+// input collects the mouse and keyboard messages for a controller. The
+// messages arrive on the goroutine handling the window events while the
+// scene updates, so everything is guarded by a mutex and systems only read
+// the copy returned by frame.
+type input struct {
+	mutex sync.Mutex
+	state inputState
+}
+
+// inputState is the input of a frame.
+type inputState struct {
+	keys    map[sdl.Keycode]bool
+	buttons map[uint8]bool
+
+	// how far the mouse and its wheel moved since the last frame
+	motion gome.FloatVector2
+	scroll float32
+}
+
+// listen starts collecting the input messages. Listeners are forgotten when
+// the scene changes, so it has to be called whenever the scene gets shown.
+func (in *input) listen() {
+	in.reset()
+	for _, name := range []string{"Keyboard", "MouseButton", "MouseMotion", "MouseScroll"} {
+		gome.MailBox.Listen(name, in.handle)
+	}
+}
+
+// reset forgets all keys and buttons held and the movement so far.
+func (in *input) reset() {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+	in.state = inputState{keys: make(map[sdl.Keycode]bool), buttons: make(map[uint8]bool)}
+}
+
+// handle records an input message.
+func (in *input) handle(msg gome.Message) {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	switch msg := msg.(type) {
+	case gome.KeyboardMessage:
+		in.state.keys[msg.Key.Sym] = msg.State == sdl.PRESSED
+	case gome.MouseButtonMessage:
+		in.state.buttons[msg.Button] = msg.State == sdl.PRESSED
+	case gome.MouseMotionMessage:
+		in.state.motion.X += msg.XRel
+		in.state.motion.Y += msg.YRel
+	case gome.MouseScrollMessage:
+		in.state.scroll += msg.Y
+	}
+}
+
+// frame returns a copy of the input, with the mouse and wheel movement since
+// the last call.
+func (in *input) frame() inputState {
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	state := inputState{
+		keys:    make(map[sdl.Keycode]bool, len(in.state.keys)),
+		buttons: make(map[uint8]bool, len(in.state.buttons)),
+		motion:  in.state.motion,
+		scroll:  in.state.scroll,
+	}
+	for key, held := range in.state.keys {
+		state.keys[key] = held
+	}
+	for button, held := range in.state.buttons {
+		state.buttons[button] = held
+	}
+
+	in.state.motion, in.state.scroll = gome.FloatVector2{}, 0
+	return state
+}
+
+// axis returns 1 if the positive key is held, -1 if the negative one is and 0
+// if both or none are.
+func (state inputState) axis(positive, negative sdl.Keycode) float32 {
+	value := float32(0)
+	if state.keys[positive] {
+		value++
+	}
+	if state.keys[negative] {
+		value--
+	}
+	return value
+}
+
+// lookRotation returns the orientation of something at eye looking at center,
+// with the Y axis up.
+func lookRotation(eye, center mgl32.Vec3) mgl32.Quat {
+	if eye.ApproxEqual(center) {
+		return mgl32.QuatIdent()
+	}
+
+	// the view matrix rotates the world the other way around
+	view := mgl32.LookAtV(eye, center, mgl32.Vec3{0, 1, 0})
+	return mgl32.Mat4ToQuat(view).Inverse()
+}
+
+// yawPitch returns the orientation turned by yaw around the Y axis and then
+// by pitch around the X axis.
+func yawPitch(yaw, pitch float32) mgl32.Quat {
+	return mgl32.QuatRotate(yaw, mgl32.Vec3{0, 1, 0}).Mul(mgl32.QuatRotate(pitch, mgl32.Vec3{1, 0, 0}))
+}
+
+// clampPitch keeps a pitch just short of straight up or down, where yaw
+// stops making sense.
+func clampPitch(pitch float32) float32 {
+	limit := float32(math.Pi/2 - 0.01)
+	return float32(math.Max(-float64(limit), math.Min(float64(limit), float64(pitch))))
+}
+
+/*
+	OrbitController
+*/
+
+// An OrbitControllerComponent makes its camera circle around a point while a
+// mouse button is dragged, and zoom with the mouse wheel. It's the usual
+// camera of model viewers and level editors.
+type OrbitControllerComponent struct {
+	// Target is the point the camera circles around.
+	Target gome.FloatVector3
+
+	// Distance is the distance from the target, kept between MinDistance and
+	// MaxDistance if they aren't 0.
+	Distance, MinDistance, MaxDistance float32
+
+	// Yaw and Pitch are the angles around the target in radians. At 0 the
+	// camera is on the +Z side of the target.
+	Yaw, Pitch float32
+
+	// Sensitivity is how many radians dragging over the whole window turns the
+	// camera, π if 0.
+	Sensitivity float32
+
+	// Button is the mouse button to drag with, sdl.BUTTON_LEFT if 0.
+	Button uint8
+}
+
+func (*OrbitControllerComponent) Name() string { return "OrbitController" }
+
+// An OrbitControllerSystem moves the cameras with an OrbitControllerComponent.
+type OrbitControllerSystem struct {
+	gome.MultiSystem
+	input input
+}
+
+func (ocs *OrbitControllerSystem) Add(id uint, components []gome.Component) error {
+	components[1].(*CameraComponent).Oriented = true
+	return ocs.MultiSystem.Add(id, components)
+}
+
+func (ocs *OrbitControllerSystem) Focus(scene *gome.Scene) { ocs.input.listen() }
+
+func (ocs *OrbitControllerSystem) Update(delta time.Duration) {
+	state := ocs.input.frame()
+	motion, scroll := state.motion, state.scroll
+
+	for _, components := range ocs.MultiSystem.Entities {
+		controller := components[0].(*OrbitControllerComponent)
+		spaceComponent := components[2].(*SpaceComponent)
+
+		button, sensitivity := controller.Button, controller.Sensitivity
+		if button == 0 {
+			button = sdl.BUTTON_LEFT
+		}
+		if sensitivity == 0 {
+			sensitivity = math.Pi
+		}
+
+		// the motion is in window sizes from -1 to 1
+		if state.buttons[button] {
+			controller.Yaw -= motion.X / 2 * sensitivity
+			controller.Pitch = clampPitch(controller.Pitch + motion.Y/2*sensitivity)
+		}
+
+		// every step of the wheel zooms by 10%
+		controller.Distance *= float32(math.Pow(0.9, float64(scroll)))
+		if controller.MinDistance > 0 && controller.Distance < controller.MinDistance {
+			controller.Distance = controller.MinDistance
+		}
+		if controller.MaxDistance > 0 && controller.Distance > controller.MaxDistance {
+			controller.Distance = controller.MaxDistance
+		}
+
+		// the camera looks along its -Z axis, so it sits on the +Z one
+		orientation := yawPitch(controller.Yaw, -controller.Pitch)
+		target := mgl32.Vec3{controller.Target.X, controller.Target.Y, controller.Target.Z}
+		position := target.Add(orientation.Rotate(mgl32.Vec3{0, 0, controller.Distance}))
+
+		spaceComponent.SetPosition(gome.FloatVector3{X: position.X(), Y: position.Y(), Z: position.Z()})
+		spaceComponent.SetOrientation(orientation)
+	}
+}
+
+func (*OrbitControllerSystem) Name() string { return "OrbitController" }
+
+func (*OrbitControllerSystem) RequiredComponents() []string {
+	return []string{"OrbitController", "Camera", "Space"}
+}
+
+/*
+	FlyController
+*/
+
+// A FlyControllerComponent makes its camera fly around freely: W, A, S and D
+// move it, E and Q up and down, and the mouse looks around while a button is
+// held. Shift makes it faster.
+type FlyControllerComponent struct {
+	// Speed is how far the camera flies per second, 5 if 0.
+	Speed float32
+
+	// Yaw and Pitch are the angles the camera looks in, in radians. At 0 it
+	// looks along -Z.
+	Yaw, Pitch float32
+
+	// Sensitivity is how many radians moving the mouse over the whole window
+	// turns the camera, π if 0.
+	Sensitivity float32
+
+	// Button is the mouse button to hold to look around, sdl.BUTTON_RIGHT if 0.
+	Button uint8
+}
+
+func (*FlyControllerComponent) Name() string { return "FlyController" }
+
+// A FlyControllerSystem moves the cameras with a FlyControllerComponent.
+type FlyControllerSystem struct {
+	gome.MultiSystem
+	input input
+}
+
+func (fcs *FlyControllerSystem) Add(id uint, components []gome.Component) error {
+	components[1].(*CameraComponent).Oriented = true
+	return fcs.MultiSystem.Add(id, components)
+}
+
+func (fcs *FlyControllerSystem) Focus(scene *gome.Scene) { fcs.input.listen() }
+
+func (fcs *FlyControllerSystem) Update(delta time.Duration) {
+	state := fcs.input.frame()
+	motion := state.motion
+
+	for _, components := range fcs.MultiSystem.Entities {
+		controller := components[0].(*FlyControllerComponent)
+		spaceComponent := components[2].(*SpaceComponent)
+
+		speed, sensitivity, button := controller.Speed, controller.Sensitivity, controller.Button
+		if speed == 0 {
+			speed = 5
+		}
+		if state.keys[sdl.K_LSHIFT] {
+			speed *= 3
+		}
+		if sensitivity == 0 {
+			sensitivity = math.Pi
+		}
+		if button == 0 {
+			button = sdl.BUTTON_RIGHT
+		}
+
+		if state.buttons[button] {
+			controller.Yaw -= motion.X / 2 * sensitivity
+			controller.Pitch = clampPitch(controller.Pitch + motion.Y/2*sensitivity)
+		}
+		orientation := yawPitch(controller.Yaw, controller.Pitch)
+
+		direction := mgl32.Vec3{
+			state.axis(sdl.K_d, sdl.K_a),
+			state.axis(sdl.K_e, sdl.K_q),
+			-state.axis(sdl.K_w, sdl.K_s),
+		}
+		position := spaceComponent.GetPosition()
+		if direction.Len() > 0 {
+			step := orientation.Rotate(direction.Normalize()).Mul(speed * float32(delta.Seconds()))
+			position.X += step.X()
+			position.Y += step.Y()
+			position.Z += step.Z()
+		}
+
+		spaceComponent.SetPosition(position)
+		spaceComponent.SetOrientation(orientation)
+	}
+}
+
+func (*FlyControllerSystem) Name() string { return "FlyController" }
+
+func (*FlyControllerSystem) RequiredComponents() []string {
+	return []string{"FlyController", "Camera", "Space"}
+}
+
+/*
+	FollowController
+*/
+
+// A FollowControllerComponent makes its camera follow an entity, e.g. the
+// player in a third-person game.
+type FollowControllerComponent struct {
+	// Target is the space of the entity to follow.
+	Target *SpaceComponent
+
+	// Offset is where the camera is relative to the target, rotated with it,
+	// e.g. {0, 2, 5} for behind and above an entity facing -Z.
+	Offset gome.FloatVector3
+
+	// LookOffset is the point relative to the target the camera looks at,
+	// e.g. the head of a character.
+	LookOffset gome.FloatVector3
+
+	// Smoothing is about how many seconds the camera takes to catch up with
+	// the target. It follows rigidly if 0.
+	Smoothing float32
+}
+
+func (*FollowControllerComponent) Name() string { return "FollowController" }
+
+// A FollowControllerSystem moves the cameras with a FollowControllerComponent.
+type FollowControllerSystem struct {
+	gome.MultiSystem
+}
+
+func (fcs *FollowControllerSystem) Add(id uint, components []gome.Component) error {
+	components[1].(*CameraComponent).Oriented = true
+	return fcs.MultiSystem.Add(id, components)
+}
+
+func (fcs *FollowControllerSystem) Update(delta time.Duration) {
+	for _, components := range fcs.MultiSystem.Entities {
+		controller := components[0].(*FollowControllerComponent)
+		spaceComponent := components[2].(*SpaceComponent)
+		if controller.Target == nil {
+			continue
+		}
+
+		target := controller.Target.worldMatrix()
+		offset, look := controller.Offset, controller.LookOffset
+		goal := target.Mul4x1(mgl32.Vec4{offset.X, offset.Y, offset.Z, 1}).Vec3()
+		center := target.Mul4x1(mgl32.Vec4{look.X, look.Y, look.Z, 1}).Vec3()
+
+		// close the same fraction of the distance every second, no matter
+		// the frame rate
+		current := spaceComponent.GetPosition()
+		position := mgl32.Vec3{current.X, current.Y, current.Z}
+		if controller.Smoothing > 0 {
+			t := 1 - float32(math.Exp(-delta.Seconds()/float64(controller.Smoothing)))
+			position = position.Add(goal.Sub(position).Mul(t))
+		} else {
+			position = goal
+		}
+
+		spaceComponent.SetPosition(gome.FloatVector3{X: position.X(), Y: position.Y(), Z: position.Z()})
+		spaceComponent.SetOrientation(lookRotation(position, center))
+	}
+}
+
+func (*FollowControllerSystem) Name() string { return "FollowController" }
+
+func (*FollowControllerSystem) RequiredComponents() []string {
+	return []string{"FollowController", "Camera", "Space"}
+}
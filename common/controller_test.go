@@ -0,0 +1,80 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"sync"
+	"testing"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// TestInputConcurrent sends input messages from another goroutine while
+// frames are read, like the window does. Run it with -race.
+func TestInputConcurrent(t *testing.T) {
+	var in input
+	in.reset()
+
+	const messages = 1000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < messages; i++ {
+			state := uint8(sdl.PRESSED)
+			if i%2 == 1 {
+				state = sdl.RELEASED
+			}
+			in.handle(gome.KeyboardMessage{Key: sdl.Keysym{Sym: sdl.Keycode('a' + i%26)}, State: state})
+			in.handle(gome.MouseButtonMessage{Button: uint8(i % 3), State: state})
+			in.handle(gome.MouseMotionMessage{XRel: 1, YRel: -1})
+			in.handle(gome.MouseScrollMessage{Y: 1})
+		}
+	}()
+
+	var motion gome.FloatVector2
+	var scroll float32
+	for i := 0; i < messages; i++ {
+		state := in.frame()
+		state.axis(sdl.K_w, sdl.K_s)
+		_ = state.buttons[sdl.BUTTON_LEFT]
+		motion.X += state.motion.X
+		motion.Y += state.motion.Y
+		scroll += state.scroll
+	}
+	wg.Wait()
+
+	state := in.frame()
+	motion.X += state.motion.X
+	motion.Y += state.motion.Y
+	scroll += state.scroll
+
+	// no movement gets lost or counted twice between frames
+	if motion.X != messages || motion.Y != -messages || scroll != messages {
+		t.Errorf("got motion %v and scroll %v, want {%v %v} and %v", motion, scroll, messages, -messages, messages)
+	}
+}
+
+func TestInputAxis(t *testing.T) {
+	var in input
+	in.reset()
+
+	tests := []struct {
+		pressed []sdl.Keycode
+		want    float32
+	}{
+		{nil, 0},
+		{[]sdl.Keycode{sdl.K_w}, 1},
+		{[]sdl.Keycode{sdl.K_s}, -1},
+		{[]sdl.Keycode{sdl.K_w, sdl.K_s}, 0},
+	}
+
+	for _, test := range tests {
+		in.reset()
+		for _, key := range test.pressed {
+			in.handle(gome.KeyboardMessage{Key: sdl.Keysym{Sym: key}, State: sdl.PRESSED})
+		}
+		if got := in.frame().axis(sdl.K_w, sdl.K_s); got != test.want {
+			t.Errorf("axis with %v held = %v, want %v", test.pressed, got, test.want)
+		}
+	}
+}
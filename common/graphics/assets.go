@@ -0,0 +1,619 @@
+package graphics
+
+import (
+	"bytes"
+	"gitlocal/gome"
+	"image"
+	"image/color"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/*
+	Assets
+*/
+
+// An AssetState tells if an asset is ready to be used.
+type AssetState int
+
+const (
+	AssetReady = AssetState(iota)
+	AssetLoading
+	AssetFailed
+)
+
+// An asset is the part every cached resource has in common.
+type asset struct {
+	path    string
+	refs    int
+	manager *AssetManager
+	state   AssetState
+	err     error
+	modTime time.Time
+}
+
+// Path returns the path the asset was loaded from.
+func (a *asset) Path() string { return a.path }
+
+// Refs returns the number of users of the asset.
+func (a *asset) Refs() int { return a.refs }
+
+// State returns if the asset is ready, still loading in the background or
+// failed to load.
+func (a *asset) State() AssetState { return a.state }
+
+// Err returns the error of an asset that failed to load in the background.
+func (a *asset) Err() error { return a.err }
+
+// release decrements the reference count and returns true if
+// it was the last reference.
+func (a *asset) release() bool {
+	if a.refs <= 0 {
+		return false
+	}
+
+	a.refs--
+	return a.refs == 0
+}
+
+// A MeshAsset is a vertex array loaded from an .obj file, together with the
+// textures of its materials.
+type MeshAsset struct {
+	asset
+
+	// Array is the one of the placeholder mesh while the mesh is loading or
+	// after it failed to load.
+	Array VertexArray
+
+	// Parts are the ranges of the array drawn with one material each.
+	Parts []MeshPart
+
+	// Bounds and Sphere bound the vertices, Positions and Indices are the
+	// triangles of the mesh, kept for raycasts. Skinning is not applied.
+	Bounds    gome.AABB
+	Sphere    gome.Sphere
+	Positions []gome.FloatVector3
+	Indices   []uint32
+
+	// fallback is the mesh shown while loading or after failing to load. Its
+	// array, parts and bounds are used in place of own ones.
+	fallback *MeshAsset
+
+	// libraries are the modification times of the material libraries of the
+	// mesh, so hot reloading notices changed materials.
+	libraries map[string]time.Time
+}
+
+// A MeshPart is a range of indices of a mesh drawn with one material.
+type MeshPart struct {
+	First, Count int
+	Material     *Material
+
+	// The textures of the material, nil if the material has none.
+	DiffuseMap   *TextureAsset
+	SpecularMap  *TextureAsset
+	BumpMap      *TextureAsset
+	NormalMap    *TextureAsset
+	MetallicMap  *TextureAsset
+	RoughnessMap *TextureAsset
+	OcclusionMap *TextureAsset
+	EmissiveMap  *TextureAsset
+}
+
+// textures returns pointers to all texture fields of the part, in the order of
+// Material.Maps.
+func (mp *MeshPart) textures() []**TextureAsset {
+	return []**TextureAsset{
+		&mp.DiffuseMap, &mp.SpecularMap, &mp.BumpMap,
+		&mp.NormalMap, &mp.MetallicMap, &mp.RoughnessMap, &mp.OcclusionMap, &mp.EmissiveMap,
+	}
+}
+
+// Release tells the manager the mesh is no longer used. When the last user
+// released it, the GPU memory is freed.
+func (ma *MeshAsset) Release() {
+	if ma.release() {
+		ma.manager.forgetMesh(ma)
+		ma.free()
+	}
+}
+
+func (ma *MeshAsset) free() {
+	if ma.fallback != nil {
+		ma.fallback.Release()
+		ma.fallback = nil
+	} else {
+		ma.Array.Delete()
+		releaseParts(ma.Parts)
+	}
+	ma.Array, ma.Parts = VertexArray{}, nil
+}
+
+// setFallback shows a placeholder mesh in place of the mesh.
+func (ma *MeshAsset) setFallback(fallback *MeshAsset) {
+	ma.free()
+
+	ma.fallback = fallback
+	ma.Array, ma.Parts = fallback.Array, fallback.Parts
+	ma.Bounds, ma.Sphere = fallback.Bounds, fallback.Sphere
+	ma.Positions, ma.Indices = fallback.Positions, fallback.Indices
+}
+
+// upload uploads the vertices of a mesh, keeping its bounds and triangles.
+func (ma *MeshAsset) upload(data *Mesh) {
+	data.UpdateBounds()
+	ma.Array = data.Upload()
+	ma.Bounds, ma.Sphere = data.Bounds, data.Sphere
+	ma.Positions, ma.Indices = data.Positions, data.Indices
+
+	ma.libraries = make(map[string]time.Time, len(data.Libraries))
+	for _, library := range data.Libraries {
+		ma.libraries[library] = modTime(library)
+	}
+}
+
+// releaseParts releases the textures of mesh parts.
+func releaseParts(parts []MeshPart) {
+	for i := range parts {
+		for _, texture := range parts[i].textures() {
+			if *texture != nil {
+				(*texture).Release()
+			}
+		}
+	}
+}
+
+// A TextureAsset is a texture loaded from an image file.
+type TextureAsset struct {
+	asset
+	ID uint32
+
+	// fallback is the texture shown while loading or after failing to load.
+	// Its ID is used in place of an own texture.
+	fallback *TextureAsset
+
+	// external textures are owned by something else, e.g. a render target.
+	external bool
+}
+
+// Release tells the manager the texture is no longer used. When the last user
+// released it, the GPU memory is freed.
+func (ta *TextureAsset) Release() {
+	if ta.release() {
+		ta.manager.forgetTexture(ta)
+		ta.free()
+	}
+}
+
+func (ta *TextureAsset) free() {
+	if ta.fallback != nil {
+		ta.fallback.Release()
+		ta.fallback = nil
+	} else if !ta.external {
+		gl.DeleteTextures(1, &ta.ID)
+	}
+	ta.ID = 0
+}
+
+// setFallback replaces the texture with a placeholder texture.
+func (ta *TextureAsset) setFallback(fallback *TextureAsset) {
+	if ta.fallback != nil {
+		ta.fallback.Release()
+	}
+
+	ta.fallback = fallback
+	ta.ID = fallback.ID
+}
+
+// A ShaderAsset is a shader program loaded from a .shader file.
+type ShaderAsset struct {
+	asset
+	Shader
+}
+
+// Release tells the manager the shader is no longer used. When the last user
+// released it, the program is deleted.
+func (sa *ShaderAsset) Release() {
+	if sa.release() {
+		delete(sa.manager.shaders, sa.path)
+		sa.free()
+	}
+}
+
+func (sa *ShaderAsset) free() {
+	sa.Shader.Delete()
+}
+
+/*
+	AssetManager
+*/
+
+// An AssetManager loads meshes, textures and shaders from gome.Files and caches
+// them by path, so every file gets loaded and uploaded to the GPU only once. Every call to
+// a loading method has to be matched by a call to Release on the returned asset.
+//
+// OpenGL objects can't be shared between contexts, so every scene needs its
+// own AssetManager.
+type AssetManager struct {
+	// UploadsPerFrame limits how many assets loaded in the background get
+	// uploaded to the GPU per call to Poll. 0 means no limit.
+	UploadsPerFrame int
+
+	meshes   map[string]*MeshAsset
+	textures map[string]*TextureAsset
+	shaders  map[string]*ShaderAsset
+
+	loader loader
+	reload hotReload
+}
+
+// NewAssetManager returns an empty asset manager.
+func NewAssetManager() *AssetManager {
+	return &AssetManager{
+		meshes:   make(map[string]*MeshAsset),
+		textures: make(map[string]*TextureAsset),
+		shaders:  make(map[string]*ShaderAsset),
+	}
+}
+
+// assetKey returns the path used to identify an asset, so different ways to
+// write the same path share one asset. Relative paths are paths of gome.Files
+// and are intentionally not made absolute, since they may be served by any
+// mount and not by the working directory. The absolute and relative path of
+// the same file are therefore two different assets.
+func assetKey(name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// assetDir returns the directory of an asset key. Files referenced by the
+// asset (like material libraries) are relative to it.
+func assetDir(key string) string {
+	return path.Dir(filepath.ToSlash(key))
+}
+
+// placeholderKey is the key of the placeholder texture. It can't collide with
+// the key of a file, because "<" can't be part of a path on all systems.
+const placeholderKey = "<placeholder>"
+
+// Placeholder returns a checkerboard texture that can be shown in place of
+// textures that failed to load.
+func (am *AssetManager) Placeholder() *TextureAsset {
+	if texture, ok := am.textures[placeholderKey]; ok {
+		texture.refs++
+		return texture
+	}
+
+	texture := &TextureAsset{
+		asset: asset{path: placeholderKey, refs: 1, manager: am},
+		ID:    PlaceholderTexture(),
+	}
+	am.textures[placeholderKey] = texture
+
+	return texture
+}
+
+// placeholderMeshKey is the key of the placeholder mesh.
+const placeholderMeshKey = "<placeholder mesh>"
+
+// PlaceholderMesh returns a unit cube with the placeholder texture that can
+// be shown in place of meshes that are loading or failed to load.
+func (am *AssetManager) PlaceholderMesh() *MeshAsset {
+	if mesh, ok := am.meshes[placeholderMeshKey]; ok {
+		mesh.refs++
+		return mesh
+	}
+
+	// the cube has no texture maps, so there is nothing that could fail
+	mesh, _ := am.UploadMesh(placeholderMeshKey, Cube(1, 1))
+	mesh.Parts[0].DiffuseMap = am.Placeholder()
+	return mesh
+}
+
+// forgetMesh removes a mesh from the cache, so the next request of its path
+// loads it anew. Meshes that were replaced in the cache already are ignored.
+func (am *AssetManager) forgetMesh(mesh *MeshAsset) {
+	if am.meshes[mesh.path] == mesh {
+		delete(am.meshes, mesh.path)
+	}
+}
+
+// forgetTexture removes a texture from the cache, like forgetMesh.
+func (am *AssetManager) forgetTexture(texture *TextureAsset) {
+	if am.textures[texture.path] == texture {
+		delete(am.textures, texture.path)
+	}
+}
+
+// loadingKey is the key of the texture shown while a texture is loading.
+const loadingKey = "<loading>"
+
+// loadingTexture returns a plain grey texture shown while textures load in
+// the background.
+func (am *AssetManager) loadingTexture() *TextureAsset {
+	return am.solidTexture(loadingKey, color.RGBA{R: 128, G: 128, B: 128, A: 255})
+}
+
+// whiteKey is the key of the white texture.
+const whiteKey = "<white>"
+
+// White returns a plain white texture, which can be bound for materials
+// without a texture.
+func (am *AssetManager) White() *TextureAsset {
+	return am.solidTexture(whiteKey, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+}
+
+// solidTexture returns a 1x1 texture of a color, cached under a key.
+func (am *AssetManager) solidTexture(key string, c color.RGBA) *TextureAsset {
+	if texture, ok := am.textures[key]; ok {
+		texture.refs++
+		return texture
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, c)
+
+	texture := &TextureAsset{
+		asset: asset{path: key, refs: 1, manager: am},
+		ID:    uploadTexture(img),
+	}
+	am.textures[key] = texture
+
+	return texture
+}
+
+// TargetTexture makes a color attachment of a render target available as a
+// texture under a key, so materials can use the key as a map, e.g. for screens
+// showing what a camera sees. Register it before the meshes using it get
+// loaded. The texture stays owned by the target and must not outlive it.
+func (am *AssetManager) TargetTexture(key string, target *RenderTarget, attachment int) *TextureAsset {
+	key = assetKey(key)
+	if texture, ok := am.textures[key]; ok {
+		texture.refs++
+		return texture
+	}
+
+	texture := &TextureAsset{
+		asset:    asset{path: key, refs: 1, manager: am},
+		ID:       target.Colors[attachment],
+		external: true,
+	}
+	am.textures[key] = texture
+
+	return texture
+}
+
+// Mesh returns the mesh of an .obj file, loading it if it's not cached yet.
+// Material libraries are loaded relative to the .obj file. If only textures fail
+// to load, the mesh is returned together with the first error, and the failed
+// textures are replaced by the placeholder texture.
+func (am *AssetManager) Mesh(path string) (*MeshAsset, error) {
+	key := assetKey(path)
+	if mesh, ok := am.meshes[key]; ok {
+		mesh.refs++
+		return mesh, nil
+	}
+
+	gome.Log.Debug(gome.CategoryAssets, "loading mesh", "path", path)
+
+	file, err := gome.Files.Open(path)
+	if err != nil {
+		return nil, &gome.AssetError{Path: path, Err: err}
+	}
+	defer file.Close()
+
+	reader := &OBJFileReader{Dir: assetDir(key)}
+	data, err := reader.Parse(file)
+	if err != nil {
+		return nil, &gome.AssetError{Path: path, Err: err}
+	}
+
+	mesh := &MeshAsset{
+		asset: asset{path: key, refs: 1, manager: am, modTime: modTime(key)},
+	}
+	mesh.upload(data)
+	am.meshes[key] = mesh
+
+	mesh.Parts, err = am.loadParts(data.Submeshes, false)
+	return mesh, err
+}
+
+// UploadMesh checks and uploads a mesh that was loaded or generated by other
+// means than Mesh, e.g. a mesh of a model file. Meshes are cached by key, so uploading the
+// same key again only adds a reference; an empty key always uploads a new mesh.
+// Images embedded in the mesh are uploaded as textures under their path.
+//
+// Like with Mesh, textures that fail to load are replaced by the placeholder
+// texture, and the mesh is returned together with the first error.
+func (am *AssetManager) UploadMesh(key string, data *Mesh) (*MeshAsset, error) {
+	if mesh, ok := am.meshes[key]; ok && key != "" {
+		mesh.refs++
+		return mesh, nil
+	}
+
+	if err := data.Validate(); err != nil {
+		return nil, &gome.AssetError{Path: key, Err: err}
+	}
+
+	mesh := &MeshAsset{
+		asset: asset{path: key, refs: 1, manager: am},
+	}
+	mesh.upload(data)
+	if key != "" {
+		am.meshes[key] = mesh
+	}
+
+	// upload the embedded images the materials use, and release them again once
+	// the parts hold their own references
+	embedded := []*TextureAsset{}
+	var err error
+	for _, submesh := range data.Submeshes {
+		for _, file := range submesh.Material.Maps() {
+			image, ok := data.Images[file]
+			if !ok {
+				continue
+			}
+
+			texture, terr := am.embeddedTexture(file, image)
+			if terr != nil {
+				if err == nil {
+					err = terr
+				}
+				continue
+			}
+			embedded = append(embedded, texture)
+		}
+	}
+
+	var perr error
+	mesh.Parts, perr = am.loadParts(data.Submeshes, false)
+	for _, texture := range embedded {
+		texture.Release()
+	}
+
+	if err == nil {
+		err = perr
+	}
+	return mesh, err
+}
+
+// embeddedTexture returns the texture of an encoded image embedded in a model
+// file, uploading it if it's not cached yet.
+func (am *AssetManager) embeddedTexture(key string, data []byte) (*TextureAsset, error) {
+	if texture, ok := am.textures[key]; ok {
+		texture.refs++
+		return texture, nil
+	}
+
+	rgba, err := decodeImage(bytes.NewReader(data))
+	if err != nil {
+		return nil, &gome.AssetError{Path: key, Err: err}
+	}
+
+	texture := &TextureAsset{
+		asset: asset{path: key, refs: 1, manager: am},
+		ID:    uploadTexture(rgba),
+	}
+	am.textures[key] = texture
+
+	return texture, nil
+}
+
+// loadParts loads the textures of the materials of submeshes. Textures that
+// fail to load are replaced by the placeholder texture, and the first error
+// is returned.
+func (am *AssetManager) loadParts(submeshes []Submesh, async bool) (parts []MeshPart, err error) {
+	load := func(file string) *TextureAsset {
+		if len(file) == 0 {
+			return nil
+		}
+		if async {
+			return am.TextureAsync(file)
+		}
+
+		texture, terr := am.Texture(file)
+		if terr != nil {
+			if err == nil {
+				err = terr
+			}
+			return am.Placeholder()
+		}
+		return texture
+	}
+
+	parts = make([]MeshPart, len(submeshes))
+	for i, submesh := range submeshes {
+		parts[i] = MeshPart{
+			First:    submesh.First,
+			Count:    submesh.Count,
+			Material: submesh.Material,
+		}
+
+		files := submesh.Material.Maps()
+		for j, texture := range parts[i].textures() {
+			*texture = load(files[j])
+		}
+	}
+
+	return parts, err
+}
+
+// Texture returns the texture of an image file, loading it if it's not cached yet.
+func (am *AssetManager) Texture(path string) (*TextureAsset, error) {
+	key := assetKey(path)
+	if texture, ok := am.textures[key]; ok {
+		texture.refs++
+		return texture, nil
+	}
+
+	gome.Log.Debug(gome.CategoryAssets, "loading texture", "path", path)
+
+	id, err := newTexture(path)
+	if err != nil {
+		return nil, &gome.AssetError{Path: path, Err: err}
+	}
+
+	texture := &TextureAsset{
+		asset: asset{path: key, refs: 1, manager: am, modTime: modTime(key)},
+		ID:    id,
+	}
+	am.textures[key] = texture
+
+	return texture, nil
+}
+
+// Shader returns the shader program of a .shader file, loading it if it's not
+// cached yet.
+func (am *AssetManager) Shader(path string) (*ShaderAsset, error) {
+	key := assetKey(path)
+	if shader, ok := am.shaders[key]; ok {
+		shader.refs++
+		return shader, nil
+	}
+
+	gome.Log.Debug(gome.CategoryAssets, "loading shader", "path", path)
+
+	file, err := gome.Files.Open(path)
+	if err != nil {
+		return nil, &gome.AssetError{Path: path, Err: err}
+	}
+	defer file.Close()
+
+	shader := &ShaderAsset{
+		asset: asset{path: key, refs: 1, manager: am, modTime: modTime(key)},
+	}
+	if err := shader.Shader.Init(file); err != nil {
+		return nil, &gome.AssetError{Path: path, Err: err}
+	}
+	am.shaders[key] = shader
+
+	return shader, nil
+}
+
+// Clear frees all assets, no matter if they are still used. Assets still
+// loading in the background get discarded.
+func (am *AssetManager) Clear() {
+	// meshes first, since they release their textures
+	for key, mesh := range am.meshes {
+		delete(am.meshes, key)
+		mesh.refs = 0
+		mesh.free()
+	}
+	for key, texture := range am.textures {
+		delete(am.textures, key)
+		texture.refs = 0
+		texture.free()
+	}
+	for key, shader := range am.shaders {
+		delete(am.shaders, key)
+		shader.refs = 0
+		shader.free()
+	}
+
+	am.loader.reset()
+}
@@ -2,6 +2,7 @@ package graphics
 
 import (
 	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
 )
 
 type ElementType uint32
@@ -87,8 +88,15 @@ type VertexArray struct {
 	vao      uint32
 	ibo      uint32
 	vbo      uint32
+
+	// instances is the buffer of the model matrices of instanced draws
+	instances uint32
 }
 
+// InstanceAttribute is the first of the four vertex attributes (the columns of
+// a mat4) holding the model matrix of an instance.
+const InstanceAttribute = 6
+
 // SetLayout sets the vertex layout, but only once.
 func (va *VertexArray) SetLayout(layout VertexLayout) {
 	if len(va.layout.layout) != 0 {
@@ -154,9 +162,63 @@ func (va *VertexArray) SetIndexData(data []uint32) {
 	va.vertices = len(data)
 }
 
+// Empty returns true if there are no vertices to draw.
+func (va *VertexArray) Empty() bool {
+	return va.vertices == 0
+}
+
 // Draw draws the vertex array.
 func (va *VertexArray) Draw() {
 	gl.BindVertexArray(va.vao)
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, va.ibo)
 	gl.DrawElements(gl.TRIANGLES, int32(va.vertices), gl.UNSIGNED_INT, nil)
 }
+
+// DrawRange draws count indices starting at first.
+func (va *VertexArray) DrawRange(first, count int) {
+	gl.BindVertexArray(va.vao)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, va.ibo)
+	gl.DrawElements(gl.TRIANGLES, int32(count), gl.UNSIGNED_INT, gl.PtrOffset(first*4))
+}
+
+// SetInstanceData sets the model matrices of the instances drawn by
+// DrawInstancedRange, one per instance.
+func (va *VertexArray) SetInstanceData(models []mgl32.Mat4) {
+	gl.BindVertexArray(va.vao)
+
+	if va.instances == 0 {
+		gl.GenBuffers(1, &va.instances)
+		gl.BindBuffer(gl.ARRAY_BUFFER, va.instances)
+
+		// a mat4 takes one attribute per column, which advance once per instance
+		for column := 0; column < 4; column++ {
+			attribute := uint32(InstanceAttribute + column)
+			gl.VertexAttribPointer(attribute, 4, gl.FLOAT, false, 16*4, gl.PtrOffset(column*4*4))
+			gl.EnableVertexAttribArray(attribute)
+			gl.VertexAttribDivisor(attribute, 1)
+		}
+	}
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, va.instances)
+	if len(models) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, len(models)*16*4, gl.Ptr(&models[0][0]), gl.STREAM_DRAW)
+	}
+}
+
+// DrawInstancedRange draws count indices starting at first once for every
+// instance set with SetInstanceData.
+func (va *VertexArray) DrawInstancedRange(first, count, instances int) {
+	gl.BindVertexArray(va.vao)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, va.ibo)
+	gl.DrawElementsInstanced(gl.TRIANGLES, int32(count), gl.UNSIGNED_INT, gl.PtrOffset(first*4), int32(instances))
+}
+
+// Delete frees the GPU memory used by the vertex array and its buffers.
+func (va *VertexArray) Delete() {
+	gl.DeleteBuffers(1, &va.vbo)
+	gl.DeleteBuffers(1, &va.ibo)
+	gl.DeleteBuffers(1, &va.instances)
+	gl.DeleteVertexArrays(1, &va.vao)
+
+	*va = VertexArray{}
+}
@@ -1,40 +1,373 @@
 #shader vertex
-#version 130
+#version 330 core
 
-in vec3 vertex_pos;
-in vec2 vertex_uv;
-in vec3 vertex_normal;
+layout(location = 0) in vec3 vertex_pos;
+layout(location = 1) in vec2 vertex_uv;
+layout(location = 2) in vec3 vertex_normal;
+layout(location = 3) in vec4 vertex_tangent;
+layout(location = 4) in vec4 vertex_joints;
+layout(location = 5) in vec4 vertex_weights;
+
+// the model matrix of instanced draws, one per instance
+layout(location = 6) in mat4 instance_model;
 
 out vec2 uv;
 out vec3 normal;
-
-struct LightSoruce {
-	int Type;
-	vec3 Position;
-	vec3 Direction;
-	vec3 Color;
-	float Attenuation;
-};
+out vec4 tangent;
+out vec3 position;
 
 uniform mat4 u_MVP;
-//uniform LightSource u_Lights[16];
+uniform mat4 u_Model;
+uniform mat3 u_NormalMatrix;
+
+// instanced draws take the model matrix from the instance attribute instead
+uniform int u_Instanced;
+uniform mat4 u_ViewProjection;
+
+// skinned meshes get deformed by up to 4 of the joints
+uniform int u_Skinned;
+uniform mat4 u_Joints[64];
 
 void main() {
+	mat4 skin = mat4(1.0);
+	if (u_Skinned != 0 && dot(vertex_weights, vec4(1.0)) > 0.0) {
+		skin = vertex_weights.x * u_Joints[int(vertex_joints.x)] +
+			vertex_weights.y * u_Joints[int(vertex_joints.y)] +
+			vertex_weights.z * u_Joints[int(vertex_joints.z)] +
+			vertex_weights.w * u_Joints[int(vertex_joints.w)];
+	}
+
+	mat4 model = u_Model;
+	mat3 normalMatrix = u_NormalMatrix;
+	mat4 MVP = u_MVP;
+	if (u_Instanced != 0) {
+		model = instance_model;
+		normalMatrix = transpose(inverse(mat3(model)));
+		MVP = u_ViewProjection * model;
+	}
+
 	uv = vertex_uv;
-	normal = vertex_normal;
-    gl_Position = u_MVP * vec4(vertex_pos, 1.0);
+	normal = normalMatrix * mat3(skin) * vertex_normal;
+	tangent = vec4(mat3(model) * mat3(skin) * vertex_tangent.xyz, vertex_tangent.w);
+	position = vec3(model * skin * vec4(vertex_pos, 1.0));
+    gl_Position = MVP * skin * vec4(vertex_pos, 1.0);
 }
 
 #shader fragment
-#version 130
+#version 330 core
 
 in vec2 uv;
 in vec3 normal;
+in vec4 tangent;
+in vec3 position;
 
 out vec4 fColor;
 
+#define POINT_LIGHT 0
+#define DIRECTIONAL_LIGHT 1
+#define SPOT_LIGHT 2
+
+// must match MaxLights and lightData in light.go
+#define MAX_LIGHTS 64
+
+#define PI 3.14159265
+
+struct LightSource {
+	vec4 Position;  // w is the type
+	vec4 Direction;
+	vec4 Color;     // w is the attenuation
+	vec4 Cone;      // cosines of the inner and outer cone
+};
+
+layout(std140) uniform u_Lights {
+	vec4 u_Ambient;
+	ivec4 u_LightCount;
+	LightSource u_Light[MAX_LIGHTS];
+};
+
+// texture unit 0 to 6
 uniform sampler2D tex;
+uniform sampler2D u_SpecularMap;
+uniform sampler2D u_NormalMap;
+uniform sampler2D u_MetallicMap;
+uniform sampler2D u_RoughnessMap;
+uniform sampler2D u_OcclusionMap;
+uniform sampler2D u_EmissiveMap;
+
+uniform vec3 u_Diffuse;
+uniform vec3 u_Specular;
+uniform float u_Shininess;
+uniform float u_Dissolve;
+uniform vec3 u_Emissive;
+uniform int u_NormalMapped;
+
+uniform int u_PBR;
+uniform float u_Metallic;
+uniform float u_Roughness;
+
+// the environment cubemap on texture unit 7 and the spherical harmonics of
+// its diffuse light
+uniform int u_HasEnvironment;
+uniform samplerCube u_Environment;
+uniform float u_EnvironmentLevels;
+uniform vec3 u_Irradiance[9];
+
+uniform vec3 u_CameraPosition;
+
+// u_LinearOutput keeps the output in linear color space for post effects,
+// which encode it for the screen after the last one
+uniform int u_LinearOutput;
+
+// the cascaded shadow map of a directional light on texture unit 8, with the
+// depths where the cascades end
+uniform sampler2DArrayShadow u_CascadeMap;
+uniform mat4 u_CascadeMatrices[4];
+uniform vec4 u_CascadeSplits;
+uniform int u_CascadeCount;
+uniform vec3 u_CascadeOrigin;
+uniform vec3 u_CascadeForward;
+
+// the cube shadow maps of point and spot lights on texture units 9 to 12, which
+// store the distance to the light divided by the far plane
+uniform samplerCubeShadow u_PointShadow0;
+uniform samplerCubeShadow u_PointShadow1;
+uniform samplerCubeShadow u_PointShadow2;
+uniform samplerCubeShadow u_PointShadow3;
+uniform vec4 u_PointShadowFar;
+
+// cascadeShadow returns how much of a directional light reaches the fragment.
+float cascadeShadow(vec3 N, vec3 L) {
+	float depth = dot(position - u_CascadeOrigin, u_CascadeForward);
+	int cascade = 0;
+	while (cascade < u_CascadeCount && depth > u_CascadeSplits[cascade]) {
+		cascade++;
+	}
+	if (cascade >= u_CascadeCount) {
+		return 1.0;
+	}
+
+	vec4 projected = u_CascadeMatrices[cascade] * vec4(position, 1.0);
+	vec3 coords = projected.xyz / projected.w * 0.5 + 0.5;
+	if (coords.z > 1.0) {
+		return 1.0;
+	}
+
+	// steep surfaces need a bigger bias against shadow acne
+	float bias = max(0.002 * (1.0 - dot(N, L)), 0.0005);
+
+	// percentage closer filtering over 3x3 texels
+	vec2 texel = 1.0 / vec2(textureSize(u_CascadeMap, 0).xy);
+	float lit = 0.0;
+	for (int x = -1; x <= 1; x++) {
+		for (int y = -1; y <= 1; y++) {
+			lit += texture(u_CascadeMap, vec4(coords.xy + vec2(x, y) * texel, float(cascade), coords.z - bias));
+		}
+	}
+	return lit / 9.0;
+}
+
+// pointShadowSample compares a depth with a cube shadow map.
+float pointShadowSample(int slot, vec3 direction, float depth) {
+	vec4 coords = vec4(direction, depth);
+	if (slot == 0) {
+		return texture(u_PointShadow0, coords);
+	} else if (slot == 1) {
+		return texture(u_PointShadow1, coords);
+	} else if (slot == 2) {
+		return texture(u_PointShadow2, coords);
+	}
+	return texture(u_PointShadow3, coords);
+}
+
+// pointShadow returns how much of a point or spot light reaches the fragment.
+float pointShadow(int slot, vec3 lightPosition, vec3 N, vec3 L) {
+	vec3 offset = position - lightPosition;
+	float far = u_PointShadowFar[slot];
+	float dist = length(offset);
+	if (dist >= far) {
+		return 1.0;
+	}
+
+	float bias = max(0.01 * (1.0 - dot(N, L)), 0.002);
+	float depth = dist / far - bias;
+
+	// percentage closer filtering with offsets in all directions
+	const vec3 kernel[8] = vec3[](
+		vec3(1, 1, 1), vec3(1, -1, 1), vec3(-1, -1, 1), vec3(-1, 1, 1),
+		vec3(1, 1, -1), vec3(1, -1, -1), vec3(-1, -1, -1), vec3(-1, 1, -1)
+	);
+	float radius = 0.005 * dist;
+	float lit = pointShadowSample(slot, offset, depth);
+	for (int i = 0; i < 8; i++) {
+		lit += pointShadowSample(slot, offset + kernel[i] * radius, depth);
+	}
+	return lit / 9.0;
+}
+
+// light returns the direction to a light and its color at the fragment.
+vec3 light(int i, vec3 N, out vec3 L) {
+	LightSource source = u_Light[i];
+	int type = int(source.Position.w);
+	int shadow = int(source.Cone.z);
+
+	if (type == DIRECTIONAL_LIGHT) {
+		L = -normalize(source.Direction.xyz);
+		if (shadow >= 0) {
+			return source.Color.rgb * cascadeShadow(N, L);
+		}
+		return source.Color.rgb;
+	}
+
+	vec3 offset = source.Position.xyz - position;
+	float dist = length(offset);
+	L = offset / max(dist, 1e-4);
+	float intensity = 1.0 / (1.0 + source.Color.w * dist * dist);
+
+	if (type == SPOT_LIGHT) {
+		float angle = dot(-L, normalize(source.Direction.xyz));
+		intensity *= smoothstep(source.Cone.y, max(source.Cone.x, source.Cone.y + 1e-4), angle);
+	}
+	if (shadow >= 0 && intensity > 0.0) {
+		intensity *= pointShadow(shadow, source.Position.xyz, N, L);
+	}
+
+	return source.Color.rgb * intensity;
+}
+
+// surfaceNormal returns the normal of the fragment, bent by the normal map.
+vec3 surfaceNormal() {
+	vec3 N = normalize(normal);
+	if (u_NormalMapped == 0 || dot(tangent.xyz, tangent.xyz) == 0.0) {
+		return N;
+	}
+
+	vec3 T = normalize(tangent.xyz - N * dot(N, tangent.xyz));
+	vec3 B = cross(N, T) * tangent.w;
+	vec3 mapped = texture(u_NormalMap, uv).xyz * 2.0 - 1.0;
+	return normalize(mat3(T, B, N) * mapped);
+}
+
+// blinnPhong shades the fragment with the specular material model. The albedo
+// is in linear color space.
+vec3 blinnPhong(vec3 albedo, vec3 N, vec3 V) {
+	vec3 specular = texture(u_SpecularMap, uv).rgb * u_Specular;
+
+	vec3 color = u_Ambient.rgb * albedo;
+	for (int i = 0; i < u_LightCount.x; i++) {
+		vec3 L;
+		vec3 radiance = light(i, N, L);
+
+		float lambert = max(dot(N, L), 0.0);
+		if (lambert <= 0.0) {
+			continue;
+		}
+
+		vec3 H = normalize(L + V);
+		float highlight = pow(max(dot(N, H), 0.0), max(u_Shininess, 1.0));
+
+		color += radiance * (albedo * lambert + specular * highlight);
+	}
+
+	return color;
+}
+
+// irradiance returns the diffuse light of the environment from a direction.
+vec3 irradiance(vec3 n) {
+	return max(
+		u_Irradiance[0] * 0.282095 +
+		u_Irradiance[1] * 0.488603 * n.y +
+		u_Irradiance[2] * 0.488603 * n.z +
+		u_Irradiance[3] * 0.488603 * n.x +
+		u_Irradiance[4] * 1.092548 * n.x * n.y +
+		u_Irradiance[5] * 1.092548 * n.y * n.z +
+		u_Irradiance[6] * 0.315392 * (3.0 * n.z * n.z - 1.0) +
+		u_Irradiance[7] * 1.092548 * n.x * n.z +
+		u_Irradiance[8] * 0.546274 * (n.x * n.x - n.y * n.y),
+		vec3(0.0));
+}
+
+// environmentBRDF approximates the split sum of the specular environment
+// light (Karis, 2014).
+vec2 environmentBRDF(float NdotV, float roughness) {
+	const vec4 c0 = vec4(-1.0, -0.0275, -0.572, 0.022);
+	const vec4 c1 = vec4(1.0, 0.0425, 1.04, -0.04);
+	vec4 r = roughness * c0 + c1;
+	float a004 = min(r.x * r.x, exp2(-9.28 * NdotV)) * r.x + r.y;
+	return vec2(-1.04, 1.04) * a004 + r.zw;
+}
+
+// pbr shades the fragment with the metallic-roughness material model. The
+// albedo is in linear color space.
+vec3 pbr(vec3 albedo, vec3 N, vec3 V) {
+	float metallic = clamp(texture(u_MetallicMap, uv).b * u_Metallic, 0.0, 1.0);
+	float roughness = clamp(texture(u_RoughnessMap, uv).g * u_Roughness, 0.04, 1.0);
+	float occlusion = texture(u_OcclusionMap, uv).r;
+
+	vec3 F0 = mix(vec3(0.04), albedo, metallic);
+	vec3 diffuse = albedo * (1.0 - metallic);
+	float NdotV = max(dot(N, V), 1e-4);
+
+	// Cook-Torrance with the GGX distribution, Smith-Schlick geometry and the
+	// Schlick fresnel
+	float a = roughness * roughness;
+	float k = (roughness + 1.0) * (roughness + 1.0) / 8.0;
+
+	vec3 color = vec3(0.0);
+	for (int i = 0; i < u_LightCount.x; i++) {
+		vec3 L;
+		vec3 radiance = light(i, N, L);
+
+		float NdotL = dot(N, L);
+		if (NdotL <= 0.0) {
+			continue;
+		}
+
+		vec3 H = normalize(L + V);
+		float NdotH = max(dot(N, H), 0.0);
+		float d = NdotH * NdotH * (a * a - 1.0) + 1.0;
+		float D = a * a / (PI * d * d);
+		float G = NdotL / (NdotL * (1.0 - k) + k) * NdotV / (NdotV * (1.0 - k) + k);
+		vec3 F = F0 + (1.0 - F0) * pow(1.0 - max(dot(H, V), 0.0), 5.0);
+
+		vec3 specular = D * G * F / (4.0 * NdotL * NdotV);
+		vec3 kD = (1.0 - F) * diffuse;
+		color += (kD / PI + specular) * radiance * NdotL;
+	}
+
+	// ambient and environment light
+	vec3 ambient = u_Ambient.rgb * diffuse;
+	if (u_HasEnvironment != 0) {
+		vec3 R = reflect(-V, N);
+		vec3 prefiltered = textureLod(u_Environment, R, roughness * (u_EnvironmentLevels - 1.0)).rgb;
+		vec2 brdf = environmentBRDF(NdotV, roughness);
+
+		ambient += diffuse * irradiance(N) + prefiltered * (F0 * brdf.x + brdf.y);
+	}
+	color += ambient * occlusion;
+
+	return color;
+}
 
 void main() {
-    fColor = texture(tex, uv);
+	vec4 color = texture(tex, uv);
+	vec3 emissive = texture(u_EmissiveMap, uv).rgb;
+
+	vec3 N = surfaceNormal();
+	vec3 V = normalize(u_CameraPosition - position);
+
+	// textures are stored in sRGB, light adds up in linear color space for
+	// both material models
+	vec3 albedo = pow(color.rgb, vec3(2.2)) * u_Diffuse;
+	vec3 lit = pow(emissive, vec3(2.2)) * u_Emissive;
+	if (u_PBR == 0) {
+		lit += blinnPhong(albedo, N, V);
+	} else {
+		lit += pbr(albedo, N, V);
+	}
+
+	// encode the linear color for the screen once, at the very end of the frame
+	if (u_LinearOutput == 0) {
+		lit = pow(max(lit, 0.0), vec3(1.0 / 2.2));
+	}
+	fColor = vec4(lit, color.a * u_Dissolve);
 }
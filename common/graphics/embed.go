@@ -0,0 +1,78 @@
+package graphics
+
+import (
+	"embed"
+	"gitlocal/gome"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// engineAssets are the assets every game needs, embedded into the binary.
+//
+//go:embed default.shader shadow.shader pick.shader post
+var engineAssets embed.FS
+
+// DefaultShader is the path of the shader the RenderSystem uses.
+const DefaultShader = "gome/default.shader"
+
+// ShadowShader is the path of the shader lights render shadow maps with.
+const ShadowShader = "gome/shadow.shader"
+
+// PickShader is the path of the shader entity IDs get picked with.
+const PickShader = "gome/pick.shader"
+
+func init() {
+	gome.Files.Mount("gome", engineAssets)
+}
+
+// sourceMount is the source directory mounted by MountSourceAssets, with the
+// number of its users.
+var sourceMount struct {
+	sync.Mutex
+	users int
+	fsys  fs.FS
+}
+
+// MountSourceAssets mounts the source directory of this package over the
+// embedded engine assets under "gome", if it still exists where the binary was
+// built. Embedded files have no modification time, so this is what lets hot
+// reloading pick up changes to the engine shaders. It returns whether the
+// directory is mounted. Every call has to be paired with one of
+// UnmountSourceAssets.
+func MountSourceAssets() (mounted bool) {
+	sourceMount.Lock()
+	defer sourceMount.Unlock()
+
+	sourceMount.users++
+	if sourceMount.users == 1 {
+		if _, file, _, ok := runtime.Caller(0); ok {
+			dir := filepath.Dir(file)
+			if _, err := os.Stat(filepath.Join(dir, "default.shader")); err == nil {
+				sourceMount.fsys = os.DirFS(dir)
+				gome.Files.Mount("gome", sourceMount.fsys)
+				gome.Log.Info(gome.CategoryAssets, "mounted engine assets from source", "dir", dir)
+			}
+		}
+	}
+
+	return sourceMount.fsys != nil
+}
+
+// UnmountSourceAssets undoes a call of MountSourceAssets. The source directory
+// gets unmounted once nothing uses it anymore.
+func UnmountSourceAssets() {
+	sourceMount.Lock()
+	defer sourceMount.Unlock()
+
+	if sourceMount.users == 0 {
+		return
+	}
+	sourceMount.users--
+	if sourceMount.users == 0 && sourceMount.fsys != nil {
+		gome.Files.UnmountFS("gome", sourceMount.fsys)
+		sourceMount.fsys = nil
+	}
+}
@@ -0,0 +1,35 @@
+package graphics
+
+import (
+	"gitlocal/gome"
+	"testing"
+)
+
+func TestMountSourceAssets(t *testing.T) {
+	if !modTime(DefaultShader).IsZero() {
+		t.Fatal("embedded shader has a modification time")
+	}
+
+	// the tests run from the source, so the directory exists
+	if !MountSourceAssets() || !MountSourceAssets() {
+		t.Fatal("source assets not mounted")
+	}
+	for _, shader := range []string{DefaultShader, ShadowShader, PickShader} {
+		if modTime(shader).IsZero() {
+			t.Errorf("%s has no modification time", shader)
+		}
+	}
+
+	// the directory stays mounted until the last user unmounts it
+	UnmountSourceAssets()
+	if modTime(DefaultShader).IsZero() {
+		t.Error("source assets unmounted while still in use")
+	}
+	UnmountSourceAssets()
+	if !modTime(DefaultShader).IsZero() {
+		t.Error("source assets still mounted")
+	}
+	if _, err := gome.Files.Stat(DefaultShader); err != nil {
+		t.Errorf("embedded shader gone after unmounting: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package graphics
+
+import (
+	"fmt"
+	"gitlocal/gome"
+	"image"
+	"math"
+	"math/bits"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/*
+	Environment
+*/
+
+// An Environment is a cubemap of the surroundings of a scene. PBR materials
+// reflect it and are lit by it.
+type Environment struct {
+	// ID is the OpenGL cubemap texture. Its mipmaps are used for the blurry
+	// reflections of rough surfaces.
+	ID uint32
+
+	// Levels is the number of mipmap levels of the cubemap.
+	Levels int
+
+	// Irradiance are the spherical harmonics coefficients of the diffuse light
+	// the environment casts, already convolved with the cosine lobe and
+	// divided by pi, in linear color space.
+	Irradiance [9]gome.FloatVector3
+}
+
+// LoadEnvironment loads a cubemap from six square images of the same size, in
+// the order +X, -X, +Y, -Y, +Z, -Z. It must be called on the thread owning the
+// OpenGL context.
+func LoadEnvironment(faces [6]string) (*Environment, error) {
+	images := [6]*image.RGBA{}
+	for i, face := range faces {
+		file, err := gome.Files.Open(face)
+		if err != nil {
+			return nil, &gome.AssetError{Path: face, Err: err}
+		}
+
+		images[i], err = decodeImage(file)
+		file.Close()
+		if err != nil {
+			return nil, &gome.AssetError{Path: face, Err: err}
+		}
+
+		size := images[i].Rect.Size()
+		if size.X != size.Y || size != images[0].Rect.Size() {
+			return nil, &gome.AssetError{Path: face, Err: fmt.Errorf("cubemap faces must be squares of the same size")}
+		}
+	}
+
+	env := &Environment{
+		Levels:     bits.Len(uint(images[0].Rect.Dx())),
+		Irradiance: irradiance(images),
+	}
+
+	gl.GenTextures(1, &env.ID)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, env.ID)
+	for i, img := range images {
+		gl.TexImage2D(
+			gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i),
+			0,
+			gl.SRGB8_ALPHA8, // sample in linear color space
+			int32(img.Rect.Dx()),
+			int32(img.Rect.Dy()),
+			0,
+			gl.RGBA,
+			gl.UNSIGNED_BYTE,
+			gl.Ptr(img.Pix))
+	}
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+
+	// filter across the edges of the faces, so blurry reflections have no seams
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+
+	return env, nil
+}
+
+// Delete frees the cubemap.
+func (e *Environment) Delete() {
+	gl.DeleteTextures(1, &e.ID)
+	e.ID = 0
+}
+
+// cubemapDirection returns the direction of a point on a face of a cubemap,
+// with u and v from -1 to 1 going right and down in the image.
+func cubemapDirection(face int, u, v float64) (x, y, z float64) {
+	switch face {
+	case 0:
+		return 1, -v, -u
+	case 1:
+		return -1, -v, u
+	case 2:
+		return u, 1, v
+	case 3:
+		return u, -1, -v
+	case 4:
+		return u, -v, 1
+	default:
+		return -u, -v, -1
+	}
+}
+
+// shBasis returns the first 9 real spherical harmonics of a unit direction.
+func shBasis(x, y, z float64) [9]float64 {
+	return [9]float64{
+		0.282095,
+		0.488603 * y,
+		0.488603 * z,
+		0.488603 * x,
+		1.092548 * x * y,
+		1.092548 * y * z,
+		0.315392 * (3*z*z - 1),
+		1.092548 * x * z,
+		0.546274 * (x*x - y*y),
+	}
+}
+
+// irradiance projects the faces of a cubemap onto spherical harmonics and
+// convolves them with the cosine lobe (Ramamoorthi and Hanrahan, 2001).
+func irradiance(faces [6]*image.RGBA) (coefficients [9]gome.FloatVector3) {
+	// the diffuse light is smooth, a few thousand samples per face are enough
+	size := faces[0].Rect.Dx()
+	step := 1
+	for size/step > 64 {
+		step *= 2
+	}
+
+	// sRGB to linear
+	linear := [256]float64{}
+	for i := range linear {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			linear[i] = c / 12.92
+		} else {
+			linear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+
+	sums := [9][3]float64{}
+	weight := 0.0
+	for face, img := range faces {
+		for py := step / 2; py < size; py += step {
+			for px := step / 2; px < size; px += step {
+				u := 2*(float64(px)+0.5)/float64(size) - 1
+				v := 2*(float64(py)+0.5)/float64(size) - 1
+				x, y, z := cubemapDirection(face, u, v)
+
+				// the solid angle of a texel shrinks towards the corners
+				length := math.Sqrt(x*x + y*y + z*z)
+				solidAngle := 1 / (length * length * length)
+				weight += solidAngle
+
+				offset := img.PixOffset(img.Rect.Min.X+px, img.Rect.Min.Y+py)
+				color := [3]float64{linear[img.Pix[offset]], linear[img.Pix[offset+1]], linear[img.Pix[offset+2]]}
+
+				basis := shBasis(x/length, y/length, z/length)
+				for i, b := range basis {
+					for c := range color {
+						sums[i][c] += color[c] * b * solidAngle
+					}
+				}
+			}
+		}
+	}
+
+	// the weights have to add up to the area of the sphere. The bands of the
+	// cosine lobe are pi, 2pi/3 and pi/4, divided by pi for the diffuse BRDF.
+	bands := [9]float64{1, 2.0 / 3, 2.0 / 3, 2.0 / 3, 0.25, 0.25, 0.25, 0.25, 0.25}
+	for i := range sums {
+		scale := 4 * math.Pi / weight * bands[i]
+		coefficients[i] = gome.FloatVector3{
+			X: float32(sums[i][0] * scale),
+			Y: float32(sums[i][1] * scale),
+			Z: float32(sums[i][2] * scale),
+		}
+	}
+
+	return coefficients
+}
@@ -0,0 +1,1053 @@
+package graphics
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlocal/gome"
+	"io"
+	"math"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	glTF document
+*/
+
+// The following types mirror the parts of the glTF 2.0 JSON the reader uses.
+// See https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html
+
+type gltfDocument struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	ExtensionsRequired []string `json:"extensionsRequired"`
+
+	Scene  *int `json:"scene"`
+	Scenes []struct {
+		Nodes []int `json:"nodes"`
+	} `json:"scenes"`
+
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Textures    []gltfTexture    `json:"textures"`
+	Images      []gltfImage      `json:"images"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	Skins       []gltfSkin       `json:"skins"`
+	Animations  []gltfAnimation  `json:"animations"`
+}
+
+type gltfNode struct {
+	Name        string    `json:"name"`
+	Children    []int     `json:"children"`
+	Mesh        *int      `json:"mesh"`
+	Skin        *int      `json:"skin"`
+	Matrix      []float32 `json:"matrix"`
+	Translation []float32 `json:"translation"`
+	Rotation    []float32 `json:"rotation"`
+	Scale       []float32 `json:"scale"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type gltfMaterial struct {
+	Name                 string `json:"name"`
+	PBRMetallicRoughness struct {
+		BaseColorFactor          []float32       `json:"baseColorFactor"`
+		BaseColorTexture         *gltfTextureRef `json:"baseColorTexture"`
+		MetallicFactor           *float32        `json:"metallicFactor"`
+		RoughnessFactor          *float32        `json:"roughnessFactor"`
+		MetallicRoughnessTexture *gltfTextureRef `json:"metallicRoughnessTexture"`
+	} `json:"pbrMetallicRoughness"`
+	NormalTexture    *gltfTextureRef `json:"normalTexture"`
+	OcclusionTexture *gltfTextureRef `json:"occlusionTexture"`
+	EmissiveTexture  *gltfTextureRef `json:"emissiveTexture"`
+	EmissiveFactor   []float32       `json:"emissiveFactor"`
+	AlphaMode        string          `json:"alphaMode"`
+}
+
+type gltfTextureRef struct {
+	Index    int `json:"index"`
+	TexCoord int `json:"texCoord"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri"`
+	MimeType   string `json:"mimeType"`
+	BufferView *int   `json:"bufferView"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int            `json:"bufferView"`
+	ByteOffset    int             `json:"byteOffset"`
+	ComponentType int             `json:"componentType"`
+	Normalized    bool            `json:"normalized"`
+	Count         int             `json:"count"`
+	Type          string          `json:"type"`
+	Sparse        json.RawMessage `json:"sparse"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfSkin struct {
+	Name                string `json:"name"`
+	InverseBindMatrices *int   `json:"inverseBindMatrices"`
+	Joints              []int  `json:"joints"`
+}
+
+type gltfAnimation struct {
+	Name     string `json:"name"`
+	Channels []struct {
+		Sampler int `json:"sampler"`
+		Target  struct {
+			Node *int   `json:"node"`
+			Path string `json:"path"`
+		} `json:"target"`
+	} `json:"channels"`
+	Samplers []struct {
+		Input         int    `json:"input"`
+		Output        int    `json:"output"`
+		Interpolation string `json:"interpolation"`
+	} `json:"samplers"`
+}
+
+// accessor component types
+const (
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+)
+
+// primitive modes
+const (
+	gltfTriangles     = 4
+	gltfTriangleStrip = 5
+	gltfTriangleFan   = 6
+)
+
+// components of the accessor types
+var gltfTypeComponents = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT2":   4,
+	"MAT3":   9,
+	"MAT4":   16,
+}
+
+/*
+	GLTFFileReader
+*/
+
+// A GLTFFileReader reads a glTF 2.0 file, either as .gltf (JSON) or .glb (binary).
+type GLTFFileReader struct {
+	// Path is the path of the file. External buffers and images are loaded
+	// relative to it, and images embedded in the file get texture paths
+	// starting with it.
+	Path string
+}
+
+// Check checks if the file type is glTF.
+func (gfr *GLTFFileReader) Check(file io.Reader) bool {
+	// TODO
+	return true
+}
+
+// Extension returns the default file extention for this file type.
+func (gfr *GLTFFileReader) Extension() string { return "gltf" }
+
+// gltfParser holds the state while converting a glTF document.
+type gltfParser struct {
+	path    string
+	doc     gltfDocument
+	buffers [][]byte
+
+	materials []*Material
+	images    map[string][]byte
+}
+
+// Parse reads the file without uploading anything to the GPU, so it can be
+// called from any goroutine.
+//
+// Only triangle primitives are supported. Every primitive of a mesh becomes a
+// submesh with its own material. Images embedded in the file are returned in
+// the Images of the meshes, keyed by the texture path used in their materials.
+func (gfr *GLTFFileReader) Parse(file io.Reader) (*Model, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &gltfParser{path: gfr.Path, images: make(map[string][]byte)}
+
+	// binary files start with a header, followed by the JSON and a binary chunk
+	var binaryChunk []byte
+	if len(content) >= 4 && string(content[:4]) == "glTF" {
+		content, binaryChunk, err = splitGLB(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := json.Unmarshal(content, &parser.doc); err != nil {
+		return nil, fmt.Errorf("gltf: %w", err)
+	}
+	if !strings.HasPrefix(parser.doc.Asset.Version, "2.") {
+		return nil, fmt.Errorf("gltf: unsupported version %q", parser.doc.Asset.Version)
+	}
+	if len(parser.doc.ExtensionsRequired) > 0 {
+		return nil, fmt.Errorf("gltf: unsupported required extensions %v", parser.doc.ExtensionsRequired)
+	}
+
+	if err := parser.loadBuffers(binaryChunk); err != nil {
+		return nil, err
+	}
+	if err := parser.loadMaterials(); err != nil {
+		return nil, err
+	}
+
+	model := &Model{}
+	for i := range parser.doc.Meshes {
+		mesh, err := parser.mesh(i)
+		if err != nil {
+			return nil, fmt.Errorf("gltf: mesh %d: %w", i, err)
+		}
+		model.Meshes = append(model.Meshes, mesh)
+	}
+	for i := range parser.doc.Skins {
+		skin, err := parser.skin(i)
+		if err != nil {
+			return nil, fmt.Errorf("gltf: skin %d: %w", i, err)
+		}
+		model.Skins = append(model.Skins, skin)
+	}
+	if err := parser.nodes(model); err != nil {
+		return nil, fmt.Errorf("gltf: %w", err)
+	}
+	for i := range parser.doc.Animations {
+		animation, err := parser.animation(i)
+		if err != nil {
+			return nil, fmt.Errorf("gltf: animation %d: %w", i, err)
+		}
+		model.Animations = append(model.Animations, animation)
+	}
+
+	return model, nil
+}
+
+// splitGLB splits a .glb file into its JSON and binary chunk.
+func splitGLB(content []byte) (jsonChunk, binaryChunk []byte, err error) {
+	if len(content) < 12 {
+		return nil, nil, errors.New("glb: file too short")
+	}
+	if version := binary.LittleEndian.Uint32(content[4:8]); version != 2 {
+		return nil, nil, fmt.Errorf("glb: unsupported version %d", version)
+	}
+	if length := binary.LittleEndian.Uint32(content[8:12]); int(length) <= len(content) {
+		content = content[:length]
+	}
+
+	for offset := 12; offset+8 <= len(content); {
+		length := int(binary.LittleEndian.Uint32(content[offset : offset+4]))
+		chunkType := string(content[offset+4 : offset+8])
+		offset += 8
+
+		if length < 0 || offset+length > len(content) {
+			return nil, nil, errors.New("glb: chunk exceeds the file")
+		}
+		chunk := content[offset : offset+length]
+		offset += length
+
+		switch chunkType {
+		case "JSON":
+			jsonChunk = chunk
+		case "BIN\x00":
+			binaryChunk = chunk
+		}
+	}
+
+	if jsonChunk == nil {
+		return nil, nil, errors.New("glb: missing JSON chunk")
+	}
+	return jsonChunk, binaryChunk, nil
+}
+
+// resolve returns the path of a file referenced by the document.
+func (gp *gltfParser) resolve(uri string) string {
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
+	}
+	return path.Join(path.Dir(gp.path), uri)
+}
+
+// readURI returns the data of a data URI or an external file.
+func (gp *gltfParser) readURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.IndexByte(uri, ',')
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, errors.New("unsupported data uri")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+
+	file, err := gome.Files.Open(gp.resolve(uri))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// loadBuffers reads all buffers. A buffer without uri is the binary chunk of
+// a .glb file.
+func (gp *gltfParser) loadBuffers(binaryChunk []byte) (err error) {
+	gp.buffers = make([][]byte, len(gp.doc.Buffers))
+	for i, buffer := range gp.doc.Buffers {
+		if buffer.URI == "" {
+			if binaryChunk == nil {
+				return fmt.Errorf("gltf: buffer %d: missing binary chunk", i)
+			}
+			gp.buffers[i] = binaryChunk
+		} else if gp.buffers[i], err = gp.readURI(buffer.URI); err != nil {
+			return fmt.Errorf("gltf: buffer %d: %w", i, err)
+		}
+
+		if len(gp.buffers[i]) < buffer.ByteLength {
+			return fmt.Errorf("gltf: buffer %d: expected %d bytes, got %d", i, buffer.ByteLength, len(gp.buffers[i]))
+		}
+	}
+
+	return nil
+}
+
+// bufferView returns the bytes of a buffer view.
+func (gp *gltfParser) bufferView(index int) ([]byte, error) {
+	if index < 0 || index >= len(gp.doc.BufferViews) {
+		return nil, fmt.Errorf("buffer view %d out of range", index)
+	}
+
+	view := gp.doc.BufferViews[index]
+	if view.Buffer < 0 || view.Buffer >= len(gp.buffers) {
+		return nil, fmt.Errorf("buffer %d out of range", view.Buffer)
+	}
+
+	buffer := gp.buffers[view.Buffer]
+	if view.ByteOffset < 0 || view.ByteLength < 0 || view.ByteOffset+view.ByteLength > len(buffer) {
+		return nil, fmt.Errorf("buffer view %d exceeds its buffer", index)
+	}
+
+	return buffer[view.ByteOffset : view.ByteOffset+view.ByteLength], nil
+}
+
+// image returns the texture path of an image. Images embedded in the file
+// are added to the images of the parser.
+func (gp *gltfParser) image(index int) (string, error) {
+	if index < 0 || index >= len(gp.doc.Images) {
+		return "", fmt.Errorf("image %d out of range", index)
+	}
+
+	image := gp.doc.Images[index]
+	if image.URI != "" && !strings.HasPrefix(image.URI, "data:") {
+		return gp.resolve(image.URI), nil
+	}
+
+	key := fmt.Sprintf("%s#image%d", gp.path, index)
+	if _, ok := gp.images[key]; ok {
+		return key, nil
+	}
+
+	var data []byte
+	var err error
+	if image.BufferView != nil {
+		data, err = gp.bufferView(*image.BufferView)
+	} else {
+		data, err = gp.readURI(image.URI)
+	}
+	if err != nil {
+		return "", fmt.Errorf("image %d: %w", index, err)
+	}
+
+	gp.images[key] = data
+	return key, nil
+}
+
+// texture returns the texture path of a texture reference.
+func (gp *gltfParser) texture(ref *gltfTextureRef) (string, error) {
+	if ref.Index < 0 || ref.Index >= len(gp.doc.Textures) {
+		return "", fmt.Errorf("texture %d out of range", ref.Index)
+	}
+	if ref.TexCoord != 0 {
+		gome.Log.Warn(gome.CategoryAssets, "only the first uv set is supported", "path", gp.path, "texture", ref.Index)
+	}
+
+	source := gp.doc.Textures[ref.Index].Source
+	if source == nil {
+		return "", fmt.Errorf("texture %d has no image", ref.Index)
+	}
+
+	return gp.image(*source)
+}
+
+// loadMaterials converts the metallic-roughness materials of the document.
+func (gp *gltfParser) loadMaterials() error {
+	for i, gm := range gp.doc.Materials {
+		material := NewMaterial(gm.Name)
+		pbr := gm.PBRMetallicRoughness
+
+		if len(pbr.BaseColorFactor) == 4 {
+			material.Diffuse = gome.FloatVector3{X: pbr.BaseColorFactor[0], Y: pbr.BaseColorFactor[1], Z: pbr.BaseColorFactor[2]}
+			if gm.AlphaMode == "BLEND" {
+				material.Dissolve = pbr.BaseColorFactor[3]
+			}
+		}
+
+		material.PBR = true
+		material.Metallic, material.Roughness = 1, 1
+		if pbr.MetallicFactor != nil {
+			material.Metallic = *pbr.MetallicFactor
+		}
+		if pbr.RoughnessFactor != nil {
+			material.Roughness = *pbr.RoughnessFactor
+		}
+		if len(gm.EmissiveFactor) == 3 {
+			material.Emissive = gome.FloatVector3{X: gm.EmissiveFactor[0], Y: gm.EmissiveFactor[1], Z: gm.EmissiveFactor[2]}
+		}
+
+		// approximate the metallic-roughness model with the specular one, for
+		// renderers that don't support PBR
+		specular := 0.04 + (1-0.04)*material.Metallic
+		material.Specular = gome.FloatVector3{X: specular, Y: specular, Z: specular}
+		material.Shininess = 2/float32(math.Max(math.Pow(float64(material.Roughness), 4), 1e-4)) - 2
+
+		// metallic and roughness share one texture
+		maps := []struct {
+			ref   *gltfTextureRef
+			files []*string
+		}{
+			{pbr.BaseColorTexture, []*string{&material.DiffuseMap}},
+			{pbr.MetallicRoughnessTexture, []*string{&material.MetallicMap, &material.RoughnessMap}},
+			{gm.NormalTexture, []*string{&material.NormalMap}},
+			{gm.OcclusionTexture, []*string{&material.OcclusionMap}},
+			{gm.EmissiveTexture, []*string{&material.EmissiveMap}},
+		}
+		for _, m := range maps {
+			if m.ref == nil {
+				continue
+			}
+
+			file, err := gp.texture(m.ref)
+			if err != nil {
+				return fmt.Errorf("gltf: material %d: %w", i, err)
+			}
+			for _, target := range m.files {
+				*target = file
+			}
+		}
+
+		gp.materials = append(gp.materials, material)
+	}
+
+	return nil
+}
+
+// accessor returns the raw data of an accessor, the byte offset between its
+// elements and the number of components of an element.
+func (gp *gltfParser) accessor(index int) (accessor gltfAccessor, data []byte, stride, components int, err error) {
+	if index < 0 || index >= len(gp.doc.Accessors) {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d out of range", index)
+	}
+
+	accessor = gp.doc.Accessors[index]
+	if accessor.Count < 0 {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d: negative count %d", index, accessor.Count)
+	}
+	if accessor.ByteOffset < 0 {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d: negative byte offset %d", index, accessor.ByteOffset)
+	}
+	if len(accessor.Sparse) > 0 {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d: sparse accessors are not supported", index)
+	}
+
+	components, ok := gltfTypeComponents[accessor.Type]
+	if !ok {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d: unknown type %q", index, accessor.Type)
+	}
+
+	size := gltfComponentSize(accessor.ComponentType)
+	if size == 0 {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d: unknown component type %d", index, accessor.ComponentType)
+	}
+
+	// an accessor without buffer view is all zeros
+	stride = size * components
+	if accessor.BufferView == nil {
+		return accessor, make([]byte, stride*accessor.Count), stride, components, nil
+	}
+
+	view, err := gp.bufferView(*accessor.BufferView)
+	if err != nil {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d: %w", index, err)
+	}
+	if byteStride := gp.doc.BufferViews[*accessor.BufferView].ByteStride; byteStride > 0 {
+		stride = byteStride
+	}
+
+	// compare the number of elements first, so a huge count can't overflow
+	available := len(view) - accessor.ByteOffset
+	if available < 0 || accessor.Count > 0 &&
+		(accessor.Count-1 > available/stride || stride*(accessor.Count-1)+size*components > available) {
+		return accessor, nil, 0, 0, fmt.Errorf("accessor %d exceeds its buffer view", index)
+	}
+
+	return accessor, view[accessor.ByteOffset:], stride, components, nil
+}
+
+// gltfComponentSize returns the size of a component type in bytes, or 0 if
+// the type is unknown.
+func gltfComponentSize(componentType int) int {
+	switch componentType {
+	case gltfByte, gltfUnsignedByte:
+		return 1
+	case gltfShort, gltfUnsignedShort:
+		return 2
+	case gltfUnsignedInt, gltfFloat:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// floats reads an accessor as floats. Normalized integers are converted to
+// the range of 0 to 1 (or -1 to 1 if signed), other integers keep their value.
+func (gp *gltfParser) floats(index int) (values []float32, components int, err error) {
+	accessor, data, stride, components, err := gp.accessor(index)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := gltfComponentSize(accessor.ComponentType)
+	values = make([]float32, 0, accessor.Count*components)
+	for i := 0; i < accessor.Count; i++ {
+		for c := 0; c < components; c++ {
+			element := data[i*stride+c*size:]
+
+			var value float32
+			switch accessor.ComponentType {
+			case gltfFloat:
+				value = math.Float32frombits(binary.LittleEndian.Uint32(element))
+			case gltfUnsignedInt:
+				value = float32(binary.LittleEndian.Uint32(element))
+			case gltfUnsignedShort:
+				value = float32(binary.LittleEndian.Uint16(element))
+				if accessor.Normalized {
+					value /= math.MaxUint16
+				}
+			case gltfShort:
+				value = float32(int16(binary.LittleEndian.Uint16(element)))
+				if accessor.Normalized {
+					value = float32(math.Max(float64(value/math.MaxInt16), -1))
+				}
+			case gltfUnsignedByte:
+				value = float32(element[0])
+				if accessor.Normalized {
+					value /= math.MaxUint8
+				}
+			case gltfByte:
+				value = float32(int8(element[0]))
+				if accessor.Normalized {
+					value = float32(math.Max(float64(value/math.MaxInt8), -1))
+				}
+			}
+
+			values = append(values, value)
+		}
+	}
+
+	return values, components, nil
+}
+
+// indices reads an accessor of unsigned integer indices.
+func (gp *gltfParser) indices(index int) ([]uint32, error) {
+	accessor, data, stride, components, err := gp.accessor(index)
+	if err != nil {
+		return nil, err
+	}
+	if components != 1 {
+		return nil, fmt.Errorf("accessor %d: indices must be scalars", index)
+	}
+
+	indices := make([]uint32, accessor.Count)
+	for i := range indices {
+		element := data[i*stride:]
+
+		switch accessor.ComponentType {
+		case gltfUnsignedByte:
+			indices[i] = uint32(element[0])
+		case gltfUnsignedShort:
+			indices[i] = uint32(binary.LittleEndian.Uint16(element))
+		case gltfUnsignedInt:
+			indices[i] = binary.LittleEndian.Uint32(element)
+		default:
+			return nil, fmt.Errorf("accessor %d: invalid index type %d", index, accessor.ComponentType)
+		}
+	}
+
+	return indices, nil
+}
+
+// attribute reads a vertex attribute with the expected number of components.
+// Missing attributes are returned as nil.
+func (gp *gltfParser) attribute(primitive gltfPrimitive, name string, components int) ([]float32, error) {
+	index, ok := primitive.Attributes[name]
+	if !ok {
+		return nil, nil
+	}
+
+	values, got, err := gp.floats(index)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if got != components {
+		return nil, fmt.Errorf("%s: expected %d components, got %d", name, components, got)
+	}
+
+	return values, nil
+}
+
+// mesh converts a mesh with one submesh per primitive.
+func (gp *gltfParser) mesh(index int) (*Mesh, error) {
+	gm := gp.doc.Meshes[index]
+
+	// an attribute is kept if any primitive has it
+	has := map[string]bool{}
+	for _, primitive := range gm.Primitives {
+		for name := range primitive.Attributes {
+			has[name] = true
+		}
+	}
+
+	mesh := &Mesh{
+		Materials: make(map[string]*Material),
+		Images:    gp.images,
+	}
+
+	for p, primitive := range gm.Primitives {
+		mode := gltfTriangles
+		if primitive.Mode != nil {
+			mode = *primitive.Mode
+		}
+		if mode != gltfTriangles && mode != gltfTriangleStrip && mode != gltfTriangleFan {
+			gome.Log.Warn(gome.CategoryAssets, "skipping primitive that is not made of triangles", "path", gp.path, "mesh", index, "primitive", p)
+			continue
+		}
+
+		positions, err := gp.attribute(primitive, "POSITION", 3)
+		if err != nil {
+			return nil, err
+		}
+		if positions == nil {
+			return nil, fmt.Errorf("primitive %d has no positions", p)
+		}
+		count := len(positions) / 3
+
+		uvs, err := gp.attribute(primitive, "TEXCOORD_0", 2)
+		if err != nil {
+			return nil, err
+		}
+		normals, err := gp.attribute(primitive, "NORMAL", 3)
+		if err != nil {
+			return nil, err
+		}
+		tangents, err := gp.attribute(primitive, "TANGENT", 4)
+		if err != nil {
+			return nil, err
+		}
+		joints, err := gp.attribute(primitive, "JOINTS_0", 4)
+		if err != nil {
+			return nil, err
+		}
+		weights, err := gp.attribute(primitive, "WEIGHTS_0", 4)
+		if err != nil {
+			return nil, err
+		}
+
+		if !sameLength(count, uvs, 2) || !sameLength(count, normals, 3) || !sameLength(count, tangents, 4) ||
+			!sameLength(count, joints, 4) || !sameLength(count, weights, 4) {
+			return nil, fmt.Errorf("primitive %d: attributes differ in length", p)
+		}
+
+		// append the vertices
+		base := uint32(len(mesh.Positions))
+		for i := 0; i < count; i++ {
+			mesh.Positions = append(mesh.Positions, vector3(positions, i))
+			if has["TEXCOORD_0"] {
+				mesh.UVs = append(mesh.UVs, vector2(uvs, i))
+			}
+			if has["NORMAL"] {
+				mesh.Normals = append(mesh.Normals, vector3(normals, i))
+			}
+			if has["TANGENT"] {
+				mesh.Tangents = append(mesh.Tangents, vector4(tangents, i))
+			}
+			if has["JOINTS_0"] {
+				mesh.Joints = append(mesh.Joints, vector4(joints, i))
+				mesh.Weights = append(mesh.Weights, vector4(weights, i))
+			}
+		}
+
+		// read or generate the indices, and convert them to triangles
+		var indices []uint32
+		if primitive.Indices != nil {
+			if indices, err = gp.indices(*primitive.Indices); err != nil {
+				return nil, err
+			}
+		} else {
+			indices = make([]uint32, count)
+			for i := range indices {
+				indices[i] = uint32(i)
+			}
+		}
+		indices = gltfTriangulate(indices, mode)
+
+		first := len(mesh.Indices)
+		for _, vertex := range indices {
+			if int(vertex) >= count {
+				return nil, fmt.Errorf("primitive %d: index %d out of range", p, vertex)
+			}
+			mesh.Indices = append(mesh.Indices, base+vertex)
+		}
+
+		// add the submesh with its material
+		material := NewMaterial("")
+		if primitive.Material != nil {
+			if *primitive.Material < 0 || *primitive.Material >= len(gp.materials) {
+				return nil, fmt.Errorf("primitive %d: material %d out of range", p, *primitive.Material)
+			}
+			material = gp.materials[*primitive.Material]
+		}
+		mesh.Materials[material.Name] = material
+		mesh.Submeshes = append(mesh.Submeshes, Submesh{First: first, Count: len(indices), Material: material})
+		mesh.Groups = append(mesh.Groups, MeshGroup{Object: gm.Name, Material: material.Name, First: first, Count: len(indices)})
+	}
+
+	// the specification asks for flat normals and MikkTSpace tangents if
+	// they are missing
+	if mesh.Normals == nil {
+		mesh.FlatNormals()
+	}
+	if mesh.Tangents == nil && mesh.UVs != nil && mesh.needsTangents() {
+		mesh.GenerateTangents()
+	}
+
+	mesh.UpdateBounds()
+	return mesh, nil
+}
+
+// sameLength checks if an attribute of n components has count elements. Missing
+// attributes always match.
+func sameLength(count int, values []float32, n int) bool {
+	return values == nil || len(values) == count*n
+}
+
+// vector2, vector3 and vector4 return the i-th vector of an attribute, or a
+// zero vector if the attribute is missing.
+func vector2(values []float32, i int) gome.FloatVector2 {
+	if values == nil {
+		return gome.FloatVector2{}
+	}
+	return gome.FloatVector2{X: values[i*2], Y: values[i*2+1]}
+}
+
+func vector3(values []float32, i int) gome.FloatVector3 {
+	if values == nil {
+		return gome.FloatVector3{}
+	}
+	return gome.FloatVector3{X: values[i*3], Y: values[i*3+1], Z: values[i*3+2]}
+}
+
+func vector4(values []float32, i int) gome.FloatVector4 {
+	if values == nil {
+		return gome.FloatVector4{}
+	}
+	return gome.FloatVector4{X: values[i*4], Y: values[i*4+1], Z: values[i*4+2], W: values[i*4+3]}
+}
+
+// gltfTriangulate converts the indices of triangle strips and fans to triangles.
+func gltfTriangulate(indices []uint32, mode int) []uint32 {
+	if mode == gltfTriangles {
+		return indices[:len(indices)/3*3]
+	}
+
+	triangles := []uint32{}
+	for i := 2; i < len(indices); i++ {
+		switch {
+		case mode == gltfTriangleFan:
+			triangles = append(triangles, indices[0], indices[i-1], indices[i])
+		case i%2 == 0:
+			triangles = append(triangles, indices[i-2], indices[i-1], indices[i])
+		default: // keep the winding order of odd strip triangles
+			triangles = append(triangles, indices[i-1], indices[i-2], indices[i])
+		}
+	}
+
+	return triangles
+}
+
+// skin converts a skin.
+func (gp *gltfParser) skin(index int) (skin ModelSkin, err error) {
+	gs := gp.doc.Skins[index]
+	skin.Name = gs.Name
+	skin.Joints = gs.Joints
+
+	if len(gs.Joints) > MaxJoints {
+		return skin, fmt.Errorf("%d joints, at most %d are supported", len(gs.Joints), MaxJoints)
+	}
+	for _, joint := range gs.Joints {
+		if joint < 0 || joint >= len(gp.doc.Nodes) {
+			return skin, fmt.Errorf("joint node %d out of range", joint)
+		}
+	}
+
+	skin.InverseBindMatrices = make([]mgl32.Mat4, len(gs.Joints))
+	if gs.InverseBindMatrices == nil {
+		for i := range skin.InverseBindMatrices {
+			skin.InverseBindMatrices[i] = mgl32.Ident4()
+		}
+		return skin, nil
+	}
+
+	values, components, err := gp.floats(*gs.InverseBindMatrices)
+	if err != nil {
+		return skin, err
+	}
+	if components != 16 || len(values) < 16*len(gs.Joints) {
+		return skin, errors.New("expected a matrix per joint")
+	}
+	for i := range skin.InverseBindMatrices {
+		copy(skin.InverseBindMatrices[i][:], values[i*16:])
+	}
+
+	return skin, nil
+}
+
+// nodes converts the node hierarchy and finds the root nodes of the scene.
+func (gp *gltfParser) nodes(model *Model) error {
+	model.Nodes = make([]ModelNode, len(gp.doc.Nodes))
+	for i := range model.Nodes {
+		model.Nodes[i].Parent = -1
+	}
+
+	for i, gn := range gp.doc.Nodes {
+		node := &model.Nodes[i]
+		node.Name = gn.Name
+		node.Children = gn.Children
+		node.Translation = mgl32.Vec3{}
+		node.Rotation = mgl32.QuatIdent()
+		node.Scale = mgl32.Vec3{1, 1, 1}
+
+		node.Mesh, node.Skin = -1, -1
+		if gn.Mesh != nil {
+			if *gn.Mesh < 0 || *gn.Mesh >= len(model.Meshes) {
+				return fmt.Errorf("node %d: mesh %d out of range", i, *gn.Mesh)
+			}
+			node.Mesh = *gn.Mesh
+		}
+		if gn.Skin != nil {
+			if *gn.Skin < 0 || *gn.Skin >= len(model.Skins) {
+				return fmt.Errorf("node %d: skin %d out of range", i, *gn.Skin)
+			}
+			node.Skin = *gn.Skin
+		}
+
+		if len(gn.Matrix) == 16 {
+			matrix := mgl32.Mat4{}
+			copy(matrix[:], gn.Matrix)
+			node.Translation, node.Rotation, node.Scale = decompose(matrix)
+		}
+		if len(gn.Translation) == 3 {
+			node.Translation = mgl32.Vec3{gn.Translation[0], gn.Translation[1], gn.Translation[2]}
+		}
+		if len(gn.Rotation) == 4 {
+			node.Rotation = mgl32.Quat{W: gn.Rotation[3], V: mgl32.Vec3{gn.Rotation[0], gn.Rotation[1], gn.Rotation[2]}}
+		}
+		if len(gn.Scale) == 3 {
+			node.Scale = mgl32.Vec3{gn.Scale[0], gn.Scale[1], gn.Scale[2]}
+		}
+
+		for _, child := range gn.Children {
+			if child < 0 || child >= len(model.Nodes) || child == i {
+				return fmt.Errorf("node %d: child %d out of range", i, child)
+			}
+			if model.Nodes[child].Parent != -1 {
+				return fmt.Errorf("node %d has more than one parent", child)
+			}
+			model.Nodes[child].Parent = i
+		}
+	}
+
+	// a node whose parents lead back to itself is part of a cycle
+	for i := range model.Nodes {
+		parent := model.Nodes[i].Parent
+		for steps := 0; parent != -1; steps++ {
+			if steps > len(model.Nodes) {
+				return fmt.Errorf("node %d is part of a cycle", i)
+			}
+			parent = model.Nodes[parent].Parent
+		}
+	}
+
+	// use the nodes of the default scene, or all nodes without parent
+	if len(gp.doc.Scenes) > 0 {
+		scene := 0
+		if gp.doc.Scene != nil {
+			scene = *gp.doc.Scene
+		}
+		if scene < 0 || scene >= len(gp.doc.Scenes) {
+			return fmt.Errorf("scene %d out of range", scene)
+		}
+
+		for _, root := range gp.doc.Scenes[scene].Nodes {
+			if root < 0 || root >= len(model.Nodes) || model.Nodes[root].Parent != -1 {
+				return fmt.Errorf("scene %d: invalid root node %d", scene, root)
+			}
+		}
+		model.Roots = gp.doc.Scenes[scene].Nodes
+	} else {
+		for i, node := range model.Nodes {
+			if node.Parent == -1 {
+				model.Roots = append(model.Roots, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// decompose splits a transform matrix into translation, rotation and scale.
+func decompose(matrix mgl32.Mat4) (translation mgl32.Vec3, rotation mgl32.Quat, scale mgl32.Vec3) {
+	translation = matrix.Col(3).Vec3()
+	scale = mgl32.Vec3{matrix.Col(0).Vec3().Len(), matrix.Col(1).Vec3().Len(), matrix.Col(2).Vec3().Len()}
+
+	rotationMatrix := mgl32.Ident4()
+	for col := 0; col < 3; col++ {
+		if scale[col] == 0 {
+			return translation, mgl32.QuatIdent(), scale
+		}
+		rotationMatrix.SetCol(col, matrix.Col(col).Mul(1/scale[col]))
+	}
+	rotationMatrix.SetCol(3, mgl32.Vec4{0, 0, 0, 1})
+
+	return translation, mgl32.Mat4ToQuat(rotationMatrix).Normalize(), scale
+}
+
+// animation converts an animation. Morph target weights are not supported
+// and skipped.
+func (gp *gltfParser) animation(index int) (*Animation, error) {
+	ga := gp.doc.Animations[index]
+	animation := &Animation{Name: ga.Name}
+
+	for c, channel := range ga.Channels {
+		if channel.Target.Node == nil {
+			continue
+		}
+		if *channel.Target.Node < 0 || *channel.Target.Node >= len(gp.doc.Nodes) {
+			return nil, fmt.Errorf("channel %d: node %d out of range", c, *channel.Target.Node)
+		}
+		if channel.Sampler < 0 || channel.Sampler >= len(ga.Samplers) {
+			return nil, fmt.Errorf("channel %d: sampler %d out of range", c, channel.Sampler)
+		}
+		sampler := ga.Samplers[channel.Sampler]
+
+		result := AnimationChannel{Node: *channel.Target.Node}
+		switch channel.Target.Path {
+		case "translation":
+			result.Path = AnimateTranslation
+		case "rotation":
+			result.Path = AnimateRotation
+		case "scale":
+			result.Path = AnimateScale
+		default:
+			gome.Log.Debug(gome.CategoryAssets, "skipping unsupported animation channel", "path", gp.path, "target", channel.Target.Path)
+			continue
+		}
+
+		switch sampler.Interpolation {
+		case "", "LINEAR":
+			result.Interpolation = InterpolateLinear
+		case "STEP":
+			result.Interpolation = InterpolateStep
+		case "CUBICSPLINE":
+			result.Interpolation = InterpolateCubicSpline
+		default:
+			return nil, fmt.Errorf("channel %d: unknown interpolation %q", c, sampler.Interpolation)
+		}
+
+		times, components, err := gp.floats(sampler.Input)
+		if err != nil {
+			return nil, fmt.Errorf("channel %d: %w", c, err)
+		}
+		if components != 1 {
+			return nil, fmt.Errorf("channel %d: keyframe times must be scalars", c)
+		}
+		values, components, err := gp.floats(sampler.Output)
+		if err != nil {
+			return nil, fmt.Errorf("channel %d: %w", c, err)
+		}
+
+		expected := len(times)
+		if result.Interpolation == InterpolateCubicSpline {
+			expected *= 3
+		}
+		if components != result.components() || len(values) != expected*components {
+			return nil, fmt.Errorf("channel %d: expected %d values with %d components", c, expected, result.components())
+		}
+
+		result.Times = times
+		result.Values = values
+		animation.Channels = append(animation.Channels, result)
+
+		if len(times) > 0 && times[len(times)-1] > animation.Duration {
+			animation.Duration = times[len(times)-1]
+		}
+	}
+
+	return animation, nil
+}
@@ -0,0 +1,209 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// gltfTriangleBuffer is a buffer with the positions of a triangle, followed by
+// its indices as unsigned shorts.
+func gltfTriangleBuffer() []byte {
+	buffer := &bytes.Buffer{}
+	for _, value := range []float32{0, 0, 0, 1, 0, 0, 0, 1, 0} {
+		binary.Write(buffer, binary.LittleEndian, math.Float32bits(value))
+	}
+	binary.Write(buffer, binary.LittleEndian, []uint16{0, 1, 2, 0})
+	return buffer.Bytes()
+}
+
+// gltfTriangle returns a document with a triangle mesh, placed by a child
+// node of a root node. The buffer uri is left out if it is empty. change may
+// modify the document before it gets encoded.
+func gltfTriangle(uri string, change func(doc map[string]interface{})) []byte {
+	buffer := map[string]interface{}{"byteLength": 44}
+	if uri != "" {
+		buffer["uri"] = uri
+	}
+
+	doc := map[string]interface{}{
+		"asset": map[string]interface{}{"version": "2.0"},
+		"scene": 0,
+		"scenes": []interface{}{
+			map[string]interface{}{"nodes": []int{0}},
+		},
+		"nodes": []interface{}{
+			map[string]interface{}{"name": "root", "children": []int{1}, "translation": []float32{1, 2, 3}},
+			map[string]interface{}{"name": "triangle", "mesh": 0, "scale": []float32{2, 2, 2}},
+		},
+		"meshes": []interface{}{
+			map[string]interface{}{"name": "triangle", "primitives": []interface{}{
+				map[string]interface{}{"attributes": map[string]int{"POSITION": 0}, "indices": 1, "material": 0},
+			}},
+		},
+		"materials": []interface{}{
+			map[string]interface{}{"name": "red", "pbrMetallicRoughness": map[string]interface{}{
+				"baseColorFactor": []float32{1, 0, 0, 1}, "metallicFactor": 0.5,
+			}},
+		},
+		"accessors": []interface{}{
+			map[string]interface{}{"bufferView": 0, "componentType": gltfFloat, "count": 3, "type": "VEC3"},
+			map[string]interface{}{"bufferView": 1, "componentType": gltfUnsignedShort, "count": 3, "type": "SCALAR"},
+		},
+		"bufferViews": []interface{}{
+			map[string]interface{}{"buffer": 0, "byteLength": 36},
+			map[string]interface{}{"buffer": 0, "byteOffset": 36, "byteLength": 6},
+		},
+		"buffers": []interface{}{buffer},
+	}
+	if change != nil {
+		change(doc)
+	}
+
+	content, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// glb packs a JSON document and a binary chunk into a .glb file.
+func glb(document, bin []byte) []byte {
+	pad := func(chunk []byte, with byte) []byte {
+		for len(chunk)%4 != 0 {
+			chunk = append(chunk, with)
+		}
+		return chunk
+	}
+	document, bin = pad(document, ' '), pad(bin, 0)
+
+	file := &bytes.Buffer{}
+	file.WriteString("glTF")
+	binary.Write(file, binary.LittleEndian, []uint32{2, uint32(12 + 8 + len(document) + 8 + len(bin))})
+	binary.Write(file, binary.LittleEndian, uint32(len(document)))
+	file.WriteString("JSON")
+	file.Write(document)
+	binary.Write(file, binary.LittleEndian, uint32(len(bin)))
+	file.WriteString("BIN\x00")
+	file.Write(bin)
+	return file.Bytes()
+}
+
+func TestGLTFParse(t *testing.T) {
+	dataURI := "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(gltfTriangleBuffer())
+	files := map[string][]byte{
+		"gltf": gltfTriangle(dataURI, nil),
+		"glb":  glb(gltfTriangle("", nil), gltfTriangleBuffer()),
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			model, err := (&GLTFFileReader{Path: "model." + name}).Parse(bytes.NewReader(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(model.Meshes) != 1 {
+				t.Fatalf("got %d meshes, want 1", len(model.Meshes))
+			}
+			mesh := model.Meshes[0]
+			if err := mesh.Validate(); err != nil {
+				t.Error(err)
+			}
+			if len(mesh.Indices) != 3 || len(mesh.Positions) != 3 {
+				t.Errorf("got %d indices and %d positions, want 3 each", len(mesh.Indices), len(mesh.Positions))
+			}
+			if mesh.Positions[1].X != 1 || mesh.Positions[2].Y != 1 {
+				t.Errorf("wrong positions %v", mesh.Positions)
+			}
+
+			// missing normals are flat
+			for _, normal := range mesh.Normals {
+				if normal.Z != 1 {
+					t.Errorf("got normal %v, want {0 0 1}", normal)
+				}
+			}
+
+			material := mesh.Submeshes[0].Material
+			if material.Name != "red" || material.Diffuse.X != 1 || material.Diffuse.Y != 0 || material.Metallic != 0.5 {
+				t.Errorf("wrong material %+v", material)
+			}
+
+			if len(model.Roots) != 1 || model.Roots[0] != 0 {
+				t.Errorf("got roots %v, want [0]", model.Roots)
+			}
+			child := model.Nodes[1]
+			if child.Parent != 0 || child.Mesh != 0 || child.Scale[0] != 2 {
+				t.Errorf("wrong child node %+v", child)
+			}
+			if model.Nodes[0].Translation[2] != 3 {
+				t.Errorf("wrong root translation %v", model.Nodes[0].Translation)
+			}
+		})
+	}
+}
+
+func TestGLTFErrors(t *testing.T) {
+	dataURI := "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(gltfTriangleBuffer())
+
+	tests := []struct {
+		name   string
+		change func(doc map[string]interface{})
+		err    string
+	}{
+		{"version", func(doc map[string]interface{}) {
+			doc["asset"] = map[string]interface{}{"version": "1.0"}
+		}, "unsupported version"},
+		{"required extension", func(doc map[string]interface{}) {
+			doc["extensionsRequired"] = []string{"KHR_draco_mesh_compression"}
+		}, "required extensions"},
+		{"mesh out of range", func(doc map[string]interface{}) {
+			doc["nodes"].([]interface{})[1].(map[string]interface{})["mesh"] = 5
+		}, "mesh 5 out of range"},
+		{"cycle", func(doc map[string]interface{}) {
+			doc["nodes"].([]interface{})[1].(map[string]interface{})["children"] = []int{0}
+		}, "part of a cycle"},
+		{"accessor exceeds view", func(doc map[string]interface{}) {
+			doc["accessors"].([]interface{})[0].(map[string]interface{})["count"] = 4
+		}, "exceeds its buffer view"},
+		{"negative count", func(doc map[string]interface{}) {
+			doc["accessors"].([]interface{})[0].(map[string]interface{})["count"] = -1
+		}, "negative count"},
+		{"negative count without view", func(doc map[string]interface{}) {
+			accessor := doc["accessors"].([]interface{})[0].(map[string]interface{})
+			delete(accessor, "bufferView")
+			accessor["count"] = -1
+		}, "negative count"},
+		{"negative index count", func(doc map[string]interface{}) {
+			doc["accessors"].([]interface{})[1].(map[string]interface{})["count"] = -1
+		}, "negative count"},
+		{"negative byte offset", func(doc map[string]interface{}) {
+			accessor := doc["accessors"].([]interface{})[0].(map[string]interface{})
+			accessor["byteOffset"] = -4
+			accessor["count"] = 0
+		}, "negative byte offset"},
+		{"byte offset out of range", func(doc map[string]interface{}) {
+			accessor := doc["accessors"].([]interface{})[0].(map[string]interface{})
+			accessor["byteOffset"] = 100
+			accessor["count"] = 0
+		}, "exceeds its buffer view"},
+		{"huge count", func(doc map[string]interface{}) {
+			doc["accessors"].([]interface{})[0].(map[string]interface{})["count"] = 1 << 61
+		}, "exceeds its buffer view"},
+		{"no positions", func(doc map[string]interface{}) {
+			primitive := doc["meshes"].([]interface{})[0].(map[string]interface{})["primitives"].([]interface{})[0]
+			primitive.(map[string]interface{})["attributes"] = map[string]int{}
+		}, "has no positions"},
+	}
+
+	for _, test := range tests {
+		_, err := (&GLTFFileReader{}).Parse(bytes.NewReader(gltfTriangle(dataURI, test.change)))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.err)
+		}
+	}
+}
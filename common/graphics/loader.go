@@ -0,0 +1,244 @@
+package graphics
+
+import (
+	"gitlocal/gome"
+	"image"
+	"runtime"
+	"sync"
+)
+
+// A loadResult is the decoded data of an asset loaded in the background.
+type loadResult struct {
+	asset interface{} // *MeshAsset or *TextureAsset
+	data  interface{} // *Mesh or *image.RGBA
+	err   error
+
+	// the generation of the loader the load was started in
+	generation int
+}
+
+// A loader decodes files on worker goroutines. The results get collected
+// until they are uploaded to the GPU by AssetManager.Poll.
+type loader struct {
+	workers  chan struct{}
+	mutex    sync.Mutex
+	finished []loadResult
+
+	// progress of the current batch of assets
+	loaded int
+	total  int
+
+	// generation counts the resets, so results of loads started before the
+	// last one can be told apart
+	generation int
+}
+
+// start decodes a file on a worker goroutine.
+func (l *loader) start(asset interface{}, decode func() (interface{}, error)) {
+	if l.workers == nil {
+		l.workers = make(chan struct{}, runtime.NumCPU())
+	}
+	l.total++
+	generation := l.generation
+
+	go func() {
+		// limit the number of files decoded at the same time
+		l.workers <- struct{}{}
+		data, err := decode()
+		<-l.workers
+
+		l.mutex.Lock()
+		l.finished = append(l.finished, loadResult{asset: asset, data: data, err: err, generation: generation})
+		l.mutex.Unlock()
+	}()
+}
+
+// reset discards all finished results and the progress. Loads that are still
+// running finish in the background, but their results get discarded as well.
+func (l *loader) reset() {
+	l.take(0)
+	l.loaded = 0
+	l.total = 0
+	l.generation++
+}
+
+// take removes up to n finished results. If n is 0, all are taken.
+func (l *loader) take(n int) []loadResult {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if n <= 0 || n > len(l.finished) {
+		n = len(l.finished)
+	}
+
+	results := l.finished[:n:n]
+	l.finished = l.finished[n:]
+	return results
+}
+
+// MeshAsync works like Mesh, but reads the file in the background. Until the
+// mesh is uploaded by Poll, its state is AssetLoading and it shows the
+// placeholder mesh.
+func (am *AssetManager) MeshAsync(path string) *MeshAsset {
+	key := assetKey(path)
+	if mesh, ok := am.meshes[key]; ok {
+		mesh.refs++
+		return mesh
+	}
+
+	gome.Log.Debug(gome.CategoryAssets, "loading mesh in background", "path", path)
+
+	mesh := &MeshAsset{
+		asset: asset{path: key, refs: 1, manager: am, state: AssetLoading, modTime: modTime(key)},
+	}
+	mesh.setFallback(am.PlaceholderMesh())
+	am.meshes[key] = mesh
+
+	am.loader.start(mesh, func() (interface{}, error) {
+		file, err := gome.Files.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		reader := &OBJFileReader{Dir: assetDir(key)}
+		return reader.Parse(file)
+	})
+
+	return mesh
+}
+
+// TextureAsync works like Texture, but decodes the image in the background.
+// Until the texture is uploaded by Poll, its state is AssetLoading and its ID
+// is the one of a plain grey texture.
+func (am *AssetManager) TextureAsync(path string) *TextureAsset {
+	key := assetKey(path)
+	if texture, ok := am.textures[key]; ok {
+		texture.refs++
+		return texture
+	}
+
+	gome.Log.Debug(gome.CategoryAssets, "loading texture in background", "path", path)
+
+	texture := &TextureAsset{
+		asset: asset{path: key, refs: 1, manager: am, state: AssetLoading, modTime: modTime(key)},
+	}
+	texture.setFallback(am.loadingTexture())
+	am.textures[key] = texture
+
+	am.loader.start(texture, func() (interface{}, error) {
+		file, err := gome.Files.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return decodeImage(file)
+	})
+
+	return texture
+}
+
+// Loading returns true if there are assets loading in the background.
+func (am *AssetManager) Loading() bool {
+	return am.loader.loaded < am.loader.total
+}
+
+// Poll uploads assets that finished loading in the background to the GPU. It
+// has to be called regularly (e.g. every frame) on the thread owning the OpenGL
+// context. A LoadProgressMessage is sent for every finished asset.
+//
+// Failed assets are passed to gome.HandleError. They keep showing the
+// placeholder mesh or texture, and are removed from the cache, so loading
+// their path again retries them.
+//
+// If hot reloading is enabled, Poll also reloads changed assets.
+func (am *AssetManager) Poll() {
+	am.checkReload()
+
+	for _, result := range am.loader.take(am.UploadsPerFrame) {
+		// the load was started before the manager got cleared
+		if result.generation != am.loader.generation {
+			continue
+		}
+
+		var path string
+
+		switch asset := result.asset.(type) {
+		case *MeshAsset:
+			path = asset.path
+			am.finishMesh(asset, result)
+		case *TextureAsset:
+			path = asset.path
+			am.finishTexture(asset, result)
+		}
+
+		am.loader.loaded++
+		gome.MailBox.Send(gome.LoadProgressMessage{
+			Path:   path,
+			Err:    result.err,
+			Loaded: am.loader.loaded,
+			Total:  am.loader.total,
+		})
+
+		// start counting anew with the next batch
+		if am.loader.loaded == am.loader.total {
+			am.loader.loaded = 0
+			am.loader.total = 0
+		}
+	}
+}
+
+// finishMesh uploads a mesh loaded in the background.
+func (am *AssetManager) finishMesh(mesh *MeshAsset, result loadResult) {
+	// the mesh was released while loading
+	if mesh.refs == 0 {
+		return
+	}
+
+	if result.err != nil {
+		mesh.state = AssetFailed
+		mesh.err = &gome.AssetError{Path: mesh.path, Err: result.err}
+		am.forgetMesh(mesh)
+		handleAsyncError(mesh.err)
+		return
+	}
+
+	data := result.data.(*Mesh)
+	mesh.free()
+	mesh.upload(data)
+	mesh.state = AssetReady
+	mesh.Parts, _ = am.loadParts(data.Submeshes, true)
+}
+
+// finishTexture uploads a texture loaded in the background.
+func (am *AssetManager) finishTexture(texture *TextureAsset, result loadResult) {
+	// the texture was released while loading
+	if texture.refs == 0 {
+		return
+	}
+
+	if result.err != nil {
+		texture.state = AssetFailed
+		texture.err = &gome.AssetError{Path: texture.path, Err: result.err}
+		texture.setFallback(am.Placeholder())
+		am.forgetTexture(texture)
+		handleAsyncError(texture.err)
+		return
+	}
+
+	texture.fallback.Release()
+	texture.fallback = nil
+	texture.ID = uploadTexture(result.data.(*image.RGBA))
+	texture.state = AssetReady
+}
+
+// handleAsyncError passes an error of a background load to gome.HandleError.
+// There is no caller to return it to, so unhandled errors get logged.
+func handleAsyncError(err error) {
+	if herr := gome.HandleError(err); herr != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not load asset", "err", herr)
+	} else {
+		gome.Log.Warn(gome.CategoryAssets, "using placeholder", "err", err)
+	}
+}
@@ -0,0 +1,43 @@
+package graphics
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLoaderReset checks that loads finishing after a reset are told apart
+// from the ones started after it.
+func TestLoaderReset(t *testing.T) {
+	l := &loader{}
+	release := make(chan struct{})
+	l.start("stale", func() (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+
+	l.reset()
+	if l.total != 0 {
+		t.Fatalf("got total %d after reset, want 0", l.total)
+	}
+	l.start("current", func() (interface{}, error) { return nil, nil })
+	close(release)
+
+	results := []loadResult{}
+	for deadline := time.Now().Add(5 * time.Second); len(results) < 2 && time.Now().Before(deadline); {
+		results = append(results, l.take(0)...)
+		time.Sleep(time.Millisecond)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	for _, result := range results {
+		current := result.generation == l.generation
+		if current != (result.asset == "current") {
+			t.Errorf("%v counts as current: %v", result.asset, current)
+		}
+	}
+	if l.total != 1 {
+		t.Errorf("got total %d, want 1", l.total)
+	}
+}
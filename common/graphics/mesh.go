@@ -0,0 +1,467 @@
+package graphics
+
+import (
+	"errors"
+	"fmt"
+	"gitlocal/gome"
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// MESH_VERTEX_LAYOUT is the layout meshes are uploaded with: position, uv,
+// normal and tangent.
+var MESH_VERTEX_LAYOUT = VertexLayout{layout: []ElementType{FVEC3, FVEC2, FVEC3, FVEC4}}
+
+// SKINNED_VERTEX_LAYOUT is the layout of skinned meshes, which additionally
+// have four joint indices and four joint weights.
+var SKINNED_VERTEX_LAYOUT = VertexLayout{layout: []ElementType{FVEC3, FVEC2, FVEC3, FVEC4, FVEC4, FVEC4}}
+
+/*
+	Mesh
+*/
+
+// A Mesh is geometry in main memory, as produced by the model loaders. It can
+// be inspected and processed without an OpenGL context, and uploaded to the
+// GPU with Upload.
+//
+// Every vertex attribute has one element per position. Only Positions are
+// required; missing attributes are nil and get uploaded as zeros.
+type Mesh struct {
+	Positions []gome.FloatVector3
+	Normals   []gome.FloatVector3
+	UVs       []gome.FloatVector2
+
+	// Tangents point along the u axis of the uvs. W is the handedness of the
+	// bitangent (1 or -1).
+	Tangents []gome.FloatVector4
+
+	// Joints and Weights are the indices and weights of up to four joints
+	// moving each vertex, in X, Y, Z, W order. They are nil if the mesh is not
+	// skinned.
+	Joints  []gome.FloatVector4
+	Weights []gome.FloatVector4
+
+	// Indices are the vertices of the triangles, three per triangle.
+	Indices []uint32
+
+	// Submeshes divide the indices into ranges drawn with the same material.
+	Submeshes []Submesh
+
+	// Groups divide the indices into ranges by object, group, material and
+	// smoothing group. Not every loader sets them.
+	Groups []MeshGroup
+
+	// Materials are all materials of the material libraries by name.
+	Materials map[string]*Material
+
+	// Libraries are the paths of the material libraries the file names,
+	// including missing ones.
+	Libraries []string
+
+	// Images are encoded images embedded in the model file, by the texture
+	// path the materials use for them.
+	Images map[string][]byte
+
+	// Bounds and Sphere are the bounding box and sphere of the positions, see
+	// UpdateBounds.
+	Bounds gome.AABB
+	Sphere gome.Sphere
+}
+
+// A Submesh is a range of indices of a mesh drawn with one material.
+type Submesh struct {
+	// First is the first index of the submesh, Count the number of indices.
+	First, Count int
+
+	Material *Material
+}
+
+// A MeshGroup is a range of indices of a mesh sharing the same properties.
+type MeshGroup struct {
+	Object    string
+	Group     string
+	Material  string
+	Smoothing int
+
+	// First is the first index of the group, Count the number of indices.
+	First, Count int
+}
+
+// VertexCount returns the number of vertices.
+func (m *Mesh) VertexCount() int { return len(m.Positions) }
+
+// Skinned returns true if the vertices have joints and weights.
+func (m *Mesh) Skinned() bool { return m.Joints != nil }
+
+// UpdateBounds recalculates the bounding box and sphere from the positions.
+func (m *Mesh) UpdateBounds() {
+	m.Bounds = gome.NewAABB(m.Positions...)
+	m.Sphere = gome.NewSphere(m.Positions...)
+}
+
+// Validate checks if all attributes have one element per vertex, and if all
+// indices and submeshes are in range.
+func (m *Mesh) Validate() error {
+	count := len(m.Positions)
+	attributes := []struct {
+		name   string
+		length int
+		isNil  bool
+	}{
+		{"normals", len(m.Normals), m.Normals == nil},
+		{"uvs", len(m.UVs), m.UVs == nil},
+		{"tangents", len(m.Tangents), m.Tangents == nil},
+		{"joints", len(m.Joints), m.Joints == nil},
+		{"weights", len(m.Weights), m.Weights == nil},
+	}
+	for _, attribute := range attributes {
+		if !attribute.isNil && attribute.length != count {
+			return fmt.Errorf("mesh: %d %s for %d vertices", attribute.length, attribute.name, count)
+		}
+	}
+	if (m.Joints == nil) != (m.Weights == nil) {
+		return fmt.Errorf("mesh: joints and weights must be set together")
+	}
+
+	if len(m.Indices)%3 != 0 {
+		return fmt.Errorf("mesh: %d indices don't form triangles", len(m.Indices))
+	}
+	for i, index := range m.Indices {
+		if int(index) >= count {
+			return fmt.Errorf("mesh: index %d is %d, but there are %d vertices", i, index, count)
+		}
+	}
+
+	for i, submesh := range m.Submeshes {
+		if submesh.First < 0 || submesh.Count < 0 || submesh.First+submesh.Count > len(m.Indices) {
+			return fmt.Errorf("mesh: submesh %d out of range", i)
+		}
+		if submesh.Material == nil {
+			return fmt.Errorf("mesh: submesh %d has no material", i)
+		}
+	}
+
+	return nil
+}
+
+// Layout returns the vertex layout the mesh gets uploaded with.
+func (m *Mesh) Layout() VertexLayout {
+	if m.Skinned() {
+		return SKINNED_VERTEX_LAYOUT
+	}
+	return MESH_VERTEX_LAYOUT
+}
+
+// Interleave returns the vertex data in the layout of the mesh.
+func (m *Mesh) Interleave() []float32 {
+	skinned := m.Skinned()
+	layout := m.Layout()
+	stride := layout.stride() / 4
+
+	vertices := make([]float32, 0, len(m.Positions)*stride)
+	for i, position := range m.Positions {
+		vertices = append(vertices, position.X, position.Y, position.Z)
+
+		uv := gome.FloatVector2{}
+		if m.UVs != nil {
+			uv = m.UVs[i]
+		}
+		vertices = append(vertices, uv.X, uv.Y)
+
+		normal := gome.FloatVector3{}
+		if m.Normals != nil {
+			normal = m.Normals[i]
+		}
+		vertices = append(vertices, normal.X, normal.Y, normal.Z)
+
+		tangent := gome.FloatVector4{}
+		if m.Tangents != nil {
+			tangent = m.Tangents[i]
+		}
+		vertices = append(vertices, tangent.X, tangent.Y, tangent.Z, tangent.W)
+
+		if skinned {
+			joints, weights := m.Joints[i], m.Weights[i]
+			vertices = append(vertices,
+				joints.X, joints.Y, joints.Z, joints.W,
+				weights.X, weights.Y, weights.Z, weights.W,
+			)
+		}
+	}
+
+	return vertices
+}
+
+// Upload uploads the mesh to a new vertex array. It must be called on the
+// thread owning the OpenGL context.
+func (m *Mesh) Upload() (va VertexArray) {
+	va.SetLayout(m.Layout())
+	va.SetData(m.Interleave())
+	va.SetIndexData(m.Indices)
+
+	return
+}
+
+/*
+	Processing
+*/
+
+// vec3 and fromVec3 convert between gome and mathgl vectors.
+func vec3(v gome.FloatVector3) mgl32.Vec3 { return mgl32.Vec3{v.X, v.Y, v.Z} }
+
+func fromVec3(v mgl32.Vec3) gome.FloatVector3 {
+	return gome.FloatVector3{X: v[0], Y: v[1], Z: v[2]}
+}
+
+// normalizeOr returns the normalized vector, or fallback if it has no length.
+func normalizeOr(v, fallback mgl32.Vec3) mgl32.Vec3 {
+	if length := v.Len(); length > 1e-12 {
+		return v.Mul(1 / length)
+	}
+	return fallback
+}
+
+// faceNormal returns the normal of a triangle, scaled by twice its area.
+func (m *Mesh) faceNormal(triangle int) mgl32.Vec3 {
+	a := vec3(m.Positions[m.Indices[triangle*3]])
+	b := vec3(m.Positions[m.Indices[triangle*3+1]])
+	c := vec3(m.Positions[m.Indices[triangle*3+2]])
+
+	return b.Sub(a).Cross(c.Sub(a))
+}
+
+// SmoothNormals sets the normal of every vertex to the average of the normals
+// of the triangles around it, weighted by the angle of the triangles at the
+// vertex, so the result doesn't depend on how faces were triangulated. Vertices
+// at the same position (e.g. at uv seams) get the same normal.
+func (m *Mesh) SmoothNormals() { m.smoothNormals(nil, nil) }
+
+// smoothKey is a position in a smoothing group.
+type smoothKey struct {
+	position gome.FloatVector3
+	group    int
+}
+
+// smoothNormals works like SmoothNormals, but only vertices of the same
+// smoothing group (one per vertex) share normals. All vertices are in the
+// same group if groups is nil. If missing is not nil, only the normals of the
+// vertices marked in it are replaced.
+func (m *Mesh) smoothNormals(groups []int, missing []bool) {
+	group := func(vertex uint32) int {
+		if groups == nil {
+			return 0
+		}
+		return groups[vertex]
+	}
+
+	sums := make(map[smoothKey]mgl32.Vec3, len(m.Positions))
+	for triangle := 0; triangle < len(m.Indices)/3; triangle++ {
+		normal := normalizeOr(m.faceNormal(triangle), mgl32.Vec3{})
+		corners := m.Indices[triangle*3 : triangle*3+3]
+
+		for i, index := range corners {
+			position := vec3(m.Positions[index])
+			a := normalizeOr(vec3(m.Positions[corners[(i+1)%3]]).Sub(position), mgl32.Vec3{})
+			b := normalizeOr(vec3(m.Positions[corners[(i+2)%3]]).Sub(position), mgl32.Vec3{})
+			angle := float32(math.Acos(float64(mgl32.Clamp(a.Dot(b), -1, 1))))
+
+			key := smoothKey{m.Positions[index], group(index)}
+			sums[key] = sums[key].Add(normal.Mul(angle))
+		}
+	}
+
+	if missing == nil || len(m.Normals) != len(m.Positions) {
+		m.Normals = make([]gome.FloatVector3, len(m.Positions))
+	}
+	for i, position := range m.Positions {
+		if missing != nil && !missing[i] {
+			continue
+		}
+
+		key := smoothKey{position, group(uint32(i))}
+		m.Normals[i] = fromVec3(normalizeOr(sums[key], mgl32.Vec3{0, 1, 0}))
+	}
+}
+
+// FlatNormals gives every triangle its own vertices with the normal of the
+// triangle, so the mesh looks faceted. The number of vertices becomes the
+// number of indices.
+func (m *Mesh) FlatNormals() {
+	flat := &Mesh{Indices: make([]uint32, len(m.Indices))}
+	for i, index := range m.Indices {
+		flat.Indices[i] = uint32(i)
+		flat.Positions = append(flat.Positions, m.Positions[index])
+		if m.UVs != nil {
+			flat.UVs = append(flat.UVs, m.UVs[index])
+		}
+		if m.Tangents != nil {
+			flat.Tangents = append(flat.Tangents, m.Tangents[index])
+		}
+		if m.Joints != nil {
+			flat.Joints = append(flat.Joints, m.Joints[index])
+			flat.Weights = append(flat.Weights, m.Weights[index])
+		}
+	}
+
+	flat.Normals = make([]gome.FloatVector3, len(flat.Positions))
+	for triangle := 0; triangle < len(flat.Indices)/3; triangle++ {
+		normal := fromVec3(normalizeOr(flat.faceNormal(triangle), mgl32.Vec3{0, 1, 0}))
+		flat.Normals[triangle*3] = normal
+		flat.Normals[triangle*3+1] = normal
+		flat.Normals[triangle*3+2] = normal
+	}
+
+	m.Positions, m.Normals, m.UVs, m.Tangents = flat.Positions, flat.Normals, flat.UVs, flat.Tangents
+	m.Joints, m.Weights, m.Indices = flat.Joints, flat.Weights, flat.Indices
+}
+
+// GenerateTangents calculates the tangents from the uvs, the way MikkTSpace
+// does for most meshes: the uv directions of the triangles around a vertex are
+// accumulated, orthogonalized against its normal, and the handedness of the
+// bitangent is stored in W. Smooth normals are generated if there are none.
+func (m *Mesh) GenerateTangents() error {
+	if m.UVs == nil {
+		return errors.New("mesh: tangents need uvs")
+	}
+	if m.Normals == nil {
+		m.SmoothNormals()
+	}
+
+	tangents := make([]mgl32.Vec3, len(m.Positions))
+	bitangents := make([]mgl32.Vec3, len(m.Positions))
+	for triangle := 0; triangle < len(m.Indices)/3; triangle++ {
+		i0, i1, i2 := m.Indices[triangle*3], m.Indices[triangle*3+1], m.Indices[triangle*3+2]
+
+		e1 := vec3(m.Positions[i1]).Sub(vec3(m.Positions[i0]))
+		e2 := vec3(m.Positions[i2]).Sub(vec3(m.Positions[i0]))
+		du1, dv1 := m.UVs[i1].X-m.UVs[i0].X, m.UVs[i1].Y-m.UVs[i0].Y
+		du2, dv2 := m.UVs[i2].X-m.UVs[i0].X, m.UVs[i2].Y-m.UVs[i0].Y
+
+		det := du1*dv2 - du2*dv1
+		if abs32(det) < 1e-12 {
+			continue // the uvs of the triangle are degenerate
+		}
+		r := 1 / det
+
+		tangent := e1.Mul(dv2).Sub(e2.Mul(dv1)).Mul(r)
+		bitangent := e2.Mul(du1).Sub(e1.Mul(du2)).Mul(r)
+		for _, index := range []uint32{i0, i1, i2} {
+			tangents[index] = tangents[index].Add(tangent)
+			bitangents[index] = bitangents[index].Add(bitangent)
+		}
+	}
+
+	m.Tangents = make([]gome.FloatVector4, len(m.Positions))
+	for i := range m.Positions {
+		normal := vec3(m.Normals[i])
+
+		// Gram-Schmidt orthogonalization, with any perpendicular vector as fallback
+		tangent := tangents[i].Sub(normal.Mul(normal.Dot(tangents[i])))
+		fallback := normal.Cross(mgl32.Vec3{0, 0, 1})
+		if fallback.Len() < 1e-6 {
+			fallback = normal.Cross(mgl32.Vec3{0, 1, 0})
+		}
+		tangent = normalizeOr(tangent, normalizeOr(fallback, mgl32.Vec3{1, 0, 0}))
+
+		handedness := float32(1)
+		if normal.Cross(tangent).Dot(bitangents[i]) < 0 {
+			handedness = -1
+		}
+
+		m.Tangents[i] = gome.FloatVector4{X: tangent[0], Y: tangent[1], Z: tangent[2], W: handedness}
+	}
+
+	return nil
+}
+
+// weldKey is a vertex with all attributes rounded to a grid.
+type weldKey struct {
+	position, normal [3]int64
+	uv               [2]int64
+	tangent          [4]int64
+	joints, weights  [4]int64
+}
+
+// Weld merges vertices whose attributes are all equal after rounding them to
+// multiples of epsilon, and returns the number of removed vertices. Vertices
+// closer than epsilon may still stay apart if they round differently.
+func (m *Mesh) Weld(epsilon float32) int {
+	if epsilon <= 0 {
+		epsilon = 1e-6
+	}
+	round := func(values ...float32) (rounded [4]int64) {
+		for i, value := range values {
+			rounded[i] = int64(math.Round(float64(value / epsilon)))
+		}
+		return
+	}
+
+	welded := &Mesh{}
+	keys := make(map[weldKey]uint32, len(m.Positions))
+	remap := make([]uint32, len(m.Positions))
+	for i, position := range m.Positions {
+		key := weldKey{}
+		p := round(position.X, position.Y, position.Z)
+		copy(key.position[:], p[:3])
+		if m.Normals != nil {
+			n := round(m.Normals[i].X, m.Normals[i].Y, m.Normals[i].Z)
+			copy(key.normal[:], n[:3])
+		}
+		if m.UVs != nil {
+			uv := round(m.UVs[i].X, m.UVs[i].Y)
+			copy(key.uv[:], uv[:2])
+		}
+		if m.Tangents != nil {
+			key.tangent = round(m.Tangents[i].X, m.Tangents[i].Y, m.Tangents[i].Z, m.Tangents[i].W)
+		}
+		if m.Joints != nil {
+			key.joints = round(m.Joints[i].X, m.Joints[i].Y, m.Joints[i].Z, m.Joints[i].W)
+			key.weights = round(m.Weights[i].X, m.Weights[i].Y, m.Weights[i].Z, m.Weights[i].W)
+		}
+
+		if index, ok := keys[key]; ok {
+			remap[i] = index
+			continue
+		}
+
+		index := uint32(len(welded.Positions))
+		keys[key] = index
+		remap[i] = index
+
+		welded.Positions = append(welded.Positions, position)
+		if m.Normals != nil {
+			welded.Normals = append(welded.Normals, m.Normals[i])
+		}
+		if m.UVs != nil {
+			welded.UVs = append(welded.UVs, m.UVs[i])
+		}
+		if m.Tangents != nil {
+			welded.Tangents = append(welded.Tangents, m.Tangents[i])
+		}
+		if m.Joints != nil {
+			welded.Joints = append(welded.Joints, m.Joints[i])
+			welded.Weights = append(welded.Weights, m.Weights[i])
+		}
+	}
+
+	for i, index := range m.Indices {
+		m.Indices[i] = remap[index]
+	}
+
+	removed := len(m.Positions) - len(welded.Positions)
+	m.Positions, m.Normals, m.UVs, m.Tangents = welded.Positions, welded.Normals, welded.UVs, welded.Tangents
+	m.Joints, m.Weights = welded.Joints, welded.Weights
+
+	return removed
+}
+
+// needsTangents returns true if a material of the mesh has a bump or normal map.
+func (m *Mesh) needsTangents() bool {
+	for _, submesh := range m.Submeshes {
+		if submesh.Material != nil && (submesh.Material.BumpMap != "" || submesh.Material.NormalMap != "") {
+			return true
+		}
+	}
+	return false
+}
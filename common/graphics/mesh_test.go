@@ -0,0 +1,209 @@
+package graphics
+
+import (
+	"gitlocal/gome"
+	"strings"
+	"testing"
+)
+
+// quadMesh returns a unit quad in the XY plane facing +Z, made of two
+// triangles sharing two vertices.
+func quadMesh() *Mesh {
+	return &Mesh{
+		Positions: []gome.FloatVector3{{X: 0}, {X: 1}, {X: 1, Y: 1}, {Y: 1}},
+		UVs:       []gome.FloatVector2{{X: 0}, {X: 1}, {X: 1, Y: 1}, {Y: 1}},
+		Indices:   []uint32{0, 1, 2, 0, 2, 3},
+	}
+}
+
+func TestMeshValidate(t *testing.T) {
+	material := NewMaterial("")
+	tests := []struct {
+		name   string
+		change func(m *Mesh)
+		err    string
+	}{
+		{"valid", func(m *Mesh) {}, ""},
+		{"valid submesh", func(m *Mesh) { m.Submeshes = []Submesh{{First: 3, Count: 3, Material: material}} }, ""},
+		{"too few uvs", func(m *Mesh) { m.UVs = m.UVs[:3] }, "3 uvs for 4 vertices"},
+		{"too many normals", func(m *Mesh) { m.Normals = make([]gome.FloatVector3, 5) }, "5 normals"},
+		{"joints without weights", func(m *Mesh) { m.Joints = make([]gome.FloatVector4, 4) }, "joints and weights"},
+		{"no triangles", func(m *Mesh) { m.Indices = m.Indices[:4] }, "don't form triangles"},
+		{"index out of range", func(m *Mesh) { m.Indices[5] = 4 }, "index 5 is 4"},
+		{"submesh out of range", func(m *Mesh) { m.Submeshes = []Submesh{{First: 3, Count: 6, Material: material}} }, "submesh 0 out of range"},
+		{"submesh without material", func(m *Mesh) { m.Submeshes = []Submesh{{Count: 3}} }, "no material"},
+	}
+
+	for _, test := range tests {
+		mesh := quadMesh()
+		test.change(mesh)
+
+		err := mesh.Validate()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestMeshInterleave(t *testing.T) {
+	mesh := quadMesh()
+	mesh.Normals = []gome.FloatVector3{{Z: 1}, {Z: 1}, {Z: 1}, {Z: 1}}
+
+	// position, uv, normal and tangent, with the missing tangents as zeros
+	vertices := mesh.Interleave()
+	if len(vertices) != 4*12 {
+		t.Fatalf("got %d floats, want %d", len(vertices), 4*12)
+	}
+	want := []float32{1, 1, 0, 1, 1, 0, 0, 1, 0, 0, 0, 0}
+	for i, value := range want {
+		if vertices[2*12+i] != value {
+			t.Errorf("vertex 2: got %v, want %v", vertices[2*12:3*12], want)
+			break
+		}
+	}
+
+	// skinned meshes have joints and weights after that
+	mesh.Joints = []gome.FloatVector4{{X: 1}, {X: 2}, {X: 3}, {W: 1, X: 4}}
+	mesh.Weights = []gome.FloatVector4{{X: 1}, {X: 1}, {X: 1}, {X: 0.5, W: 0.5}}
+	vertices = mesh.Interleave()
+	layout := mesh.Layout()
+	if len(vertices) != 4*20 || layout.stride() != 20*4 {
+		t.Fatalf("got %d floats, want %d", len(vertices), 4*20)
+	}
+	if skin := vertices[3*20+12:]; skin[0] != 4 || skin[3] != 1 || skin[4] != 0.5 || skin[7] != 0.5 {
+		t.Errorf("vertex 3: got joints and weights %v", skin)
+	}
+}
+
+// near checks if two vectors are equal up to rounding errors.
+func near(a, b gome.FloatVector3) bool {
+	return abs32(a.X-b.X) < 1e-4 && abs32(a.Y-b.Y) < 1e-4 && abs32(a.Z-b.Z) < 1e-4
+}
+
+// roofMesh returns two triangles meeting at a right angle along the Y axis,
+// like a roof, sharing the vertices on the ridge.
+func roofMesh() *Mesh {
+	return &Mesh{
+		Positions: []gome.FloatVector3{{Y: 0}, {Y: 1}, {X: -1, Z: -1}, {X: 1, Z: -1}},
+		Indices:   []uint32{0, 1, 2, 1, 0, 3},
+	}
+}
+
+func TestMeshNormals(t *testing.T) {
+	// both sides of the roof meet at the ridge, where smooth normals point up
+	mesh := roofMesh()
+	mesh.SmoothNormals()
+	if len(mesh.Normals) != 4 || !near(mesh.Normals[0], gome.FloatVector3{Z: 1}) || !near(mesh.Normals[1], gome.FloatVector3{Z: 1}) {
+		t.Errorf("got smooth normals %v", mesh.Normals)
+	}
+
+	// vertices at the same position share their normal
+	split := roofMesh()
+	split.Positions = append(split.Positions, split.Positions[0], split.Positions[1])
+	split.Indices = []uint32{0, 1, 2, 5, 4, 3}
+	split.SmoothNormals()
+	if !near(split.Normals[0], split.Normals[4]) || !near(split.Normals[0], gome.FloatVector3{Z: 1}) {
+		t.Errorf("got smooth normals %v at a seam", split.Normals)
+	}
+
+	// flat normals split the ridge
+	mesh = roofMesh()
+	mesh.UVs = make([]gome.FloatVector2, 4)
+	mesh.FlatNormals()
+	if mesh.VertexCount() != 6 || len(mesh.UVs) != 6 || mesh.Validate() != nil {
+		t.Fatalf("got %d vertices and %d uvs, want 6", mesh.VertexCount(), len(mesh.UVs))
+	}
+	left := gome.FloatVector3{X: -0.70710677, Z: 0.70710677}
+	right := gome.FloatVector3{X: 0.70710677, Z: 0.70710677}
+	for i, normal := range mesh.Normals {
+		want := left
+		if i >= 3 {
+			want = right
+		}
+		if !near(normal, want) {
+			t.Errorf("flat normal %d is %v, want %v", i, normal, want)
+		}
+	}
+}
+
+func TestMeshGenerateTangents(t *testing.T) {
+	tests := []struct {
+		name       string
+		uvs        []gome.FloatVector2
+		tangent    gome.FloatVector3
+		handedness float32
+	}{
+		{"u along x", []gome.FloatVector2{{X: 0}, {X: 1}, {X: 1, Y: 1}, {Y: 1}}, gome.FloatVector3{X: 1}, 1},
+		{"u along y", []gome.FloatVector2{{Y: 0}, {Y: 1}, {X: 1, Y: 1}, {X: 1}}, gome.FloatVector3{Y: 1}, -1},
+		{"mirrored", []gome.FloatVector2{{X: 1}, {X: 0}, {Y: 1}, {X: 1, Y: 1}}, gome.FloatVector3{X: -1}, -1},
+	}
+
+	for _, test := range tests {
+		mesh := quadMesh()
+		mesh.UVs = test.uvs
+		if err := mesh.GenerateTangents(); err != nil {
+			t.Fatal(err)
+		}
+		if len(mesh.Normals) != 4 {
+			t.Errorf("%s: missing normals were not generated", test.name)
+		}
+
+		for i, tangent := range mesh.Tangents {
+			if !near(gome.FloatVector3{X: tangent.X, Y: tangent.Y, Z: tangent.Z}, test.tangent) || tangent.W != test.handedness {
+				t.Errorf("%s: tangent %d is %v, want %v with handedness %v", test.name, i, tangent, test.tangent, test.handedness)
+			}
+		}
+	}
+
+	mesh := quadMesh()
+	mesh.UVs = nil
+	if mesh.GenerateTangents() == nil {
+		t.Error("tangents generated without uvs")
+	}
+}
+
+func TestMeshWeld(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(m *Mesh)
+		epsilon float32
+		removed int
+	}{
+		{"nothing to weld", func(m *Mesh) {}, 0, 0},
+		{"flat normals", func(m *Mesh) { m.FlatNormals() }, 0, 2},
+		{"slightly off", func(m *Mesh) {
+			m.FlatNormals()
+			m.Positions[3].X += 1e-5
+		}, 1e-3, 2},
+		{"too far off", func(m *Mesh) {
+			m.FlatNormals()
+			m.Positions[3].X += 1e-2
+		}, 1e-3, 1},
+		{"different uvs", func(m *Mesh) {
+			m.FlatNormals()
+			m.UVs[3].X = 0.5
+		}, 0, 1},
+	}
+
+	for _, test := range tests {
+		mesh := quadMesh()
+		test.change(mesh)
+		before := mesh.Indices[5]
+		position := mesh.Positions[before]
+
+		if removed := mesh.Weld(test.epsilon); removed != test.removed {
+			t.Errorf("%s: removed %d vertices, want %d", test.name, removed, test.removed)
+		}
+		if err := mesh.Validate(); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+
+		// the indices still lead to the same positions
+		if !near(mesh.Positions[mesh.Indices[5]], position) {
+			t.Errorf("%s: index 5 moved from %v to %v", test.name, position, mesh.Positions[mesh.Indices[5]])
+		}
+	}
+}
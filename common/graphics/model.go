@@ -0,0 +1,220 @@
+package graphics
+
+import (
+	"fmt"
+	"gitlocal/gome"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	Model
+*/
+
+// A Model is the content of a model file: meshes, a hierarchy of nodes placing
+// them, skins and animations. It doesn't touch the GPU, so it can be loaded on
+// any goroutine.
+type Model struct {
+	// Nodes are all nodes of the file. Nodes refer to each other, to meshes and
+	// to skins by their index.
+	Nodes []ModelNode
+
+	// Roots are the nodes of the scene that have no parent.
+	Roots []int
+
+	Meshes     []*Mesh
+	Skins      []ModelSkin
+	Animations []*Animation
+}
+
+// A ModelNode is a node of the hierarchy of a model, with a transform relative
+// to its parent.
+type ModelNode struct {
+	Name string
+
+	// Parent is -1 for root nodes.
+	Parent   int
+	Children []int
+
+	Translation mgl32.Vec3
+	Rotation    mgl32.Quat
+	Scale       mgl32.Vec3
+
+	// Mesh and Skin are -1 if the node has none.
+	Mesh int
+	Skin int
+}
+
+// A ModelSkin lets a mesh follow the nodes of a skeleton.
+type ModelSkin struct {
+	Name string
+
+	// Joints are the nodes of the skeleton.
+	Joints []int
+
+	// InverseBindMatrices transform the mesh into the space of each joint.
+	InverseBindMatrices []mgl32.Mat4
+}
+
+// MaxJoints is the maximum number of joints of a skin the default shader supports.
+const MaxJoints = 64
+
+// LoadModel loads a model file from gome.Files. The format is chosen by the
+// file extension. Supported formats are glTF 2.0 (.gltf and .glb).
+func LoadModel(file string) (*Model, error) {
+	switch ext := strings.ToLower(path.Ext(file)); ext {
+	case ".gltf", ".glb":
+		reader, err := gome.Files.Open(file)
+		if err != nil {
+			return nil, &gome.AssetError{Path: file, Err: err}
+		}
+		defer reader.Close()
+
+		model, err := (&GLTFFileReader{Path: file}).Parse(reader)
+		if err != nil {
+			return nil, &gome.AssetError{Path: file, Err: err}
+		}
+		return model, nil
+	default:
+		return nil, &gome.AssetError{Path: file, Err: fmt.Errorf("unsupported model format %q", ext)}
+	}
+}
+
+/*
+	Animation
+*/
+
+// An AnimationPath is the property of a node an animation channel changes.
+type AnimationPath int
+
+const (
+	AnimateTranslation = AnimationPath(iota)
+	AnimateRotation
+	AnimateScale
+)
+
+// An Interpolation defines how values between keyframes are calculated.
+type Interpolation int
+
+const (
+	InterpolateLinear = Interpolation(iota)
+	InterpolateStep
+	InterpolateCubicSpline
+)
+
+// An Animation changes the transforms of nodes over time.
+type Animation struct {
+	Name     string
+	Channels []AnimationChannel
+
+	// Duration is the time of the last keyframe, in seconds.
+	Duration float32
+}
+
+// An AnimationChannel animates one property of one node.
+type AnimationChannel struct {
+	Node          int
+	Path          AnimationPath
+	Interpolation Interpolation
+
+	// Times are the times of the keyframes in seconds, in increasing order.
+	Times []float32
+
+	// Values are the values of the keyframes, 3 (translation and scale) or
+	// 4 (rotation) per keyframe. With cubic spline interpolation, every
+	// keyframe has an in-tangent, a value and an out-tangent.
+	Values []float32
+}
+
+// components returns the number of floats of one value.
+func (ac *AnimationChannel) components() int {
+	if ac.Path == AnimateRotation {
+		return 4
+	}
+	return 3
+}
+
+// value returns the i-th value of the values, skipping the tangents of cubic
+// splines. Tangents are returned for a part of -1 (in) or 1 (out).
+func (ac *AnimationChannel) value(i, part int) []float32 {
+	n := ac.components()
+	if ac.Interpolation == InterpolateCubicSpline {
+		i = i*3 + 1 + part
+	}
+	return ac.Values[i*n : i*n+n]
+}
+
+// Sample returns the value of the channel at a time in seconds. Times before
+// the first or after the last keyframe return the first or last value.
+func (ac *AnimationChannel) Sample(t float32) []float32 {
+	result := make([]float32, ac.components())
+	if len(ac.Times) == 0 {
+		return result
+	}
+
+	// the keyframe after t
+	next := sort.Search(len(ac.Times), func(i int) bool { return ac.Times[i] > t })
+	if next == 0 {
+		copy(result, ac.value(0, 0))
+		return result
+	}
+	if next == len(ac.Times) {
+		copy(result, ac.value(len(ac.Times)-1, 0))
+		return result
+	}
+
+	prev := next - 1
+	dt := ac.Times[next] - ac.Times[prev]
+	s := (t - ac.Times[prev]) / dt
+
+	switch ac.Interpolation {
+	case InterpolateStep:
+		copy(result, ac.value(prev, 0))
+		return result
+	case InterpolateCubicSpline:
+		// hermite spline
+		s2, s3 := s*s, s*s*s
+		p0, m0 := ac.value(prev, 0), ac.value(prev, 1)
+		p1, m1 := ac.value(next, 0), ac.value(next, -1)
+		for i := range result {
+			result[i] = (2*s3-3*s2+1)*p0[i] + (s3-2*s2+s)*dt*m0[i] + (-2*s3+3*s2)*p1[i] + (s3-s2)*dt*m1[i]
+		}
+		if ac.Path == AnimateRotation {
+			normalize(result)
+		}
+		return result
+	}
+
+	a, b := ac.value(prev, 0), ac.value(next, 0)
+	if ac.Path == AnimateRotation {
+		qa := mgl32.Quat{W: a[3], V: mgl32.Vec3{a[0], a[1], a[2]}}
+		qb := mgl32.Quat{W: b[3], V: mgl32.Vec3{b[0], b[1], b[2]}}
+		q := mgl32.QuatSlerp(qa, qb, s)
+		return []float32{q.V[0], q.V[1], q.V[2], q.W}
+	}
+
+	for i := range result {
+		result[i] = a[i] + (b[i]-a[i])*s
+	}
+	return result
+}
+
+// normalize scales a vector to a length of 1.
+func normalize(v []float32) {
+	length := float32(0)
+	for _, x := range v {
+		length += x * x
+	}
+	if length == 0 {
+		return
+	}
+
+	length = float32(math.Sqrt(float64(length)))
+	for i := range v {
+		v[i] /= length
+	}
+}
@@ -0,0 +1,291 @@
+package graphics
+
+import (
+	"bufio"
+	"fmt"
+	"gitlocal/gome"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+	Material
+*/
+
+// A Material describes how the surface of a mesh looks.
+type Material struct {
+	Name string
+
+	Ambient  gome.FloatVector3
+	Diffuse  gome.FloatVector3
+	Specular gome.FloatVector3
+
+	// Shininess is the specular exponent.
+	Shininess float32
+
+	// Dissolve is the opacity, 1 being fully opaque.
+	Dissolve float32
+
+	// Illumination is the illumination model as defined by the .mtl format.
+	Illumination int
+
+	// PBR makes the material use the metallic-roughness model instead of the
+	// specular one. Diffuse is the albedo then, Specular and Shininess are
+	// ignored.
+	PBR       bool
+	Metallic  float32
+	Roughness float32
+
+	// Emissive is the light the surface emits itself.
+	Emissive gome.FloatVector3
+
+	// Texture file paths, empty if not set.
+	DiffuseMap  string
+	BumpMap     string
+	SpecularMap string
+
+	// NormalMap is a tangent space normal map. The maps of the PBR model are
+	// read from the channels glTF packs them in: occlusion from red, roughness
+	// from green and metallic from blue, so one image can hold all three.
+	NormalMap    string
+	MetallicMap  string
+	RoughnessMap string
+	OcclusionMap string
+	EmissiveMap  string
+}
+
+// Maps returns the paths of all texture maps of the material, including the
+// empty ones.
+func (m *Material) Maps() []string {
+	return []string{
+		m.DiffuseMap, m.SpecularMap, m.BumpMap,
+		m.NormalMap, m.MetallicMap, m.RoughnessMap, m.OcclusionMap, m.EmissiveMap,
+	}
+}
+
+// NewMaterial returns a white, opaque material.
+func NewMaterial(name string) *Material {
+	return &Material{
+		Name:         name,
+		Ambient:      gome.FloatVector3{X: 1, Y: 1, Z: 1},
+		Diffuse:      gome.FloatVector3{X: 1, Y: 1, Z: 1},
+		Shininess:    1,
+		Dissolve:     1,
+		Illumination: 2,
+		Roughness:    1,
+	}
+}
+
+/*
+	MTLFileReader
+*/
+
+// A MTLFileReader reads a .mtl material library.
+type MTLFileReader struct {
+	// Dir is the directory texture paths are relative to, normally the
+	// directory of the .mtl file.
+	Dir string
+}
+
+// A MTLError is returned for malformed lines of a .mtl file.
+type MTLError struct {
+	Line int
+	Msg  string
+}
+
+func (me *MTLError) Error() string { return fmt.Sprintf("mtl: line %d: %s", me.Line, me.Msg) }
+
+// Check checks if the file type is mtl.
+func (mfr *MTLFileReader) Check(file io.Reader) bool {
+	// TODO
+	return true
+}
+
+// Extension returns the default file extention for this file type.
+func (mfr *MTLFileReader) Extension() string { return "mtl" }
+
+// Data returns all materials of the library by name.
+func (mfr *MTLFileReader) Data(file io.Reader) (materials map[string]*Material, err error) {
+	materials = make(map[string]*Material)
+	var current *Material
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+
+		words := strings.Fields(text)
+		if len(words) == 0 {
+			continue
+		}
+
+		errorf := func(format string, args ...interface{}) error {
+			return &MTLError{Line: line, Msg: fmt.Sprintf(format, args...)}
+		}
+
+		if words[0] == "newmtl" {
+			if len(words) < 2 {
+				return nil, errorf("missing material name")
+			}
+
+			current = NewMaterial(strings.Join(words[1:], " "))
+			materials[current.Name] = current
+			continue
+		}
+
+		if current == nil {
+			return nil, errorf("%s before the first newmtl", words[0])
+		}
+
+		switch words[0] {
+		case "Ka":
+			current.Ambient, err = parseColor(words[1:])
+		case "Kd":
+			current.Diffuse, err = parseColor(words[1:])
+		case "Ks":
+			current.Specular, err = parseColor(words[1:])
+		case "Ns":
+			current.Shininess, err = parseFloat(words[1:])
+		case "d":
+			// "-halo" only changes how the dissolve gets applied
+			if len(words) > 1 && words[1] == "-halo" {
+				words = words[1:]
+			}
+			current.Dissolve, err = parseFloat(words[1:])
+		case "Tr": // transparency, the inverse of dissolve
+			var transparency float32
+			transparency, err = parseFloat(words[1:])
+			current.Dissolve = 1 - transparency
+		case "illum":
+			if len(words) != 2 {
+				return nil, errorf("expected one illumination model")
+			}
+			current.Illumination, err = strconv.Atoi(words[1])
+		case "map_Kd":
+			current.DiffuseMap, err = mfr.parseMap(words[1:])
+		case "map_Bump", "map_bump", "bump":
+			current.BumpMap, err = mfr.parseMap(words[1:])
+		case "map_Ks":
+			current.SpecularMap, err = mfr.parseMap(words[1:])
+		case "Ke":
+			current.Emissive, err = parseColor(words[1:])
+		case "map_Ke":
+			current.EmissiveMap, err = mfr.parseMap(words[1:])
+		case "norm":
+			current.NormalMap, err = mfr.parseMap(words[1:])
+
+		// the PBR extension of the format
+		case "Pm":
+			current.Metallic, err = parseFloat(words[1:])
+			current.PBR = true
+		case "Pr":
+			current.Roughness, err = parseFloat(words[1:])
+			current.PBR = true
+		case "map_Pm":
+			current.MetallicMap, err = mfr.parseMap(words[1:])
+			current.PBR = true
+		case "map_Pr":
+			current.RoughnessMap, err = mfr.parseMap(words[1:])
+			current.PBR = true
+		case "map_AO", "map_ao":
+			current.OcclusionMap, err = mfr.parseMap(words[1:])
+		default:
+			gome.Log.Debug(gome.CategoryAssets, "skipping unsupported mtl statement", "line", line, "statement", words[0])
+		}
+
+		if err != nil {
+			return nil, errorf("%s: %v", words[0], err)
+		}
+	}
+
+	return materials, scanner.Err()
+}
+
+// parseColor parses an RGB color. A single value is used for all channels.
+func parseColor(words []string) (color gome.FloatVector3, err error) {
+	// spectral and CIEXYZ colors are not supported
+	if len(words) > 0 && (words[0] == "spectral" || words[0] == "xyz") {
+		return color, fmt.Errorf("%s colors are not supported", words[0])
+	}
+	if len(words) != 1 && len(words) != 3 {
+		return color, fmt.Errorf("expected 1 or 3 numbers, got %d", len(words))
+	}
+
+	values := [3]float32{}
+	for i := range values {
+		values[i], err = parseFloat(words[i%len(words) : i%len(words)+1])
+		if err != nil {
+			return color, err
+		}
+	}
+
+	return gome.FloatVector3{X: values[0], Y: values[1], Z: values[2]}, nil
+}
+
+// parseFloat parses a single number.
+func parseFloat(words []string) (float32, error) {
+	if len(words) != 1 {
+		return 0, fmt.Errorf("expected one number, got %d", len(words))
+	}
+
+	value, err := strconv.ParseFloat(words[0], 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", words[0])
+	}
+	return float32(value), nil
+}
+
+// mapOptionArgs is the number of arguments of texture map options. Options
+// with a range of arguments take as many numbers as follow them.
+var mapOptionArgs = map[string][2]int{
+	"-blendu":  {1, 1},
+	"-blendv":  {1, 1},
+	"-bm":      {1, 1},
+	"-boost":   {1, 1},
+	"-cc":      {1, 1},
+	"-clamp":   {1, 1},
+	"-imfchan": {1, 1},
+	"-texres":  {1, 1},
+	"-type":    {1, 1},
+	"-mm":      {2, 2},
+	"-o":       {1, 3},
+	"-s":       {1, 3},
+	"-t":       {1, 3},
+}
+
+// parseMap skips the options of a texture map statement and returns the
+// texture path relative to the directory of the reader.
+func (mfr *MTLFileReader) parseMap(words []string) (string, error) {
+	for len(words) > 0 {
+		args, ok := mapOptionArgs[words[0]]
+		if !ok {
+			break
+		}
+		words = words[1:]
+
+		// skip the required arguments, and the optional ones if they're numbers
+		for i := 0; i < args[1] && len(words) > 0; i++ {
+			if i >= args[0] {
+				if _, err := strconv.ParseFloat(words[0], 32); err != nil {
+					break
+				}
+			}
+			words = words[1:]
+		}
+	}
+
+	if len(words) == 0 {
+		return "", fmt.Errorf("missing texture file")
+	}
+
+	file := filepath.ToSlash(strings.Join(words, " "))
+	if filepath.IsAbs(file) || mfr.Dir == "" {
+		return file, nil
+	}
+	return path.Join(mfr.Dir, file), nil
+}
@@ -0,0 +1,79 @@
+package graphics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMTLParse(t *testing.T) {
+	library := `# a library
+newmtl shiny
+Ka 0.1
+Kd 1 0.5 0
+Ks 1 1 1
+Ns 96
+d -halo 0.75
+illum 2
+map_Kd -o 0.5 0.5 -s 2 textures/shiny.png
+map_Bump -bm 0.3 bump.png
+
+newmtl glass
+Tr 0.9
+Pm 0.25
+Pr 0.5
+norm normal.png
+`
+	materials, err := (&MTLFileReader{Dir: "models"}).Data(strings.NewReader(library))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(materials) != 2 {
+		t.Fatalf("got %d materials, want 2", len(materials))
+	}
+
+	shiny := materials["shiny"]
+	if shiny.Ambient.Z != 0.1 || shiny.Diffuse.Y != 0.5 || shiny.Shininess != 96 || shiny.Dissolve != 0.75 {
+		t.Errorf("wrong colors of %+v", shiny)
+	}
+	if shiny.DiffuseMap != "models/textures/shiny.png" || shiny.BumpMap != "models/bump.png" {
+		t.Errorf("got maps %q and %q", shiny.DiffuseMap, shiny.BumpMap)
+	}
+	if shiny.PBR {
+		t.Error("shiny is not PBR")
+	}
+
+	glass := materials["glass"]
+	if glass.Dissolve < 0.099 || glass.Dissolve > 0.101 || !glass.PBR || glass.Metallic != 0.25 || glass.Roughness != 0.5 {
+		t.Errorf("wrong glass %+v", glass)
+	}
+	if glass.NormalMap != "models/normal.png" {
+		t.Errorf("got normal map %q", glass.NormalMap)
+	}
+}
+
+func TestMTLErrors(t *testing.T) {
+	tests := []struct {
+		library string
+		line    int
+	}{
+		{"Kd 1 1 1", 1},
+		{"newmtl", 1},
+		{"newmtl a\nKd 1 1", 2},
+		{"newmtl a\n\nKd spectral file.rfl", 3},
+		{"newmtl a\nNs high", 2},
+		{"newmtl a\nmap_Kd -s 1 1 1", 2},
+	}
+
+	for _, test := range tests {
+		_, err := (&MTLFileReader{}).Data(strings.NewReader(test.library))
+		var mtlError *MTLError
+		if !errors.As(err, &mtlError) {
+			t.Errorf("%q: got error %v, want an MTLError", test.library, err)
+			continue
+		}
+		if mtlError.Line != test.line {
+			t.Errorf("%q: got error on line %d, want %d", test.library, mtlError.Line, test.line)
+		}
+	}
+}
@@ -5,34 +5,24 @@ import (
 	"fmt"
 	"gitlocal/gome"
 	"image"
+	"image/color"
 	"image/draw"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
 )
 
-var OBJ_VERTEX_LAYOUT = VertexLayout{layout: []ElementType{FVEC3, FVEC2, FVEC3}}
-
 // A OBJFileReader reads a .obj file.
-type OBJFileReader struct{}
-
-// A objVertex is a vertex generated by a .obj file.
-type objVertex struct {
-	position gome.FloatVector3
-	uv       gome.FloatVector2
-	normal   gome.FloatVector3
-}
-
-// IsSimilarTo checks if a vertex is similar to another vertex.
-func (ov objVertex) IsSimilarTo(position gome.FloatVector3, uv gome.FloatVector2, normal gome.FloatVector3) bool {
-	return ov.position.IsSimilarTo(position) &&
-		ov.uv.IsSimilarTo(uv) &&
-		ov.normal.IsSimilarTo(normal)
+type OBJFileReader struct {
+	// Dir is the directory material libraries are loaded from, normally the
+	// directory of the .obj file.
+	Dir string
 }
 
 // Check checks if the file type is obj.
@@ -46,178 +36,530 @@ func (ofr *OBJFileReader) Extension() string { return "obj" }
 
 // Data returns the data of the whole file in a more readable format.
 func (ofr *OBJFileReader) Data(file io.Reader) (data VertexArray, texture uint32, err error) {
+	data, material, err := ofr.Mesh(file)
+	if err != nil {
+		return data, 0, err
+	}
 
-	reader := bufio.NewReader(file)
+	// if the material was set, set the texture
+	// the vertex data stays valid if the texture fails to load
+	if len(material) > 0 {
+		texture, err = newTexture(material)
+		if err != nil {
+			return data, 0, &gome.AssetError{Path: material, Err: err}
+		}
+	}
 
-	// store original data temporary so it can be further processed
-	tempPositionIndices := []uint32{}
-	tempUVIndices := []uint32{}
-	tempNormalIndices := []uint32{}
-	tempPositions := []gome.FloatVector{}
-	tempUVs := []gome.FloatVector{}
-	tempNormals := []gome.FloatVector{}
+	return
+}
 
-	material := ""
+// Mesh reads the vertex data of the file without loading the texture. The
+// diffuse texture file of the first material is returned instead.
+func (ofr *OBJFileReader) Mesh(file io.Reader) (data VertexArray, material string, err error) {
+	mesh, err := ofr.Parse(file)
+	if err != nil {
+		return data, "", err
+	}
 
-	for {
-		// read the file line by line
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				// stop at the end of the file
-				break
-			}
+	if len(mesh.Submeshes) > 0 {
+		material = mesh.Submeshes[0].Material.DiffuseMap
+	}
+	return mesh.Upload(), material, nil
+}
 
-			return data, texture, err
-		}
+// An OBJError is returned for malformed lines of an .obj file.
+type OBJError struct {
+	Line int
+	Msg  string
+}
 
-		// clip the newline char from the line
-		line = line[:len(line)-1]
+func (oe *OBJError) Error() string { return fmt.Sprintf("obj: line %d: %s", oe.Line, oe.Msg) }
+
+// objIndex is the position, uv and normal index of one face vertex. Missing
+// indices are -1.
+//
+// Vertices without a normal also need the same smoothing to be shared: it is
+// the smoothing group, 0 before the first s statement, or -1 - the number of
+// the face for faces with smoothing off, so they share no vertices at all.
+type objIndex struct {
+	position, uv, normal int
+	smoothing            int
+}
+
+// objParser holds the state while parsing an .obj file.
+type objParser struct {
+	line int
+
+	positions []gome.FloatVector3
+	uvs       []gome.FloatVector2
+	normals   []gome.FloatVector3
+
+	// the mesh, with one vertex per distinct index tuple
+	mesh  *Mesh
+	cache map[objIndex]uint32
+
+	// the smoothing of every vertex of the mesh, see objIndex, and if it has
+	// no normal
+	smoothing []int
+	noNormal  []bool
+	// the number of faces so far, and if there was an s statement
+	faces       int
+	hasSmoothed bool
+
+	// if any face vertex has a uv or normal, and if any has no normal
+	hasUVs, hasNormals bool
+	missingNormals     bool
+
+	groups []MeshGroup
+	// the group the next face belongs to
+	current MeshGroup
+
+	// materials of all loaded material libraries by name
+	dir       string
+	libraries int
+	materials map[string]*Material
+}
 
-		words := strings.Split(line, " ")
+// Parse reads the mesh of the file without uploading it to the GPU, so it can be
+// called from any goroutine.
+//
+// The file is processed line by line, and face vertices with the same position,
+// uv and normal indices share one vertex. Faces with more than three vertices
+// get triangulated. If no face has uvs, the mesh has none. Faces without
+// normals get them generated per smoothing group: faces of the same group
+// share normals where they meet, faces with smoothing off ("s off" or "s 0")
+// are flat, and faces before the first s statement are all smoothed together.
+// Tangents are generated if a material has a bump map.
+// Every change of object, group, material or smoothing group starts a new
+// MeshGroup.
+//
+// Material libraries are loaded relative to Dir. If a library is missing, its
+// materials are replaced by the default material. Files without any library may
+// name a .png or .jpg texture file with usemtl directly, like older versions of
+// the engine expected.
+func (ofr *OBJFileReader) Parse(file io.Reader) (mesh *Mesh, err error) {
+	parser := &objParser{
+		mesh:      &Mesh{},
+		dir:       ofr.Dir,
+		materials: make(map[string]*Material),
+		cache:     make(map[objIndex]uint32),
+	}
 
-		// check the first word to identify the line type
-		switch words[0] {
-		case "#": // comments
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	statement := ""
+	for scanner.Scan() {
+		parser.line++
+		line := scanner.Text()
+
+		// remove comments
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		// a backslash at the end continues the statement on the next line
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(line, "\\") {
+			statement += line[:len(line)-1] + " "
+			continue
+		}
+		statement += line
+
+		words := strings.Fields(statement)
+		statement = ""
+		if len(words) == 0 {
 			continue
-		case "v": // vertices
-			tempPositions = append(tempPositions, gome.FloatVector3{
-				X: convertToFloat32(words[1]),
-				Y: convertToFloat32(words[2]),
-				Z: convertToFloat32(words[3]),
-			})
-		case "vt": // texture coordinates
-			tempUVs = append(tempUVs, gome.FloatVector2{
-				X: convertToFloat32(words[1]),
-				Y: convertToFloat32(words[2]),
-			})
-		case "vn": // vertex normals
-			tempNormals = append(tempNormals, gome.FloatVector3{
-				X: convertToFloat32(words[1]),
-				Y: convertToFloat32(words[2]),
-				Z: convertToFloat32(words[3]),
-			})
-		case "s":
-			continue // TODO
-		case "usemtl": // texture / material
-			material = words[1] // TODO this will bug out when file name contains a space
-		case "f": // indices
-			// for every word except the first one
-			for _, word := range words[1:] {
-				// append the indices for one vertex
-				split := strings.Split(word, "/")
-				tempPositionIndices = append(tempPositionIndices, convertToUint32(split[0])-1)
-				tempUVIndices = append(tempUVIndices, convertToUint32(split[1])-1)
-				tempNormalIndices = append(tempNormalIndices, convertToUint32(split[2])-1)
-			}
 		}
+
+		if err := parser.parseStatement(words); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	parser.endGroup()
+
+	mesh = parser.mesh
+	mesh.Groups = parser.groups
+	mesh.Materials = parser.materials
+	mesh.Submeshes = parser.submeshes()
+	if !parser.hasUVs {
+		mesh.UVs = nil
+	}
+	switch {
+	case !parser.hasNormals:
+		mesh.smoothNormals(parser.smoothing, nil)
+	case parser.missingNormals:
+		// only some faces have normals, the others get them generated
+		mesh.smoothNormals(parser.smoothing, parser.noNormal)
 	}
+	if mesh.UVs != nil && mesh.needsTangents() {
+		mesh.GenerateTangents()
+	}
+	mesh.UpdateBounds()
 
-	vertices := []objVertex{}
-	indices := []uint32{}
+	return mesh, nil
+}
 
-	// process temporary data
-	// TODO fix indexing
-	for i := 0; i < len(tempPositionIndices); i++ {
+// errorf returns an OBJError for the current line.
+func (op *objParser) errorf(format string, args ...interface{}) error {
+	return &OBJError{Line: op.line, Msg: fmt.Sprintf(format, args...)}
+}
 
-		var matched bool
+// parseStatement parses a single statement, split into words.
+func (op *objParser) parseStatement(words []string) error {
+	// check the first word to identify the statement type
+	switch words[0] {
+	case "v": // vertices (a w or color components are ignored)
+		position, err := op.parseFloats(words[1:], 3, 3)
+		if err != nil {
+			return err
+		}
+		op.positions = append(op.positions, gome.FloatVector3{X: position[0], Y: position[1], Z: position[2]})
+	case "vt": // texture coordinates (a w component is ignored)
+		uv, err := op.parseFloats(words[1:], 1, 2)
+		if err != nil {
+			return err
+		}
+		op.uvs = append(op.uvs, gome.FloatVector2{X: uv[0], Y: uv[1]})
+	case "vn": // vertex normals
+		normal, err := op.parseFloats(words[1:], 3, 3)
+		if err != nil {
+			return err
+		}
+		op.normals = append(op.normals, gome.FloatVector3{X: normal[0], Y: normal[1], Z: normal[2]})
+	case "f": // faces
+		return op.parseFace(words[1:])
+	case "o": // objects
+		op.endGroup()
+		op.current.Object = strings.Join(words[1:], " ")
+		op.current.Group = ""
+	case "g": // groups
+		op.endGroup()
+		op.current.Group = strings.Join(words[1:], " ")
+	case "s": // smoothing groups
+		if len(words) != 2 {
+			return op.errorf("expected one smoothing group, got %d", len(words)-1)
+		}
 
-		// only use the uv if there are textures
-		uv := gome.FloatVector2{}
-		if len(tempUVs) > 0 {
-			uv = tempUVs[tempUVIndices[i]].(gome.FloatVector2)
+		smoothing := 0
+		if words[1] != "off" {
+			var err error
+			smoothing, err = strconv.Atoi(words[1])
+			if err != nil {
+				return op.errorf("invalid smoothing group %q", words[1])
+			}
 		}
 
-		// check if there is a match
-		for j, vertex := range vertices {
-			if vertex.IsSimilarTo(
-				tempPositions[tempPositionIndices[i]].(gome.FloatVector3),
-				uv,
-				tempNormals[tempNormalIndices[i]].(gome.FloatVector3),
-			) {
-				// there is a similar vertex, use it instead
-				indices = append(indices, uint32(j))
-				matched = true
-				break
+		op.endGroup()
+		op.current.Smoothing = smoothing
+		op.hasSmoothed = true
+	case "usemtl": // materials
+		if len(words) < 2 {
+			return op.errorf("missing material name")
+		}
+
+		op.endGroup()
+		op.current.Material = strings.Join(words[1:], " ")
+	case "mtllib": // material libraries
+		if len(words) < 2 {
+			return op.errorf("missing material library")
+		}
+
+		for _, library := range words[1:] {
+			if err := op.loadLibrary(library); err != nil {
+				return err
 			}
 		}
+	default:
+		// lines, points, curves etc. are not supported
+		gome.Log.Debug(gome.CategoryAssets, "skipping unsupported obj statement", "line", op.line, "statement", words[0])
+	}
+
+	return nil
+}
+
+// parseFloats parses at least min and at most max numbers. The rest of the
+// numbers is ignored. Missing optional numbers are 0.
+func (op *objParser) parseFloats(words []string, min, max int) ([]float32, error) {
+	if len(words) < min {
+		return nil, op.errorf("expected at least %d numbers, got %d", min, len(words))
+	}
 
-		// if there is no match, add to the vertices and refer to the new entry
-		if !matched {
-			indices = append(indices, uint32(len(vertices)))
-			vertices = append(vertices, objVertex{
-				position: tempPositions[tempPositionIndices[i]].(gome.FloatVector3),
-				uv:       uv,
-				normal:   tempNormals[tempNormalIndices[i]].(gome.FloatVector3),
-			})
+	result := make([]float32, max)
+	for i := 0; i < max && i < len(words); i++ {
+		value, err := strconv.ParseFloat(words[i], 32)
+		if err != nil {
+			return nil, op.errorf("invalid number %q", words[i])
 		}
+		result[i] = float32(value)
 	}
 
-	rawData := []float32{}
+	return result, nil
+}
 
-	// convert vertex data to a raw float32 array
-	for _, vertex := range vertices {
-		rawData = append(rawData,
-			vertex.position.X, vertex.position.Y, vertex.position.Z,
-			vertex.uv.X, vertex.uv.Y,
-			vertex.normal.X, vertex.normal.Y, vertex.normal.Z,
-		)
+// parseFace parses the vertices of a face and triangulates it.
+func (op *objParser) parseFace(words []string) error {
+	if len(words) < 3 {
+		return op.errorf("a face needs at least 3 vertices, got %d", len(words))
 	}
 
-	// set vertex data
-	data.SetLayout(OBJ_VERTEX_LAYOUT)
-	data.SetData(rawData)
-	data.SetIndexData(indices)
+	face := make([]objIndex, len(words))
+	for i, word := range words {
+		// possible formats: v, v/vt, v//vn, v/vt/vn
+		split := strings.Split(word, "/")
+		if len(split) > 3 {
+			return op.errorf("invalid face vertex %q", word)
+		}
 
-	// if the material was set, set the texture
-	if len(material) > 0 {
-		texture, err = newTexture(material)
+		index := objIndex{position: -1, uv: -1, normal: -1}
+
+		var err error
+		index.position, err = op.parseIndex(split[0], len(op.positions))
 		if err != nil {
-			return data, texture, err
+			return err
+		}
+		if len(split) > 1 && split[1] != "" {
+			index.uv, err = op.parseIndex(split[1], len(op.uvs))
+			if err != nil {
+				return err
+			}
+		}
+		if len(split) > 2 && split[2] != "" {
+			index.normal, err = op.parseIndex(split[2], len(op.normals))
+			if err != nil {
+				return err
+			}
+		}
+
+		if index.normal < 0 {
+			index.smoothing = op.smoothingKey()
 		}
+
+		face[i] = index
+	}
+	op.faces++
+
+	if len(face) == 3 {
+		op.addVertex(face[0])
+		op.addVertex(face[1])
+		op.addVertex(face[2])
+		return nil
+	}
+
+	polygon := make([]gome.FloatVector3, len(face))
+	for i, index := range face {
+		polygon[i] = op.positions[index.position]
+	}
+	for _, triangle := range triangulate(polygon) {
+		op.addVertex(face[triangle[0]])
+		op.addVertex(face[triangle[1]])
+		op.addVertex(face[triangle[2]])
+	}
+
+	return nil
+}
+
+// smoothingKey returns the smoothing of vertices without normals of the
+// current face, see objIndex.
+func (op *objParser) smoothingKey() int {
+	if op.hasSmoothed && op.current.Smoothing == 0 {
+		return -1 - op.faces
+	}
+	return op.current.Smoothing
+}
+
+// addVertex adds the index of a face vertex. Face vertices with the same
+// position, uv and normal indices share one vertex.
+func (op *objParser) addVertex(index objIndex) {
+	if vertex, ok := op.cache[index]; ok {
+		op.mesh.Indices = append(op.mesh.Indices, vertex)
+		return
+	}
+
+	uv := gome.FloatVector2{}
+	if index.uv >= 0 {
+		uv = op.uvs[index.uv]
+		op.hasUVs = true
+	}
+	normal := gome.FloatVector3{}
+	if index.normal >= 0 {
+		normal = op.normals[index.normal]
+		op.hasNormals = true
 	} else {
-		texture = 0
+		op.missingNormals = true
 	}
 
-	return
+	vertex := uint32(len(op.mesh.Positions))
+	op.mesh.Positions = append(op.mesh.Positions, op.positions[index.position])
+	op.mesh.UVs = append(op.mesh.UVs, uv)
+	op.mesh.Normals = append(op.mesh.Normals, normal)
+	op.mesh.Indices = append(op.mesh.Indices, vertex)
+	op.smoothing = append(op.smoothing, index.smoothing)
+	op.noNormal = append(op.noNormal, index.normal < 0)
+	op.cache[index] = vertex
 }
 
-// convertToFloat32 silently converts a string to a float32.
-func convertToFloat32(input string) float32 {
-	result, err := strconv.ParseFloat(input, 32)
+// parseIndex parses a 1-based or negative (relative to the end) index into
+// a 0-based index and checks if it's in range.
+func (op *objParser) parseIndex(word string, count int) (int, error) {
+	index, err := strconv.Atoi(word)
 	if err != nil {
-		return 0
+		return 0, op.errorf("invalid index %q", word)
+	}
+
+	switch {
+	case index > 0:
+		index--
+	case index < 0:
+		index += count
+	default:
+		return 0, op.errorf("index 0 is not valid")
+	}
+
+	if index < 0 || index >= count {
+		return 0, op.errorf("index %s out of range (%d elements)", word, count)
+	}
+
+	return index, nil
+}
+
+// endGroup finishes the current group if it has faces.
+func (op *objParser) endGroup() {
+	first := 0
+	if len(op.groups) > 0 {
+		last := op.groups[len(op.groups)-1]
+		first = last.First + last.Count
+	}
+
+	if count := len(op.mesh.Indices) - first; count > 0 {
+		op.current.First = first
+		op.current.Count = count
+		op.groups = append(op.groups, op.current)
 	}
-	return float32(result)
 }
 
-// convertToUint silently converts a string to a uint32.
-func convertToUint32(input string) uint32 {
-	result, err := strconv.ParseUint(input, 10, 32)
+// loadLibrary loads the materials of a .mtl file. A missing file only gets
+// logged, since the mesh is still usable without its materials.
+func (op *objParser) loadLibrary(library string) error {
+	library = path.Join(op.dir, filepath.ToSlash(library))
+	op.libraries++
+	op.mesh.Libraries = append(op.mesh.Libraries, library)
+
+	file, err := gome.Files.Open(library)
 	if err != nil {
-		return 0
+		gome.Log.Warn(gome.CategoryAssets, "could not load material library", "path", library, "err", err)
+		return nil
 	}
-	return uint32(result)
+	defer file.Close()
+
+	reader := &MTLFileReader{Dir: path.Dir(library)}
+	materials, err := reader.Data(file)
+	if err != nil {
+		return op.errorf("material library %s: %v", library, err)
+	}
+
+	for name, material := range materials {
+		op.materials[name] = material
+	}
+	return nil
+}
+
+// imageExtensions are the extensions of the image formats textures can be
+// loaded from.
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+
+// material returns the material of a usemtl name. Without a material library,
+// names of image files are textures.
+func (op *objParser) material(name string) *Material {
+	if material, ok := op.materials[name]; ok {
+		return material
+	}
+
+	material := NewMaterial(name)
+	if op.libraries == 0 && imageExtensions[strings.ToLower(path.Ext(name))] {
+		// without a library, the name is the texture file
+		material.DiffuseMap = name
+	} else if op.libraries > 0 {
+		gome.Log.Warn(gome.CategoryAssets, "undefined material, using default", "material", name)
+	}
+
+	op.materials[name] = material
+	return material
+}
+
+// submeshes merges the groups into submeshes with one material each.
+func (op *objParser) submeshes() []Submesh {
+	submeshes := []Submesh{}
+	for _, group := range op.groups {
+		material := op.material(group.Material)
+
+		if last := len(submeshes) - 1; last >= 0 && submeshes[last].Material == material {
+			submeshes[last].Count += group.Count
+			continue
+		}
+
+		submeshes = append(submeshes, Submesh{First: group.First, Count: group.Count, Material: material})
+	}
+
+	return submeshes
 }
 
 // newTexture generates a new OpenGL texture from a file.
 // Source: https://gist.github.com/errcw/e3311a0ed1a1c0113a92
 func newTexture(file string) (uint32, error) {
-	imgFile, err := os.Open(file)
+	imgFile, err := gome.Files.Open(file)
 	if err != nil {
 		return 0, err
 	}
-	img, _, err := image.Decode(imgFile)
+	defer imgFile.Close()
+
+	rgba, err := decodeImage(imgFile)
 	if err != nil {
 		return 0, err
 	}
 
+	return uploadTexture(rgba), nil
+}
+
+// decodeImage decodes an image into the RGBA format OpenGL expects. It doesn't
+// touch the GPU, so it can be called from any goroutine.
+func decodeImage(file io.Reader) (*image.RGBA, error) {
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
 	rgba := image.NewRGBA(img.Bounds())
 	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, fmt.Errorf("unsupported stride")
+		return nil, fmt.Errorf("unsupported stride")
 	}
 	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
+	return rgba, nil
+}
+
+// PlaceholderTexture generates a magenta and black checkerboard texture that
+// can be shown in place of a texture that failed to load.
+func PlaceholderTexture() uint32 {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.RGBA{R: 255, B: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{A: 255})
+			}
+		}
+	}
+
+	return uploadTexture(img)
+}
+
+// uploadTexture uploads an image to a new OpenGL texture.
+func uploadTexture(rgba *image.RGBA) uint32 {
 	var texture uint32
 	gl.GenTextures(1, &texture)
 	gl.ActiveTexture(gl.TEXTURE0)
@@ -237,5 +579,5 @@ func newTexture(file string) (uint32, error) {
 		gl.UNSIGNED_BYTE,
 		gl.Ptr(rgba.Pix))
 
-	return texture, nil
+	return texture
 }
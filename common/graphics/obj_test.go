@@ -0,0 +1,271 @@
+package graphics
+
+import (
+	"errors"
+	"fmt"
+	"gitlocal/gome"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOBJVertexDeduplication(t *testing.T) {
+	header := "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 1 1 0\nvt 0 0\nvt 1 0\nvt 0 1\nvt 1 1\nvn 0 0 1\nvn 0 0 -1\n"
+
+	tests := []struct {
+		name     string
+		faces    string
+		vertices int
+	}{
+		{"shared tuples", "f 1/1/1 2/2/1 3/3/1\nf 2/2/1 4/4/1 3/3/1\n", 4},
+		{"same tuple twice in a face", "f 1/1/1 2/2/1 1/1/1\n", 2},
+		{"different uv", "f 1/1/1 2/2/1 3/3/1\nf 1/4/1 2/2/1 3/3/1\n", 4},
+		{"different normal", "f 1/1/1 2/2/1 3/3/1\nf 1/1/2 2/2/1 3/3/1\n", 4},
+		{"missing uv", "f 1/1/1 2/2/1 3/3/1\nf 1//1 2/2/1 3/3/1\n", 4},
+		{"relative indices", "f 1/1/1 2/2/1 3/3/1\nf -4/-4/-2 -3/-3/-2 -2/-2/-2\n", 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mesh, err := (&OBJFileReader{}).Parse(strings.NewReader(header + test.faces))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mesh.VertexCount(); got != test.vertices {
+				t.Errorf("got %d vertices, want %d", got, test.vertices)
+			}
+			if err := mesh.Validate(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// gridOBJ returns an .obj file of a grid of size × size quads with uvs and
+// normals, split into two triangles each.
+func gridOBJ(size int) string {
+	var builder strings.Builder
+	for y := 0; y <= size; y++ {
+		for x := 0; x <= size; x++ {
+			fmt.Fprintf(&builder, "v %d 0 %d\nvt %g %g\n", x, y, float32(x)/float32(size), float32(y)/float32(size))
+		}
+	}
+	builder.WriteString("vn 0 1 0\n")
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			a := y*(size+1) + x + 1
+			b, c, d := a+1, a+size+1, a+size+2
+			fmt.Fprintf(&builder, "f %d/%d/1 %d/%d/1 %d/%d/1\n", a, a, c, c, b, b)
+			fmt.Fprintf(&builder, "f %d/%d/1 %d/%d/1 %d/%d/1\n", b, b, c, c, d, d)
+		}
+	}
+	return builder.String()
+}
+
+// BenchmarkOBJParse parses a grid of about 100k triangles.
+func BenchmarkOBJParse(b *testing.B) {
+	size := 224
+	obj := gridOBJ(size)
+	b.SetBytes(int64(len(obj)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		mesh, err := (&OBJFileReader{}).Parse(strings.NewReader(obj))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if mesh.VertexCount() != (size+1)*(size+1) {
+			b.Fatalf("got %d vertices", mesh.VertexCount())
+		}
+	}
+}
+
+// cubeOBJ is a unit cube of quads without normals, with a statement before
+// every face.
+func cubeOBJ(statement func(face int) string) string {
+	obj := "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nv 0 0 1\nv 1 0 1\nv 1 1 1\nv 0 1 1\n"
+	faces := []string{"1 4 3 2", "5 6 7 8", "1 2 6 5", "4 8 7 3", "1 5 8 4", "2 3 7 6"}
+	for i, face := range faces {
+		obj += statement(i) + "\nf " + face + "\n"
+	}
+	return obj
+}
+
+func TestOBJSmoothingGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement func(face int) string
+		vertices  int
+		flat      bool
+	}{
+		{"no smoothing groups", func(int) string { return "" }, 8, false},
+		{"one group", func(int) string { return "s 1" }, 8, false},
+		{"smoothing off", func(int) string { return "s off" }, 24, true},
+		{"group 0", func(int) string { return "s 0" }, 24, true},
+		{"group per face", func(face int) string { return fmt.Sprintf("s %d", face+1) }, 24, true},
+		{"two groups", func(face int) string { return fmt.Sprintf("s %d", face/3+1) }, 16, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mesh, err := (&OBJFileReader{}).Parse(strings.NewReader(cubeOBJ(test.statement)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mesh.VertexCount(); got != test.vertices {
+				t.Errorf("got %d vertices, want %d", got, test.vertices)
+			}
+
+			// flat normals point along an axis, smooth ones of a cube corner don't
+			for i, normal := range mesh.Normals {
+				axis := isAxis(normal.X, normal.Y, normal.Z)
+				if test.flat && !axis {
+					t.Errorf("normal %d is %v, want a flat one", i, normal)
+				}
+			}
+		})
+	}
+}
+
+func TestOBJMissingNormals(t *testing.T) {
+	// the first face has normals pointing away from its own, the others have none
+	obj := "v 0 0 0\nv 1 0 0\nv 0 1 0\nv 1 1 0\nv 0 0 1\nvn 0 0 -1\n" +
+		"f 1//1 2//1 3//1\nf 2 4 3\ns 1\nf 1 5 2\n"
+	mesh, err := (&OBJFileReader{}).Parse(strings.NewReader(obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mesh.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, normal := range mesh.Normals {
+		if !isAxis(normal.X, normal.Y, normal.Z) {
+			t.Errorf("normal %d is %v, want a unit normal along an axis", i, normal)
+		}
+	}
+	for _, vertex := range mesh.Indices[:3] {
+		if normal := mesh.Normals[vertex]; normal.Z != -1 {
+			t.Errorf("given normal changed to %v", normal)
+		}
+	}
+	for _, vertex := range mesh.Indices[3:6] {
+		if normal := mesh.Normals[vertex]; normal.Z != 1 {
+			t.Errorf("got normal %v for the second face, want {0 0 1}", normal)
+		}
+	}
+	for _, vertex := range mesh.Indices[6:] {
+		if normal := mesh.Normals[vertex]; normal.Y != 1 {
+			t.Errorf("got normal %v for the third face, want {0 1 0}", normal)
+		}
+	}
+}
+
+// isAxis returns true if a unit vector points along an axis.
+func isAxis(x, y, z float32) bool {
+	return abs32(x)+abs32(y)+abs32(z) > 0.999 && abs32(x)+abs32(y)+abs32(z) < 1.001
+}
+
+func TestOBJLegacyTexture(t *testing.T) {
+	tests := []struct {
+		material string
+		texture  string
+	}{
+		{"grass.png", "grass.png"},
+		{"Stone.JPG", "Stone.JPG"},
+		{"textures/wood.jpeg", "textures/wood.jpeg"},
+		{"Material.001", ""},
+		{"Material", ""},
+	}
+
+	for _, test := range tests {
+		obj := "v 0 0 0\nv 1 0 0\nv 0 1 0\nusemtl " + test.material + "\nf 1 2 3\n"
+		mesh, err := (&OBJFileReader{}).Parse(strings.NewReader(obj))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mesh.Submeshes[0].Material.DiffuseMap; got != test.texture {
+			t.Errorf("usemtl %s: got texture %q, want %q", test.material, got, test.texture)
+		}
+	}
+}
+
+func TestOBJMaterialLibrary(t *testing.T) {
+	gome.Files.Mount("objtest", fstest.MapFS{
+		"models/materials.mtl": {Data: []byte("newmtl red\nKd 1 0 0\nmap_Kd red.png\nnewmtl blue\nKd 0 0 1\n")},
+	})
+	defer gome.Files.Unmount("objtest")
+
+	obj := `mtllib materials.mtl missing.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+v 1 1 0
+usemtl red
+f 1 2 3
+g second
+f 2 4 3
+usemtl blue
+f 1 3 4
+usemtl undefined
+f 1 2 4
+`
+	mesh, err := (&OBJFileReader{Dir: "objtest/models"}).Parse(strings.NewReader(obj))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mesh.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the groups of the same material are merged into one submesh
+	if len(mesh.Groups) != 4 || len(mesh.Submeshes) != 3 {
+		t.Fatalf("got %d groups and %d submeshes, want 4 and 3", len(mesh.Groups), len(mesh.Submeshes))
+	}
+	red, blue, undefined := mesh.Submeshes[0], mesh.Submeshes[1], mesh.Submeshes[2]
+	if red.Count != 6 || red.Material.Diffuse.X != 1 || red.Material.DiffuseMap != "objtest/models/red.png" {
+		t.Errorf("wrong red submesh %+v with material %+v", red, red.Material)
+	}
+	if blue.First != 6 || blue.Count != 3 || blue.Material.Diffuse.Z != 1 {
+		t.Errorf("wrong blue submesh %+v", blue)
+	}
+	if undefined.Material.Name != "undefined" || undefined.Material.DiffuseMap != "" {
+		t.Errorf("undefined material is %+v, want the default", undefined.Material)
+	}
+	if len(mesh.Libraries) != 2 || mesh.Libraries[0] != "objtest/models/materials.mtl" || mesh.Libraries[1] != "objtest/models/missing.mtl" {
+		t.Errorf("got libraries %v", mesh.Libraries)
+	}
+	if mesh.Groups[1].Group != "second" {
+		t.Errorf("got group %q, want second", mesh.Groups[1].Group)
+	}
+}
+
+func TestOBJErrors(t *testing.T) {
+	tests := []struct {
+		obj  string
+		line int
+	}{
+		{"v 1 2", 1},
+		{"v 1 2 3\nv a b c", 2},
+		{"v 0 0 0\nv 1 0 0\nf 1 2", 3},
+		{"v 0 0 0\nv 1 0 0\nv 0 1 0\n\n# comment\nf 1 2 4", 6},
+		{"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2", 4},
+		{"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1/1 2/1 3/1", 4},
+		{"v 0 0 0\nv 1 0 0\nv 0 1 0\nf -4 1 2", 4},
+		{"v 0 0 0 \\\n1\nf 1 \\\n2 3\ns", 4},
+		{"usemtl", 1},
+	}
+
+	for _, test := range tests {
+		_, err := (&OBJFileReader{}).Parse(strings.NewReader(test.obj))
+		var objError *OBJError
+		if !errors.As(err, &objError) {
+			t.Errorf("%q: got error %v, want an OBJError", test.obj, err)
+			continue
+		}
+		if objError.Line != test.line {
+			t.Errorf("%q: got error on line %d, want %d", test.obj, objError.Line, test.line)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+#shader vertex
+#version 330 core
+
+layout(location = 0) in vec3 vertex_pos;
+layout(location = 4) in vec4 vertex_joints;
+layout(location = 5) in vec4 vertex_weights;
+
+uniform mat4 u_MVP;
+
+// skinned meshes get deformed by up to 4 of the joints
+uniform int u_Skinned;
+uniform mat4 u_Joints[64];
+
+void main() {
+	mat4 skin = mat4(1.0);
+	if (u_Skinned != 0 && dot(vertex_weights, vec4(1.0)) > 0.0) {
+		skin = vertex_weights.x * u_Joints[int(vertex_joints.x)] +
+			vertex_weights.y * u_Joints[int(vertex_joints.y)] +
+			vertex_weights.z * u_Joints[int(vertex_joints.z)] +
+			vertex_weights.w * u_Joints[int(vertex_joints.w)];
+	}
+
+	gl_Position = u_MVP * skin * vec4(vertex_pos, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+// the ID of the entity plus one, 0 is nothing
+uniform int u_ID;
+
+out uint id;
+
+void main() {
+	id = uint(u_ID);
+}
@@ -0,0 +1,401 @@
+package graphics
+
+import (
+	"gitlocal/gome"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+/*
+	PostEffect
+*/
+
+// A PostPass is a full-screen shader pass over the rendered image. The
+// fragment stage of its shader gets the output of the previous pass as
+// u_Image, in linear color space, and the size of its pixels as u_TexelSize. It can also declare
+// u_Input, the image before the effect the pass belongs to, and u_Depth, the
+// depth of the scene. See the shaders in post/ for examples.
+type PostPass struct {
+	Shader string
+
+	// Uniforms are set before drawing, see Shader.SetUniform for the types.
+	// They can be changed between frames to animate an effect.
+	Uniforms map[string]interface{}
+
+	// Scale is the resolution of the output relative to the screen, 1 if 0.
+	// Blurs are cheaper and wider at lower resolutions.
+	Scale float32
+}
+
+// A PostEffect is a list of passes, e.g. for bloom, that processes the image
+// of a camera.
+type PostEffect struct {
+	Passes []PostPass
+
+	// Disabled effects are skipped.
+	Disabled bool
+}
+
+// postShader returns the path of a built-in post-processing shader.
+func postShader(name string) string { return "gome/post/" + name + ".shader" }
+
+// encodeOutput is the last effect of every post processor, which encodes the
+// linear colors for the screen.
+var encodeOutput = &PostEffect{Passes: []PostPass{{Shader: postShader("encode")}}}
+
+// ToneMapping maps the colors of the scene into the displayable range with
+// the ACES filmic curve, instead of clipping colors brighter than 1. Exposure
+// scales the colors before. It belongs before effects that expect colors
+// from 0 to 1, like FXAA.
+func ToneMapping(exposure float32) *PostEffect {
+	return &PostEffect{Passes: []PostPass{{
+		Shader:   postShader("tonemap"),
+		Uniforms: map[string]interface{}{"u_Exposure": exposure},
+	}}}
+}
+
+// GammaCorrection raises the colors to the power of 1/gamma. The image gets
+// encoded for the screen after the last effect anyway, so this only adjusts
+// it: values above 1 brighten the midtones, values below 1 darken them.
+func GammaCorrection(gamma float32) *PostEffect {
+	return &PostEffect{Passes: []PostPass{{
+		Shader:   postShader("gamma"),
+		Uniforms: map[string]interface{}{"u_Gamma": gamma},
+	}}}
+}
+
+// FXAA smooths jagged edges by blurring along them. It is a lot cheaper than
+// multisampling, but also blurs sharp details a bit.
+func FXAA() *PostEffect {
+	return &PostEffect{Passes: []PostPass{{Shader: postShader("fxaa")}}}
+}
+
+// Vignette darkens the edges of the image. The darkening starts at radius
+// from the center (1 being the corners), reaches strength after softness
+// more and stays there.
+func Vignette(strength, radius, softness float32) *PostEffect {
+	return &PostEffect{Passes: []PostPass{{
+		Shader: postShader("vignette"),
+		Uniforms: map[string]interface{}{
+			"u_Strength": strength,
+			"u_Radius":   radius,
+			"u_Softness": softness,
+		},
+	}}}
+}
+
+// ColorGrading tints the image, then adjusts its brightness, contrast and
+// saturation. A brightness of 0, contrast and saturation of 1 and a white
+// tint leave it unchanged.
+func ColorGrading(brightness, contrast, saturation float32, tint gome.FloatVector3) *PostEffect {
+	return &PostEffect{Passes: []PostPass{{
+		Shader: postShader("grading"),
+		Uniforms: map[string]interface{}{
+			"u_Brightness": brightness,
+			"u_Contrast":   contrast,
+			"u_Saturation": saturation,
+			"u_Tint":       tint,
+		},
+	}}}
+}
+
+// Bloom makes colors brighter than threshold bleed into their surroundings,
+// by adding a blurred copy of them multiplied by intensity. It belongs before
+// tone mapping, which brings the bright colors back into range.
+func Bloom(threshold, intensity float32) *PostEffect {
+	return &PostEffect{Passes: []PostPass{
+		{
+			Shader:   postShader("bright"),
+			Uniforms: map[string]interface{}{"u_Threshold": threshold},
+			Scale:    0.5,
+		},
+		{
+			Shader:   postShader("blur"),
+			Uniforms: map[string]interface{}{"u_Direction": gome.FloatVector2{X: 1, Y: 0}},
+			Scale:    0.5,
+		},
+		{
+			Shader:   postShader("blur"),
+			Uniforms: map[string]interface{}{"u_Direction": gome.FloatVector2{X: 0, Y: 1}},
+			Scale:    0.5,
+		},
+		{
+			Shader:   postShader("bloom"),
+			Uniforms: map[string]interface{}{"u_Intensity": intensity},
+		},
+	}}
+}
+
+/*
+	PostProcessor
+*/
+
+// A PostProcessor runs post effects over what gets drawn between Begin and
+// End. The scene is drawn into a floating point image in linear color space,
+// so colors brighter than 1 survive until tone mapping. After the last effect,
+// the colors are encoded for the screen.
+type PostProcessor struct {
+	assets  *AssetManager
+	shaders map[string]*ShaderAsset // nil for shaders that failed to load
+
+	scene   *RenderTarget
+	targets []*RenderTarget
+
+	// vertexArray is empty; the passes generate their triangle from the vertex
+	// IDs, but OpenGL needs a bound vertex array to draw anyway
+	vertexArray uint32
+
+	// the framebuffer and viewport the last pass draws into
+	framebuffer uint32
+	viewport    [4]int32
+}
+
+// NewPostProcessor returns a post processor loading its shaders with an
+// asset manager.
+func NewPostProcessor(assets *AssetManager) *PostProcessor {
+	pp := &PostProcessor{
+		assets:  assets,
+		shaders: make(map[string]*ShaderAsset),
+	}
+	gl.GenVertexArrays(1, &pp.vertexArray)
+
+	return pp
+}
+
+// Begin makes draw calls render into an offscreen image of the size of the
+// viewport, until End is called.
+func (pp *PostProcessor) Begin() error {
+	pp.framebuffer = boundFramebuffer()
+	gl.GetIntegerv(gl.VIEWPORT, &pp.viewport[0])
+	width, height := int(pp.viewport[2]), int(pp.viewport[3])
+
+	if pp.scene == nil {
+		scene, err := NewRenderTarget(width, height, RenderTargetOptions{
+			Colors: []TargetFormat{TARGET_RGBA16F},
+		})
+		if err != nil {
+			return err
+		}
+		pp.scene = scene
+	} else if pp.scene.Width != width || pp.scene.Height != height {
+		if err := pp.scene.Resize(width, height); err != nil {
+			return err
+		}
+
+		// the intermediate images have the old size
+		pp.deleteTargets()
+	}
+
+	pp.scene.Bind()
+	return nil
+}
+
+// End runs the passes of the enabled effects in order, followed by the one
+// encoding the colors for the screen, which draws into the framebuffer and
+// viewport that were bound when Begin was called. Passes whose shader failed
+// to load are skipped.
+func (pp *PostProcessor) End(effects []*PostEffect) {
+	passes := [][]PostPass{}
+	for _, effect := range effects {
+		passes = append(passes, pp.loadedPasses(effect))
+	}
+	passes = append(passes, pp.loadedPasses(encodeOutput))
+
+	// drop empty effects, so the last pass is known
+	for len(passes) > 0 && len(passes[len(passes)-1]) == 0 {
+		passes = passes[:len(passes)-1]
+	}
+	if len(passes) == 0 {
+		pp.copyScene()
+		return
+	}
+
+	depthTest, blend := gl.IsEnabled(gl.DEPTH_TEST), gl.IsEnabled(gl.BLEND)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.BLEND)
+	defer func() {
+		if depthTest {
+			gl.Enable(gl.DEPTH_TEST)
+		}
+		if blend {
+			gl.Enable(gl.BLEND)
+		}
+	}()
+
+	gl.BindVertexArray(pp.vertexArray)
+	image := pp.scene
+	for i, effect := range passes {
+		input := image
+		for j, pass := range effect {
+			last := i == len(passes)-1 && j == len(effect)-1
+			output, err := pp.bindOutput(pass, last, image, input)
+			if err != nil {
+				gome.Log.Error(gome.CategoryRender, "skipping post pass", "shader", pass.Shader, "err", err)
+				continue
+			}
+
+			pp.draw(pass, image, input)
+			image = output
+		}
+	}
+}
+
+// loadedPasses returns the passes of an effect whose shader is loaded, none if
+// the effect is disabled.
+func (pp *PostProcessor) loadedPasses(effect *PostEffect) []PostPass {
+	if effect == nil || effect.Disabled {
+		return nil
+	}
+
+	passes := []PostPass{}
+	for _, pass := range effect.Passes {
+		if pp.shader(pass.Shader) != nil {
+			passes = append(passes, pass)
+		}
+	}
+	return passes
+}
+
+// shader returns the shader of a pass, loading it on first use. Load errors are
+// passed to gome.HandleError once; the passes of the shader are skipped.
+func (pp *PostProcessor) shader(path string) *ShaderAsset {
+	if shader, ok := pp.shaders[path]; ok {
+		return shader
+	}
+
+	shader, err := pp.assets.Shader(path)
+	if err != nil {
+		if herr := gome.HandleError(err); herr != nil {
+			gome.Log.Error(gome.CategoryRender, "could not load post shader", "err", herr)
+		} else {
+			gome.Log.Warn(gome.CategoryRender, "skipping post pass", "err", err)
+		}
+	}
+	pp.shaders[path] = shader
+
+	return shader
+}
+
+// bindOutput binds what a pass draws into: the framebuffer of Begin for the
+// last pass, otherwise an intermediate image that isn't read by the pass.
+func (pp *PostProcessor) bindOutput(pass PostPass, last bool, busy ...*RenderTarget) (*RenderTarget, error) {
+	if last {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, pp.framebuffer)
+		gl.Viewport(pp.viewport[0], pp.viewport[1], pp.viewport[2], pp.viewport[3])
+		return nil, nil
+	}
+
+	scale := pass.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	output, err := pp.target(int(float32(pp.scene.Width)*scale), int(float32(pp.scene.Height)*scale), busy...)
+	if err != nil {
+		return nil, err
+	}
+
+	output.Bind()
+	return output, nil
+}
+
+// target returns an intermediate image of a size that is none of the busy
+// ones, creating it if there is none.
+func (pp *PostProcessor) target(width, height int, busy ...*RenderTarget) (*RenderTarget, error) {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+search:
+	for _, target := range pp.targets {
+		if target.Width != width || target.Height != height {
+			continue
+		}
+		for _, b := range busy {
+			if target == b {
+				continue search
+			}
+		}
+		return target, nil
+	}
+
+	target, err := NewRenderTarget(width, height, RenderTargetOptions{
+		Colors:  []TargetFormat{TARGET_RGBA16F},
+		NoDepth: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pp.targets = append(pp.targets, target)
+
+	return target, nil
+}
+
+// draw draws a pass over the bound framebuffer.
+func (pp *PostProcessor) draw(pass PostPass, image, input *RenderTarget) {
+	shader := pp.shaders[pass.Shader]
+	gl.UseProgram(shader.Program)
+
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, image.Colors[0])
+	shader.SetUniformInt("u_Image", 0)
+	shader.SetUniformFVec2("u_TexelSize", gome.FloatVector2{X: 1 / float32(image.Width), Y: 1 / float32(image.Height)})
+
+	// optional inputs
+	if shader.HasUniform("u_Input") {
+		gl.ActiveTexture(gl.TEXTURE1)
+		gl.BindTexture(gl.TEXTURE_2D, input.Colors[0])
+		shader.SetUniformInt("u_Input", 1)
+	}
+	if shader.HasUniform("u_Depth") {
+		gl.ActiveTexture(gl.TEXTURE2)
+		gl.BindTexture(gl.TEXTURE_2D, pp.scene.Depth)
+		shader.SetUniformInt("u_Depth", 2)
+	}
+
+	for name, value := range pass.Uniforms {
+		shader.SetUniform(name, value)
+	}
+
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+}
+
+// copyScene copies the scene into the framebuffer of Begin unchanged, for when
+// not even the shader encoding the colors could be loaded.
+func (pp *PostProcessor) copyScene() {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, pp.scene.framebuffer)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, pp.framebuffer)
+	gl.BlitFramebuffer(
+		0, 0, int32(pp.scene.Width), int32(pp.scene.Height),
+		pp.viewport[0], pp.viewport[1], pp.viewport[0]+pp.viewport[2], pp.viewport[1]+pp.viewport[3],
+		gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, pp.framebuffer)
+	gl.Viewport(pp.viewport[0], pp.viewport[1], pp.viewport[2], pp.viewport[3])
+}
+
+// deleteTargets deletes the intermediate images.
+func (pp *PostProcessor) deleteTargets() {
+	for _, target := range pp.targets {
+		target.Delete()
+	}
+	pp.targets = nil
+}
+
+// Delete frees the images of the post processor and releases its shaders.
+func (pp *PostProcessor) Delete() {
+	pp.deleteTargets()
+	if pp.scene != nil {
+		pp.scene.Delete()
+		pp.scene = nil
+	}
+	for path, shader := range pp.shaders {
+		if shader != nil {
+			shader.Release()
+		}
+		delete(pp.shaders, path)
+	}
+	gl.DeleteVertexArrays(1, &pp.vertexArray)
+	pp.vertexArray = 0
+}
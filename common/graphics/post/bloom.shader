@@ -0,0 +1,29 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+// u_Input is the image before the bloom effect, u_Image its blurred highlights
+uniform sampler2D u_Input;
+uniform float u_Intensity;
+
+void main() {
+	vec4 image = texture(u_Input, uv);
+	color = vec4(image.rgb + texture(u_Image, uv).rgb * u_Intensity, image.a);
+}
@@ -0,0 +1,36 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+// u_Direction is (1, 0) for a horizontal and (0, 1) for a vertical blur
+uniform vec2 u_Direction;
+
+const float weights[5] = float[](0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216);
+
+// one direction of a separable 9 tap gaussian blur
+void main() {
+	vec2 offset = u_Direction * u_TexelSize;
+	vec4 image = texture(u_Image, uv) * weights[0];
+	for (int i = 1; i < 5; i++) {
+		image += texture(u_Image, uv + offset * float(i)) * weights[i];
+		image += texture(u_Image, uv - offset * float(i)) * weights[i];
+	}
+	color = image;
+}
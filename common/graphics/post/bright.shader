@@ -0,0 +1,37 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+uniform float u_Threshold;
+
+// keeps the parts of the image brighter than the threshold, downsampled with
+// four bilinear lookups
+void main() {
+	vec2 offset = u_TexelSize * 0.5;
+	vec3 image = 0.25 * (
+		texture(u_Image, uv + vec2(-offset.x, -offset.y)).rgb +
+		texture(u_Image, uv + vec2(offset.x, -offset.y)).rgb +
+		texture(u_Image, uv + vec2(-offset.x, offset.y)).rgb +
+		texture(u_Image, uv + vec2(offset.x, offset.y)).rgb);
+
+	float brightness = max(image.r, max(image.g, image.b));
+	float contribution = max(brightness - u_Threshold, 0.0) / max(brightness, 0.0001);
+	color = vec4(image * contribution, 1.0);
+}
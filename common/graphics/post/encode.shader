@@ -0,0 +1,26 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+// encode the linear colors for the screen
+void main() {
+	vec4 image = texture(u_Image, uv);
+	color = vec4(pow(max(image.rgb, 0.0), vec3(1.0 / 2.2)), image.a);
+}
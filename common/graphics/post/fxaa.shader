@@ -0,0 +1,60 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+const float REDUCE_MIN = 1.0 / 128.0;
+const float REDUCE_MUL = 1.0 / 8.0;
+const float SPAN_MAX = 8.0;
+
+// fast approximate antialiasing: blur along the edges found by the luma of
+// the neighbours
+void main() {
+	vec3 luma = vec3(0.299, 0.587, 0.114);
+	float lumaNW = dot(texture(u_Image, uv + vec2(-1.0, -1.0) * u_TexelSize).rgb, luma);
+	float lumaNE = dot(texture(u_Image, uv + vec2(1.0, -1.0) * u_TexelSize).rgb, luma);
+	float lumaSW = dot(texture(u_Image, uv + vec2(-1.0, 1.0) * u_TexelSize).rgb, luma);
+	float lumaSE = dot(texture(u_Image, uv + vec2(1.0, 1.0) * u_TexelSize).rgb, luma);
+	vec4 image = texture(u_Image, uv);
+	float lumaM = dot(image.rgb, luma);
+
+	float lumaMin = min(lumaM, min(min(lumaNW, lumaNE), min(lumaSW, lumaSE)));
+	float lumaMax = max(lumaM, max(max(lumaNW, lumaNE), max(lumaSW, lumaSE)));
+
+	vec2 dir = vec2(
+		-((lumaNW + lumaNE) - (lumaSW + lumaSE)),
+		(lumaNW + lumaSW) - (lumaNE + lumaSE));
+	float dirReduce = max((lumaNW + lumaNE + lumaSW + lumaSE) * 0.25 * REDUCE_MUL, REDUCE_MIN);
+	float rcpDirMin = 1.0 / (min(abs(dir.x), abs(dir.y)) + dirReduce);
+	dir = clamp(dir * rcpDirMin, vec2(-SPAN_MAX), vec2(SPAN_MAX)) * u_TexelSize;
+
+	vec3 rgbA = 0.5 * (
+		texture(u_Image, uv + dir * (1.0 / 3.0 - 0.5)).rgb +
+		texture(u_Image, uv + dir * (2.0 / 3.0 - 0.5)).rgb);
+	vec3 rgbB = rgbA * 0.5 + 0.25 * (
+		texture(u_Image, uv - dir * 0.5).rgb +
+		texture(u_Image, uv + dir * 0.5).rgb);
+
+	float lumaB = dot(rgbB, luma);
+	if (lumaB < lumaMin || lumaB > lumaMax) {
+		color = vec4(rgbA, image.a);
+	} else {
+		color = vec4(rgbB, image.a);
+	}
+}
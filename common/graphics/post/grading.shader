@@ -0,0 +1,35 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+uniform float u_Brightness;
+uniform float u_Contrast;
+uniform float u_Saturation;
+uniform vec3 u_Tint;
+
+void main() {
+	vec4 image = texture(u_Image, uv);
+	vec3 graded = image.rgb * u_Tint + u_Brightness;
+	graded = (graded - 0.5) * u_Contrast + 0.5;
+
+	float luma = dot(graded, vec3(0.2126, 0.7152, 0.0722));
+	graded = mix(vec3(luma), graded, u_Saturation);
+	color = vec4(max(graded, 0.0), image.a);
+}
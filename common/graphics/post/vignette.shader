@@ -0,0 +1,33 @@
+#shader vertex
+#version 330 core
+
+out vec2 uv;
+
+// a single triangle covering the screen, without any vertex buffer
+void main() {
+	uv = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	gl_Position = vec4(uv * 2.0 - 1.0, 0.0, 1.0);
+}
+
+#shader fragment
+#version 330 core
+
+in vec2 uv;
+out vec4 color;
+
+// u_Image is the output of the previous pass, u_TexelSize the size of its pixels
+uniform sampler2D u_Image;
+uniform vec2 u_TexelSize;
+
+uniform float u_Strength;
+uniform float u_Radius;
+uniform float u_Softness;
+
+void main() {
+	vec4 image = texture(u_Image, uv);
+
+	// distance from the center, 1 in the corners
+	float dist = length(uv - 0.5) * sqrt(2.0);
+	float shade = smoothstep(u_Radius, u_Radius + u_Softness, dist);
+	color = vec4(image.rgb * (1.0 - shade * u_Strength), image.a);
+}
@@ -0,0 +1,325 @@
+package graphics
+
+import (
+	"gitlocal/gome"
+	"math"
+)
+
+// The primitives are centered at the origin, with their triangles facing
+// outwards in counter-clockwise order. They have a single submesh with a white
+// default material, uvs, normals and tangents. Use them with
+// RenderComponent.Mesh to draw them without a model file.
+
+/*
+	Primitives
+*/
+
+// Cube returns a cube with edges of a length. Every face is divided into
+// subdivisions x subdivisions quads.
+func Cube(size float32, subdivisions int) *Mesh {
+	subdivisions = atLeast(subdivisions, 1)
+	b := &meshBuilder{}
+
+	// the axes of every face, with u x v = normal
+	faces := [][3]gome.FloatVector3{
+		{{X: 1}, {Z: -1}, {Y: 1}},
+		{{X: -1}, {Z: 1}, {Y: 1}},
+		{{Y: 1}, {X: 1}, {Z: -1}},
+		{{Y: -1}, {X: 1}, {Z: 1}},
+		{{Z: 1}, {X: 1}, {Y: 1}},
+		{{Z: -1}, {X: -1}, {Y: 1}},
+	}
+	for _, face := range faces {
+		normal, uAxis, vAxis := face[0], face[1], face[2]
+		b.grid(subdivisions, subdivisions, normal, func(u, v float32) gome.FloatVector3 {
+			return add(scale(normal, size/2), scale(uAxis, (u-0.5)*size), scale(vAxis, (v-0.5)*size))
+		})
+	}
+
+	return b.finish()
+}
+
+// Plane returns a plane in the XZ plane facing up, divided into a grid of
+// columns x rows quads.
+func Plane(width, depth float32, columns, rows int) *Mesh {
+	b := &meshBuilder{}
+	b.grid(atLeast(columns, 1), atLeast(rows, 1), gome.FloatVector3{Y: 1}, func(u, v float32) gome.FloatVector3 {
+		return gome.FloatVector3{X: (u - 0.5) * width, Z: (0.5 - v) * depth}
+	})
+
+	return b.finish()
+}
+
+// Sphere returns a UV sphere with segments around its vertical axis and rings
+// from pole to pole.
+func Sphere(radius float32, segments, rings int) *Mesh {
+	rings = atLeast(rings, 2)
+
+	profile := make([]latheRing, rings+1)
+	for i := range profile {
+		v := float32(i) / float32(rings)
+		sin, cos := sincos(float64(v)*math.Pi - math.Pi/2)
+		profile[i] = latheRing{
+			radius: radius * cos,
+			y:      radius * sin,
+			normal: gome.FloatVector2{X: cos, Y: sin},
+			v:      v,
+		}
+	}
+
+	b := &meshBuilder{}
+	b.lathe(atLeast(segments, 3), profile)
+	return b.finish()
+}
+
+// Capsule returns a cylinder of a height with hemispheres on both ends, so the
+// total height is height + 2 * radius. Every hemisphere has rings from its pole
+// to the cylinder.
+func Capsule(radius, height float32, segments, rings int) *Mesh {
+	rings = atLeast(rings, 1)
+	total := height + 2*radius
+
+	profile := []latheRing{}
+	for _, hemisphere := range []struct {
+		center     float32
+		start, end float64
+	}{
+		{-height / 2, -math.Pi / 2, 0},
+		{height / 2, 0, math.Pi / 2},
+	} {
+		for i := 0; i <= rings; i++ {
+			sin, cos := sincos(hemisphere.start + (hemisphere.end-hemisphere.start)*float64(i)/float64(rings))
+			y := hemisphere.center + radius*sin
+			profile = append(profile, latheRing{
+				radius: radius * cos,
+				y:      y,
+				normal: gome.FloatVector2{X: cos, Y: sin},
+				v:      (y + total/2) / total,
+			})
+		}
+	}
+
+	b := &meshBuilder{}
+	b.lathe(atLeast(segments, 3), profile)
+	return b.finish()
+}
+
+// Cylinder returns a closed cylinder with segments around its vertical axis.
+func Cylinder(radius, height float32, segments int) *Mesh {
+	segments = atLeast(segments, 3)
+
+	b := &meshBuilder{}
+	b.lathe(segments, []latheRing{
+		{radius: radius, y: -height / 2, normal: gome.FloatVector2{X: 1}, v: 0},
+		{radius: radius, y: height / 2, normal: gome.FloatVector2{X: 1}, v: 1},
+	})
+	b.disc(radius, -height/2, segments, false)
+	b.disc(radius, height/2, segments, true)
+	return b.finish()
+}
+
+// Cone returns a cone with its base at the bottom and its tip at the top.
+func Cone(radius, height float32, segments int) *Mesh {
+	segments = atLeast(segments, 3)
+
+	// the normal of the sloped side
+	length := float32(math.Hypot(float64(height), float64(radius)))
+	normal := gome.FloatVector2{X: height / length, Y: radius / length}
+
+	b := &meshBuilder{}
+	b.lathe(segments, []latheRing{
+		{radius: radius, y: -height / 2, normal: normal, v: 0},
+		{radius: 0, y: height / 2, normal: normal, v: 1},
+	})
+	b.disc(radius, -height/2, segments, false)
+	return b.finish()
+}
+
+// Torus returns a ring around the vertical axis. radius is the distance from
+// the center to the middle of the tube, segments go around the ring and sides
+// around the tube.
+func Torus(radius, tube float32, segments, sides int) *Mesh {
+	sides = atLeast(sides, 3)
+
+	profile := make([]latheRing, sides+1)
+	for i := range profile {
+		v := float32(i) / float32(sides)
+		sin, cos := sincos(float64(v) * 2 * math.Pi)
+		profile[i] = latheRing{
+			radius: radius + tube*cos,
+			y:      tube * sin,
+			normal: gome.FloatVector2{X: cos, Y: sin},
+			v:      v,
+		}
+	}
+
+	b := &meshBuilder{}
+	b.lathe(atLeast(segments, 3), profile)
+	return b.finish()
+}
+
+/*
+	Builder
+*/
+
+// A meshBuilder collects the vertices and triangles of a primitive.
+type meshBuilder struct {
+	mesh Mesh
+}
+
+// vertex adds a vertex and returns its index.
+func (b *meshBuilder) vertex(position, normal gome.FloatVector3, uv gome.FloatVector2) uint32 {
+	b.mesh.Positions = append(b.mesh.Positions, position)
+	b.mesh.Normals = append(b.mesh.Normals, normal)
+	b.mesh.UVs = append(b.mesh.UVs, uv)
+	return uint32(len(b.mesh.Positions) - 1)
+}
+
+// quad adds two triangles for four vertices in counter-clockwise order.
+func (b *meshBuilder) quad(v0, v1, v2, v3 uint32) {
+	b.mesh.Indices = append(b.mesh.Indices, v0, v1, v2, v0, v2, v3)
+}
+
+// grid adds a flat grid of columns x rows quads. The position function maps
+// uvs to positions, with the u and v directions crossing to the normal.
+func (b *meshBuilder) grid(columns, rows int, normal gome.FloatVector3, position func(u, v float32) gome.FloatVector3) {
+	first := uint32(len(b.mesh.Positions))
+	for row := 0; row <= rows; row++ {
+		for column := 0; column <= columns; column++ {
+			u, v := float32(column)/float32(columns), float32(row)/float32(rows)
+			b.vertex(position(u, v), normal, gome.FloatVector2{X: u, Y: v})
+		}
+	}
+
+	stride := uint32(columns + 1)
+	for row := uint32(0); row < uint32(rows); row++ {
+		for column := uint32(0); column < uint32(columns); column++ {
+			v0 := first + row*stride + column
+			b.quad(v0, v0+1, v0+stride+1, v0+stride)
+		}
+	}
+}
+
+// A latheRing is a ring of a surface of revolution.
+type latheRing struct {
+	radius, y float32
+
+	// normal is the normal in the plane of the profile: X points away from
+	// the axis, Y up.
+	normal gome.FloatVector2
+
+	// v is the v coordinate of the uvs of the ring.
+	v float32
+}
+
+// lathe adds a surface of revolution around the Y axis. The rings of the
+// profile go from bottom to top along the outside of the surface.
+func (b *meshBuilder) lathe(segments int, profile []latheRing) {
+	first := uint32(len(b.mesh.Positions))
+	for _, ring := range profile {
+		// the first and last vertex of a ring are at the same position, but
+		// have different uvs
+		for segment := 0; segment <= segments; segment++ {
+			u := float32(segment) / float32(segments)
+			sin, cos := sincos(float64(u) * 2 * math.Pi)
+			x, z := cos, -sin
+
+			b.vertex(
+				gome.FloatVector3{X: ring.radius * x, Y: ring.y, Z: ring.radius * z},
+				gome.FloatVector3{X: ring.normal.X * x, Y: ring.normal.Y, Z: ring.normal.X * z},
+				gome.FloatVector2{X: u, Y: ring.v},
+			)
+		}
+	}
+
+	stride := uint32(segments + 1)
+	for ring := uint32(0); ring+1 < uint32(len(profile)); ring++ {
+		bottom, top := profile[ring].radius == 0, profile[ring+1].radius == 0
+
+		for segment := uint32(0); segment < uint32(segments); segment++ {
+			v0 := first + ring*stride + segment
+
+			// rings at the axis (e.g. the poles of a sphere) only need one
+			// triangle per segment
+			switch {
+			case bottom && top:
+			case bottom:
+				b.mesh.Indices = append(b.mesh.Indices, v0, v0+stride+1, v0+stride)
+			case top:
+				b.mesh.Indices = append(b.mesh.Indices, v0, v0+1, v0+stride+1)
+			default:
+				b.quad(v0, v0+1, v0+stride+1, v0+stride)
+			}
+		}
+	}
+}
+
+// disc adds a horizontal disc facing up or down.
+func (b *meshBuilder) disc(radius, y float32, segments int, up bool) {
+	normal := gome.FloatVector3{Y: -1}
+	if up {
+		normal.Y = 1
+	}
+
+	center := b.vertex(gome.FloatVector3{Y: y}, normal, gome.FloatVector2{X: 0.5, Y: 0.5})
+	for segment := 0; segment <= segments; segment++ {
+		sin, cos := sincos(float64(segment) / float64(segments) * 2 * math.Pi)
+		x, z := cos, -sin
+		b.vertex(
+			gome.FloatVector3{X: radius * x, Y: y, Z: radius * z},
+			normal,
+			gome.FloatVector2{X: 0.5 + x/2, Y: 0.5 - z/2*normal.Y},
+		)
+	}
+
+	for segment := uint32(1); segment <= uint32(segments); segment++ {
+		if up {
+			b.mesh.Indices = append(b.mesh.Indices, center, center+segment, center+segment+1)
+		} else {
+			b.mesh.Indices = append(b.mesh.Indices, center, center+segment+1, center+segment)
+		}
+	}
+}
+
+// finish adds the submesh, tangents and bounds and returns the mesh.
+func (b *meshBuilder) finish() *Mesh {
+	mesh := &b.mesh
+	material := NewMaterial("")
+	mesh.Materials = map[string]*Material{material.Name: material}
+	mesh.Submeshes = []Submesh{{First: 0, Count: len(mesh.Indices), Material: material}}
+
+	mesh.GenerateTangents()
+	mesh.UpdateBounds()
+	return mesh
+}
+
+// sincos returns the sine and cosine of an angle, with rounding errors around
+// zero removed, so e.g. the poles of a sphere are exactly on its axis.
+func sincos(angle float64) (float32, float32) {
+	sin, cos := math.Sincos(angle)
+	if math.Abs(sin) < 1e-9 {
+		sin = 0
+	}
+	if math.Abs(cos) < 1e-9 {
+		cos = 0
+	}
+	return float32(sin), float32(cos)
+}
+
+func atLeast(value, min int) int {
+	if value < min {
+		return min
+	}
+	return value
+}
+
+func add(vectors ...gome.FloatVector3) (sum gome.FloatVector3) {
+	for _, v := range vectors {
+		sum = gome.FloatVector3{X: sum.X + v.X, Y: sum.Y + v.Y, Z: sum.Z + v.Z}
+	}
+	return
+}
+
+func scale(v gome.FloatVector3, factor float32) gome.FloatVector3 {
+	return gome.FloatVector3{X: v.X * factor, Y: v.Y * factor, Z: v.Z * factor}
+}
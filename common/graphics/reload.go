@@ -0,0 +1,169 @@
+package graphics
+
+import (
+	"errors"
+	"gitlocal/gome"
+	"time"
+)
+
+// hotReload holds the state of the file watching of an AssetManager.
+type hotReload struct {
+	interval  time.Duration
+	lastCheck time.Time
+}
+
+// EnableHotReload makes Poll check the files of all cached assets for changes
+// every interval, and reload changed assets in place. Meshes are reloaded when
+// one of their material libraries changes as well. If a changed file can't
+// be loaded (e.g. because a shader doesn't compile or a texture of a mesh is
+// missing), the old version is kept.
+// An interval of 0 disables hot reloading.
+func (am *AssetManager) EnableHotReload(interval time.Duration) {
+	am.reload.interval = interval
+	am.reload.lastCheck = time.Now()
+}
+
+// modTime returns the modification time of a file, or the zero time if
+// it doesn't exist.
+func modTime(path string) time.Time {
+	info, err := gome.Files.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// checkReload reloads all assets whose file changed since they were loaded.
+func (am *AssetManager) checkReload() {
+	if am.reload.interval <= 0 || time.Since(am.reload.lastCheck) < am.reload.interval {
+		return
+	}
+	am.reload.lastCheck = time.Now()
+
+	for _, shader := range am.shaders {
+		if shader.changed() {
+			am.reloadShader(shader)
+		}
+	}
+	for _, texture := range am.textures {
+		if texture.state != AssetLoading && texture.changed() {
+			am.reloadTexture(texture)
+		}
+	}
+	for _, mesh := range am.meshes {
+		if mesh.state != AssetLoading && mesh.changed() {
+			am.reloadMesh(mesh)
+		}
+	}
+}
+
+// changed checks if the file of the asset was modified since it got loaded,
+// and remembers the new modification time.
+func (a *asset) changed() bool {
+	current := modTime(a.path)
+	if current.IsZero() || !current.After(a.modTime) {
+		return false
+	}
+
+	a.modTime = current
+	return true
+}
+
+// changed checks if the .obj file or one of the material libraries of the mesh
+// was modified since the mesh got loaded.
+func (ma *MeshAsset) changed() bool {
+	changed := ma.asset.changed()
+	for library, last := range ma.libraries {
+		if current := modTime(library); current.After(last) {
+			ma.libraries[library] = current
+			changed = true
+		}
+	}
+	return changed
+}
+
+// reloadShader recompiles a shader, keeping the old program on error.
+func (am *AssetManager) reloadShader(shader *ShaderAsset) {
+	file, err := gome.Files.Open(shader.path)
+	if err != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not reload shader", "path", shader.path, "err", err)
+		return
+	}
+	defer file.Close()
+
+	reloaded := Shader{}
+	if err := reloaded.Init(file); err != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not reload shader", "path", shader.path, "err", err)
+		return
+	}
+
+	old := shader.Shader
+	shader.Shader = reloaded
+	old.Delete()
+
+	gome.Log.Info(gome.CategoryAssets, "reloaded shader", "path", shader.path)
+}
+
+// reloadTexture uploads a changed image, keeping the old texture on error.
+func (am *AssetManager) reloadTexture(texture *TextureAsset) {
+	file, err := gome.Files.Open(texture.path)
+	if err != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not reload texture", "path", texture.path, "err", err)
+		return
+	}
+	defer file.Close()
+
+	rgba, err := decodeImage(file)
+	if err != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not reload texture", "path", texture.path, "err", err)
+		return
+	}
+
+	// a texture that failed to load shows a placeholder, which is not ours to delete
+	texture.free()
+	texture.ID = uploadTexture(rgba)
+	texture.state = AssetReady
+	texture.err = nil
+
+	gome.Log.Info(gome.CategoryAssets, "reloaded texture", "path", texture.path)
+}
+
+// reloadMesh parses and uploads a changed mesh, keeping the old one on error.
+func (am *AssetManager) reloadMesh(mesh *MeshAsset) {
+	file, err := gome.Files.Open(mesh.path)
+	if err != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not reload mesh", "path", mesh.path, "err", err)
+		return
+	}
+	defer file.Close()
+
+	reader := &OBJFileReader{Dir: assetDir(mesh.path)}
+	data, err := reader.Parse(file)
+	if err != nil {
+		gome.Log.Error(gome.CategoryAssets, "could not reload mesh", "path", mesh.path, "err", err)
+		return
+	}
+
+	// the materials may have changed as well. Load the new textures before
+	// releasing the old ones, so unchanged textures stay cached.
+	parts, err := am.loadParts(data.Submeshes, false)
+	if err != nil {
+		texture := ""
+		var aerr *gome.AssetError
+		if errors.As(err, &aerr) {
+			texture = aerr.Path
+		}
+		gome.Log.Error(gome.CategoryAssets, "could not reload mesh texture, keeping the old mesh",
+			"path", mesh.path, "texture", texture, "err", err)
+		releaseParts(parts)
+		return
+	}
+
+	mesh.free()
+	mesh.upload(data)
+	mesh.Parts = parts
+	mesh.state = AssetReady
+	mesh.err = nil
+
+	gome.Log.Info(gome.CategoryAssets, "reloaded mesh", "path", mesh.path)
+}
@@ -81,6 +81,9 @@ func (s *Shader) Init(shader io.Reader) (err error) {
 		if len(shader) > 0 {
 			shaderptr, err := s.compile(shader+"\x00", shaderType)
 			if err != nil {
+				for _, compiled := range shaders {
+					gl.DeleteShader(compiled)
+				}
 				return err
 			}
 
@@ -106,9 +109,34 @@ func (s *Shader) Init(shader io.Reader) (err error) {
 		gl.DeleteShader(shader)
 	}
 
+	// check if linking failed
+	var status int32
+	gl.GetProgramiv(s.Program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		var logLength int32
+		gl.GetProgramiv(s.Program, gl.INFO_LOG_LENGTH, &logLength)
+
+		log := strings.Repeat("\x00", int(logLength+1))
+		gl.GetProgramInfoLog(s.Program, logLength, nil, gl.Str(log))
+
+		gl.DeleteProgram(s.Program)
+		s.Program = 0
+		return errors.New("Failed to link OpenGL program:\n" + log)
+	}
+
 	return
 }
 
+// Delete frees the shader program and all uniform buffer objects.
+func (s *Shader) Delete() {
+	for _, ubo := range s.uniformBOs {
+		gl.DeleteBuffers(1, &ubo)
+	}
+	gl.DeleteProgram(s.Program)
+
+	*s = Shader{}
+}
+
 // compile compiles a shader from source and returns the shader ID and, if occurred,
 // an error. The shaderType can be any OpenGL shader type, e.g. gl.VERTEX_SHADER
 func (s *Shader) compile(source string, shaderType uint32) (shader uint32, err error) {
@@ -134,6 +162,7 @@ func (s *Shader) compile(source string, shaderType uint32) (shader uint32, err e
 
 		// set error message
 		err = errors.New("Failed to compile OpenGL shader:\n" + log)
+		gl.DeleteShader(shader)
 	}
 
 	return
@@ -147,19 +176,28 @@ func (s *Shader) getUniformLocation(name string) (location int32) {
 		return location
 	}
 
-	// if it's not in our location cache, get it from opengl and save it in the cache
+	// if it's not in our location cache, get it from opengl and save it in the cache.
+	// Missing uniforms are cached as well, so they are only warned about once.
 	location = gl.GetUniformLocation(s.Program, gl.Str(name+"\x00"))
 	if location == -1 {
-		fmt.Println("ERROR: Could not find uniform:", name)
-		return
+		gome.Log.Warn(gome.CategoryRender, "could not find uniform", "name", name)
 	}
 
 	s.uniformLocs[name] = location
 	return
 }
 
-// getUniformLocation gets the location of a uniform in the shader.
-// May return -1 if the uniform is not found.
+// HasUniform returns whether the shader has an active uniform, without
+// warning if it doesn't.
+func (s *Shader) HasUniform(name string) bool {
+	if location, ok := s.uniformLocs[name]; ok {
+		return location != -1
+	}
+	return gl.GetUniformLocation(s.Program, gl.Str(name+"\x00")) != -1
+}
+
+// getUniformBlockLocation gets the index of a uniform block in the shader.
+// May return gl.INVALID_INDEX if the block is not found.
 func (s *Shader) getUniformBlockLocation(name string) (index uint32) {
 	// if we already saved the location, return it
 	if index, ok := s.uniformBIndices[name]; ok {
@@ -168,10 +206,31 @@ func (s *Shader) getUniformBlockLocation(name string) (index uint32) {
 
 	// if it's not in our index cache, get it from opengl and save it in the cache
 	index = gl.GetUniformBlockIndex(s.Program, gl.Str(name+"\x00"))
+	if index == gl.INVALID_INDEX {
+		gome.Log.Warn(gome.CategoryRender, "could not find uniform block", "name", name)
+		return
+	}
+
 	s.uniformBIndices[name] = index
 	return
 }
 
+// Sets a uniform value.
+func (s *Shader) SetUniformInt(name string, value int32) {
+	loc := s.getUniformLocation(name)
+	if loc != -1 {
+		gl.Uniform1i(loc, value)
+	}
+}
+
+// Sets a uniform value.
+func (s *Shader) SetUniformFloat(name string, value float32) {
+	loc := s.getUniformLocation(name)
+	if loc != -1 {
+		gl.Uniform1f(loc, value)
+	}
+}
+
 // Sets a uniform value.
 func (s *Shader) SetUniformFVec2(name string, value gome.FloatVector2) {
 	loc := s.getUniformLocation(name)
@@ -188,6 +247,14 @@ func (s *Shader) SetUniformFVec3(name string, value gome.FloatVector3) {
 	}
 }
 
+// Sets a uniform array value.
+func (s *Shader) SetUniformFVec3Array(name string, value []gome.FloatVector3) {
+	loc := s.getUniformLocation(name)
+	if loc != -1 && len(value) > 0 {
+		gl.Uniform3fv(loc, int32(len(value)), &value[0].X)
+	}
+}
+
 // Sets a uniform value.
 func (s *Shader) SetUniformFVec4(name string, value gome.FloatVector4) {
 	loc := s.getUniformLocation(name)
@@ -228,19 +295,81 @@ func (s *Shader) SetUniformFMat4(name string, value mgl32.Mat4) {
 	}
 }
 
-// SetUniformBlock sets the data of an active named uniform block.
+// Sets a uniform value.
+func (s *Shader) SetUniformFMat3(name string, value mgl32.Mat3) {
+	loc := s.getUniformLocation(name)
+	if loc != -1 {
+		gl.UniformMatrix3fv(loc, 1, false, &value[0])
+	}
+}
+
+// Sets a uniform array value.
+func (s *Shader) SetUniformFMat4Array(name string, value []mgl32.Mat4) {
+	loc := s.getUniformLocation(name)
+	if loc != -1 && len(value) > 0 {
+		gl.UniformMatrix4fv(loc, int32(len(value)), false, &value[0][0])
+	}
+}
+
+// SetUniformBlock sets the data of an active named uniform block. value has
+// to be a pointer or slice to data of size bytes in the layout of the block.
 func (s *Shader) SetUniformBlock(name string, value interface{}, size int) {
+	index := s.getUniformBlockLocation(name)
+	if index == gl.INVALID_INDEX {
+		return
+	}
+
 	// check if the uniform buffer object already exists
 	ubo, ok := s.uniformBOs[name]
 	if !ok {
 		// if not, generate one
 		gl.GenBuffers(1, &ubo)
 		s.uniformBOs[name] = ubo
+
+		// every block gets the binding point of its index
+		gl.UniformBlockBinding(s.Program, index, index)
 	}
 
 	gl.BindBuffer(gl.UNIFORM_BUFFER, ubo)
 
 	// set the new data
-	gl.BufferData(gl.UNIFORM_BUFFER, size, nil, gl.DYNAMIC_DRAW)
-	gl.BindBufferBase(gl.UNIFORM_BUFFER, s.getUniformBlockLocation(name), ubo)
+	gl.BufferData(gl.UNIFORM_BUFFER, size, gl.Ptr(value), gl.DYNAMIC_DRAW)
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, index, ubo)
+}
+
+// SetUniform sets a uniform to a value of any type with a typed setter:
+// bool, int, int32, float32, the vectors of gome and mgl32.Mat3 and Mat4.
+func (s *Shader) SetUniform(name string, value interface{}) {
+	switch value := value.(type) {
+	case bool:
+		if value {
+			s.SetUniformInt(name, 1)
+		} else {
+			s.SetUniformInt(name, 0)
+		}
+	case int:
+		s.SetUniformInt(name, int32(value))
+	case int32:
+		s.SetUniformInt(name, value)
+	case float32:
+		s.SetUniformFloat(name, value)
+	case gome.FloatVector2:
+		s.SetUniformFVec2(name, value)
+	case gome.FloatVector3:
+		s.SetUniformFVec3(name, value)
+	case gome.FloatVector4:
+		s.SetUniformFVec4(name, value)
+	case gome.Vector2:
+		s.SetUniformVec2(name, value)
+	case gome.Vector3:
+		s.SetUniformVec3(name, value)
+	case gome.Vector4:
+		s.SetUniformVec4(name, value)
+	case mgl32.Mat3:
+		s.SetUniformFMat3(name, value)
+	case mgl32.Mat4:
+		s.SetUniformFMat4(name, value)
+	default:
+		gome.Log.Warn(gome.CategoryRender, "unsupported uniform type", "name", name, "type", fmt.Sprintf("%T", value))
+	}
 }
@@ -0,0 +1,130 @@
+package graphics
+
+import (
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	ShadowMap
+*/
+
+// A ShadowMap is a depth texture a light renders the scene into, to find the
+// surfaces it can't reach. It is either an array of 2D layers, e.g. for the
+// cascades of a directional light, or a cubemap for a point light. Both are
+// set up for comparison sampling (sampler2DArrayShadow and samplerCubeShadow).
+type ShadowMap struct {
+	Texture uint32
+	Size    int
+	Layers  int
+	Cube    bool
+
+	framebuffer uint32
+}
+
+// NewShadowMap creates a shadow map of layers square 2D layers.
+func NewShadowMap(size, layers int) (*ShadowMap, error) {
+	sm := &ShadowMap{Size: size, Layers: layers}
+
+	gl.GenTextures(1, &sm.Texture)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, sm.Texture)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.DEPTH_COMPONENT32F, int32(size), int32(size), int32(layers), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	setShadowParameters(gl.TEXTURE_2D_ARRAY)
+
+	// everything outside of the map is lit
+	border := []float32{1, 1, 1, 1}
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	gl.TexParameterfv(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_BORDER_COLOR, &border[0])
+
+	return sm, sm.initFramebuffer()
+}
+
+// NewCubeShadowMap creates a cube shadow map with faces of a size. The faces
+// are the layers 0 to 5, in the order of the cubemap faces.
+func NewCubeShadowMap(size int) (*ShadowMap, error) {
+	sm := &ShadowMap{Size: size, Layers: 6, Cube: true}
+
+	gl.GenTextures(1, &sm.Texture)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, sm.Texture)
+	for face := uint32(0); face < 6; face++ {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+face, 0, gl.DEPTH_COMPONENT32F, int32(size), int32(size), 0, gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	}
+	setShadowParameters(gl.TEXTURE_CUBE_MAP)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+
+	return sm, sm.initFramebuffer()
+}
+
+// setShadowParameters makes a depth texture compare the depth of lookups with
+// the stored one, with linear filtering for smoother edges.
+func setShadowParameters(target uint32) {
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_COMPARE_MODE, gl.COMPARE_REF_TO_TEXTURE)
+	gl.TexParameteri(target, gl.TEXTURE_COMPARE_FUNC, gl.LEQUAL)
+}
+
+// initFramebuffer creates a depth-only framebuffer for the map.
+func (sm *ShadowMap) initFramebuffer() error {
+	gl.GenFramebuffers(1, &sm.framebuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, sm.framebuffer)
+	sm.attach(0)
+	gl.DrawBuffer(gl.NONE)
+	gl.ReadBuffer(gl.NONE)
+
+	err := checkFramebuffer("shadow map")
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	if err != nil {
+		sm.Delete()
+	}
+
+	return err
+}
+
+// attach attaches a layer of the map to the bound framebuffer.
+func (sm *ShadowMap) attach(layer int) {
+	if sm.Cube {
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(layer), sm.Texture, 0)
+	} else {
+		gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, sm.Texture, 0, int32(layer))
+	}
+}
+
+// Bind makes a layer of the map the target of draw calls and clears it.
+// Unbind the framebuffer and restore the viewport when done.
+func (sm *ShadowMap) Bind(layer int) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, sm.framebuffer)
+	sm.attach(layer)
+	gl.Viewport(0, 0, int32(sm.Size), int32(sm.Size))
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+}
+
+// Delete frees the texture and framebuffer of the map.
+func (sm *ShadowMap) Delete() {
+	gl.DeleteFramebuffers(1, &sm.framebuffer)
+	gl.DeleteTextures(1, &sm.Texture)
+	sm.framebuffer, sm.Texture = 0, 0
+}
+
+// CubeFaceViews returns the view matrices of the faces of a cubemap seen from
+// a position, in the order of the cubemap faces. Combine them with a square
+// projection with a field of view of 90 degrees.
+func CubeFaceViews(position mgl32.Vec3) [6]mgl32.Mat4 {
+	faces := [6][2]mgl32.Vec3{
+		{{1, 0, 0}, {0, -1, 0}},
+		{{-1, 0, 0}, {0, -1, 0}},
+		{{0, 1, 0}, {0, 0, 1}},
+		{{0, -1, 0}, {0, 0, -1}},
+		{{0, 0, 1}, {0, -1, 0}},
+		{{0, 0, -1}, {0, -1, 0}},
+	}
+
+	views := [6]mgl32.Mat4{}
+	for i, face := range faces {
+		views[i] = mgl32.LookAtV(position, position.Add(face[0]), face[1])
+	}
+	return views
+}
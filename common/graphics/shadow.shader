@@ -0,0 +1,52 @@
+#shader vertex
+#version 330 core
+
+layout(location = 0) in vec3 vertex_pos;
+layout(location = 4) in vec4 vertex_joints;
+layout(location = 5) in vec4 vertex_weights;
+
+// the model matrix of instanced draws, one per instance
+layout(location = 6) in mat4 instance_model;
+
+out vec3 position;
+
+uniform mat4 u_LightMatrix;
+uniform mat4 u_Model;
+uniform int u_Instanced;
+
+// skinned meshes get deformed by up to 4 of the joints
+uniform int u_Skinned;
+uniform mat4 u_Joints[64];
+
+void main() {
+	mat4 skin = mat4(1.0);
+	if (u_Skinned != 0 && dot(vertex_weights, vec4(1.0)) > 0.0) {
+		skin = vertex_weights.x * u_Joints[int(vertex_joints.x)] +
+			vertex_weights.y * u_Joints[int(vertex_joints.y)] +
+			vertex_weights.z * u_Joints[int(vertex_joints.z)] +
+			vertex_weights.w * u_Joints[int(vertex_joints.w)];
+	}
+
+	mat4 model = u_Instanced != 0 ? instance_model : u_Model;
+	vec4 world = model * skin * vec4(vertex_pos, 1.0);
+	position = world.xyz;
+	gl_Position = u_LightMatrix * world;
+}
+
+#shader fragment
+#version 330 core
+
+in vec3 position;
+
+// point lights store the distance to the light divided by the far plane
+uniform int u_LinearDepth;
+uniform vec3 u_LightPosition;
+uniform float u_Far;
+
+void main() {
+	if (u_LinearDepth != 0) {
+		gl_FragDepth = length(position - u_LightPosition) / u_Far;
+	} else {
+		gl_FragDepth = gl_FragCoord.z;
+	}
+}
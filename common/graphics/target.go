@@ -0,0 +1,284 @@
+package graphics
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// A TargetFormat is the format of an attachment of a render target.
+type TargetFormat uint32
+
+const (
+	TARGET_RGBA8    = TargetFormat(gl.RGBA8)
+	TARGET_RGBA16F  = TargetFormat(gl.RGBA16F)
+	TARGET_RGBA32F  = TargetFormat(gl.RGBA32F)
+	TARGET_R32UI    = TargetFormat(gl.R32UI) // one integer per pixel, e.g. IDs
+	TARGET_DEPTH24  = TargetFormat(gl.DEPTH_COMPONENT24)
+	TARGET_DEPTH32F = TargetFormat(gl.DEPTH_COMPONENT32F)
+)
+
+// pixelFormat returns the format and type of the pixels of a texture in the
+// format.
+func (tf TargetFormat) pixelFormat() (format, xtype uint32) {
+	switch tf {
+	case TARGET_RGBA8:
+		return gl.RGBA, gl.UNSIGNED_BYTE
+	case TARGET_R32UI:
+		return gl.RED_INTEGER, gl.UNSIGNED_INT
+	case TARGET_DEPTH24, TARGET_DEPTH32F:
+		return gl.DEPTH_COMPONENT, gl.FLOAT
+	default:
+		return gl.RGBA, gl.FLOAT
+	}
+}
+
+/*
+	RenderTarget
+*/
+
+// RenderTargetOptions describe the attachments of a render target.
+type RenderTargetOptions struct {
+	// Colors are the formats of the color attachments. There is one
+	// TARGET_RGBA8 attachment if it is empty.
+	Colors []TargetFormat
+
+	// Depth is the format of the depth attachment, TARGET_DEPTH24 if 0.
+	Depth TargetFormat
+
+	// NoDepth leaves out the depth attachment, e.g. for post-processing.
+	NoDepth bool
+
+	// Samples is the number of samples per pixel for multisample antialiasing.
+	// 0 disables it.
+	Samples int
+}
+
+// A RenderTarget is a framebuffer draw calls can render into instead of the
+// window, with textures holding the result. Multisampled targets draw into
+// separate buffers, which Resolve copies into the textures.
+type RenderTarget struct {
+	Width, Height int
+
+	// Colors are the textures of the color attachments, Depth the one of the
+	// depth attachment or 0. They keep their IDs when the target is resized.
+	Colors []uint32
+	Depth  uint32
+
+	options       RenderTargetOptions
+	framebuffer   uint32
+	multisample   uint32
+	renderbuffers []uint32
+}
+
+// NewRenderTarget creates a render target of a size in pixels.
+func NewRenderTarget(width, height int, options RenderTargetOptions) (*RenderTarget, error) {
+	if len(options.Colors) == 0 {
+		options.Colors = []TargetFormat{TARGET_RGBA8}
+	}
+	if options.Depth == 0 {
+		options.Depth = TARGET_DEPTH24
+	}
+
+	// the driver may not support as many samples
+	if options.Samples > 0 {
+		var max int32
+		gl.GetIntegerv(gl.MAX_SAMPLES, &max)
+		if options.Samples > int(max) {
+			options.Samples = int(max)
+		}
+	}
+
+	rt := &RenderTarget{options: options}
+	gl.GenFramebuffers(1, &rt.framebuffer)
+	rt.Colors = make([]uint32, len(options.Colors))
+	gl.GenTextures(int32(len(rt.Colors)), &rt.Colors[0])
+	if !options.NoDepth {
+		gl.GenTextures(1, &rt.Depth)
+	}
+
+	if options.Samples > 0 {
+		gl.GenFramebuffers(1, &rt.multisample)
+		rt.renderbuffers = make([]uint32, len(rt.Colors))
+		if !options.NoDepth {
+			rt.renderbuffers = append(rt.renderbuffers, 0)
+		}
+		gl.GenRenderbuffers(int32(len(rt.renderbuffers)), &rt.renderbuffers[0])
+	}
+
+	if err := rt.Resize(width, height); err != nil {
+		rt.Delete()
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+// Samples returns the number of samples per pixel, 0 if the target is not
+// multisampled.
+func (rt *RenderTarget) Samples() int { return rt.options.Samples }
+
+// Resize changes the size of the attachments. Their content is lost.
+func (rt *RenderTarget) Resize(width, height int) error {
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	rt.Width, rt.Height = width, height
+
+	previous := boundFramebuffer()
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, previous)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, rt.framebuffer)
+	for i, texture := range rt.Colors {
+		specifyTargetTexture(texture, rt.options.Colors[i], width, height)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.TEXTURE_2D, texture, 0)
+	}
+	if rt.Depth != 0 {
+		specifyTargetTexture(rt.Depth, rt.options.Depth, width, height)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, rt.Depth, 0)
+	}
+	rt.setDrawBuffers()
+	if err := checkFramebuffer("render target"); err != nil {
+		return err
+	}
+
+	if rt.multisample == 0 {
+		return nil
+	}
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, rt.multisample)
+	for i, renderbuffer := range rt.renderbuffers {
+		format, attachment := rt.options.Depth, uint32(gl.DEPTH_ATTACHMENT)
+		if i < len(rt.Colors) {
+			format, attachment = rt.options.Colors[i], gl.COLOR_ATTACHMENT0+uint32(i)
+		}
+
+		gl.BindRenderbuffer(gl.RENDERBUFFER, renderbuffer)
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, int32(rt.options.Samples), uint32(format), int32(width), int32(height))
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, attachment, gl.RENDERBUFFER, renderbuffer)
+	}
+	rt.setDrawBuffers()
+	return checkFramebuffer("multisampled render target")
+}
+
+// specifyTargetTexture (re)allocates the storage of an attachment texture.
+func specifyTargetTexture(texture uint32, format TargetFormat, width, height int) {
+	pixelFormat, pixelType := format.pixelFormat()
+
+	// integers can't be interpolated
+	filter := int32(gl.LINEAR)
+	if format == TARGET_R32UI {
+		filter = gl.NEAREST
+	}
+
+	gl.BindTexture(gl.TEXTURE_2D, texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, int32(format), int32(width), int32(height), 0, pixelFormat, pixelType, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, filter)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+}
+
+// setDrawBuffers makes draw calls write to all color attachments of the bound
+// framebuffer.
+func (rt *RenderTarget) setDrawBuffers() {
+	buffers := make([]uint32, len(rt.Colors))
+	for i := range buffers {
+		buffers[i] = gl.COLOR_ATTACHMENT0 + uint32(i)
+	}
+	gl.DrawBuffers(int32(len(buffers)), &buffers[0])
+}
+
+// checkFramebuffer returns an error if the bound framebuffer is incomplete.
+func checkFramebuffer(name string) error {
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("%s framebuffer incomplete: 0x%x", name, status)
+	}
+	return nil
+}
+
+// boundFramebuffer returns the framebuffer draw calls currently render into.
+func boundFramebuffer() uint32 {
+	var framebuffer int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &framebuffer)
+	return uint32(framebuffer)
+}
+
+// Bind makes the target the destination of draw calls and sets the viewport
+// to its size. Use Unbind to draw to the window again.
+func (rt *RenderTarget) Bind() {
+	if rt.multisample != 0 {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, rt.multisample)
+	} else {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, rt.framebuffer)
+	}
+	gl.Viewport(0, 0, int32(rt.Width), int32(rt.Height))
+}
+
+// Unbind makes the window the destination of draw calls again. The viewport
+// has to be restored by the caller.
+func (rt *RenderTarget) Unbind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// Resolve copies the multisampled buffers into the textures, so they can be
+// sampled. It does nothing if the target is not multisampled.
+func (rt *RenderTarget) Resolve() {
+	if rt.multisample == 0 {
+		return
+	}
+
+	previous := boundFramebuffer()
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, rt.multisample)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, rt.framebuffer)
+
+	// every color attachment has to be copied on its own
+	for i := range rt.Colors {
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0 + uint32(i))
+		gl.DrawBuffer(gl.COLOR_ATTACHMENT0 + uint32(i))
+		gl.BlitFramebuffer(0, 0, int32(rt.Width), int32(rt.Height), 0, 0, int32(rt.Width), int32(rt.Height), gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+	if rt.Depth != 0 {
+		gl.BlitFramebuffer(0, 0, int32(rt.Width), int32(rt.Height), 0, 0, int32(rt.Width), int32(rt.Height), gl.DEPTH_BUFFER_BIT, gl.NEAREST)
+	}
+
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	rt.setDrawBuffers()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, previous)
+}
+
+// BlitToScreen resolves the target and copies its first color attachment
+// into a rectangle of the window, scaling it if the sizes differ.
+func (rt *RenderTarget) BlitToScreen(x, y, width, height int) {
+	rt.Resolve()
+
+	previous := boundFramebuffer()
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, rt.framebuffer)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	gl.BlitFramebuffer(
+		0, 0, int32(rt.Width), int32(rt.Height),
+		int32(x), int32(y), int32(x+width), int32(y+height),
+		gl.COLOR_BUFFER_BIT, gl.LINEAR)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, previous)
+}
+
+// Delete frees the framebuffers and attachments of the target.
+func (rt *RenderTarget) Delete() {
+	gl.DeleteFramebuffers(1, &rt.framebuffer)
+	if len(rt.Colors) > 0 {
+		gl.DeleteTextures(int32(len(rt.Colors)), &rt.Colors[0])
+	}
+	if rt.Depth != 0 {
+		gl.DeleteTextures(1, &rt.Depth)
+	}
+	if rt.multisample != 0 {
+		gl.DeleteFramebuffers(1, &rt.multisample)
+		gl.DeleteRenderbuffers(int32(len(rt.renderbuffers)), &rt.renderbuffers[0])
+	}
+
+	*rt = RenderTarget{}
+}
@@ -0,0 +1,81 @@
+package graphics
+
+import (
+	"time"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+)
+
+// gpuTimerQueries is the number of frames a GPUTimer can have in flight.
+const gpuTimerQueries = 4
+
+// A GPUTimer measures how long the GPU takes to execute the commands between
+// Begin and End. To not make the CPU wait for the GPU, results are read a few
+// frames later. If the GPU falls further behind than that, frames are not
+// measured until the oldest result is read.
+type GPUTimer struct {
+	queries [gpuTimerQueries]uint32
+	pending [gpuTimerQueries]bool
+	next    int
+
+	// measuring is true between Begin and End, unless the frame is skipped
+	measuring bool
+}
+
+// Begin starts measuring. Only one GPUTimer can measure at a time.
+func (gt *GPUTimer) Begin() {
+	if gt.queries[0] == 0 {
+		gl.GenQueries(gpuTimerQueries, &gt.queries[0])
+	}
+
+	// the oldest result wasn't read yet, so its query can't be reused
+	if gt.pending[gt.next] {
+		return
+	}
+
+	gl.BeginQuery(gl.TIME_ELAPSED, gt.queries[gt.next])
+	gt.measuring = true
+}
+
+// End stops measuring.
+func (gt *GPUTimer) End() {
+	if !gt.measuring {
+		return
+	}
+	gl.EndQuery(gl.TIME_ELAPSED)
+	gt.measuring = false
+
+	gt.pending[gt.next] = true
+	gt.next = (gt.next + 1) % gpuTimerQueries
+}
+
+// Result returns the oldest measurement if the GPU finished it. It should be
+// called before Begin, because Begin reuses the query of the oldest measurement
+// and skips the frame if its result is still pending.
+func (gt *GPUTimer) Result() (duration time.Duration, ok bool) {
+	query := gt.queries[gt.next]
+	if !gt.pending[gt.next] {
+		return 0, false
+	}
+
+	var available int32
+	gl.GetQueryObjectiv(query, gl.QUERY_RESULT_AVAILABLE, &available)
+	if available == gl.FALSE {
+		return 0, false
+	}
+
+	var elapsed uint64
+	gl.GetQueryObjectui64v(query, gl.QUERY_RESULT, &elapsed)
+	gt.pending[gt.next] = false
+
+	return time.Duration(elapsed), true
+}
+
+// Delete frees the queries of the timer.
+func (gt *GPUTimer) Delete() {
+	if gt.queries[0] != 0 {
+		gl.DeleteQueries(gpuTimerQueries, &gt.queries[0])
+	}
+
+	*gt = GPUTimer{}
+}
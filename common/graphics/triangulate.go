@@ -0,0 +1,151 @@
+package graphics
+
+import "gitlocal/gome"
+
+// triangulate splits a polygon into triangles, returning the indices of the
+// triangle corners in the winding order of the polygon. Convex polygons are
+// split into a fan, concave ones get triangulated by ear clipping.
+func triangulate(polygon []gome.FloatVector3) [][3]int {
+	points := projectPolygon(polygon)
+
+	// the orientation of the polygon in the projection
+	orientation := float32(1)
+	if area := signedArea(points); area < 0 {
+		orientation = -1
+	} else if area == 0 {
+		// degenerate polygon, anything goes
+		return fan(indexRange(len(polygon)))
+	}
+
+	// convex polygons don't need ear clipping
+	convex := true
+	for i := range points {
+		prev := points[(i-1+len(points))%len(points)]
+		next := points[(i+1)%len(points)]
+		if cross2(prev, points[i], next)*orientation < 0 {
+			convex = false
+			break
+		}
+	}
+	if convex {
+		return fan(indexRange(len(polygon)))
+	}
+
+	triangles := make([][3]int, 0, len(polygon)-2)
+	remaining := indexRange(len(polygon))
+
+	for len(remaining) > 3 {
+		clipped := false
+
+		for i := range remaining {
+			prev := remaining[(i-1+len(remaining))%len(remaining)]
+			current := remaining[i]
+			next := remaining[(i+1)%len(remaining)]
+
+			// reflex corners can't be ears
+			if cross2(points[prev], points[current], points[next])*orientation <= 0 {
+				continue
+			}
+
+			// an ear must not contain other corners
+			isEar := true
+			for _, other := range remaining {
+				if other == prev || other == current || other == next {
+					continue
+				}
+				if inTriangle(points[other], points[prev], points[current], points[next], orientation) {
+					isEar = false
+					break
+				}
+			}
+
+			if isEar {
+				triangles = append(triangles, [3]int{prev, current, next})
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				clipped = true
+				break
+			}
+		}
+
+		// self-intersecting polygons may have no ears left
+		if !clipped {
+			return append(triangles, fan(remaining)...)
+		}
+	}
+
+	return append(triangles, [3]int{remaining[0], remaining[1], remaining[2]})
+}
+
+// projectPolygon projects a polygon onto the axis plane it is most parallel to.
+func projectPolygon(polygon []gome.FloatVector3) []gome.FloatVector2 {
+	// Newell's method for the polygon normal
+	normal := gome.FloatVector3{}
+	for i, current := range polygon {
+		next := polygon[(i+1)%len(polygon)]
+		normal.X += (current.Y - next.Y) * (current.Z + next.Z)
+		normal.Y += (current.Z - next.Z) * (current.X + next.X)
+		normal.Z += (current.X - next.X) * (current.Y + next.Y)
+	}
+
+	x, y, z := abs32(normal.X), abs32(normal.Y), abs32(normal.Z)
+	points := make([]gome.FloatVector2, len(polygon))
+	for i, p := range polygon {
+		switch {
+		case x >= y && x >= z:
+			points[i] = gome.FloatVector2{X: p.Y, Y: p.Z}
+		case y >= z:
+			points[i] = gome.FloatVector2{X: p.Z, Y: p.X}
+		default:
+			points[i] = gome.FloatVector2{X: p.X, Y: p.Y}
+		}
+	}
+
+	return points
+}
+
+// signedArea returns twice the signed area of a 2D polygon.
+func signedArea(points []gome.FloatVector2) float32 {
+	area := float32(0)
+	for i, current := range points {
+		next := points[(i+1)%len(points)]
+		area += current.X*next.Y - next.X*current.Y
+	}
+	return area
+}
+
+// cross2 returns the z component of the cross product of (b - a) and (c - b).
+func cross2(a, b, c gome.FloatVector2) float32 {
+	return (b.X-a.X)*(c.Y-b.Y) - (b.Y-a.Y)*(c.X-b.X)
+}
+
+// inTriangle checks if p lies inside or on the edge of the triangle abc.
+func inTriangle(p, a, b, c gome.FloatVector2, orientation float32) bool {
+	return cross2(a, b, p)*orientation >= 0 &&
+		cross2(b, c, p)*orientation >= 0 &&
+		cross2(c, a, p)*orientation >= 0
+}
+
+// fan splits a polygon into a triangle fan around its first corner.
+func fan(corners []int) [][3]int {
+	triangles := make([][3]int, 0, len(corners)-2)
+	for i := 1; i < len(corners)-1; i++ {
+		triangles = append(triangles, [3]int{corners[0], corners[i], corners[i+1]})
+	}
+	return triangles
+}
+
+// indexRange returns the indices 0 to n-1.
+func indexRange(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+func abs32(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
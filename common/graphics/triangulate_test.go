@@ -0,0 +1,61 @@
+package graphics
+
+import (
+	"gitlocal/gome"
+	"testing"
+)
+
+func TestTriangulate(t *testing.T) {
+	tests := []struct {
+		name    string
+		polygon []gome.FloatVector3
+	}{
+		{"triangle", []gome.FloatVector3{{X: 0}, {X: 1}, {Y: 1}}},
+		{"quad", []gome.FloatVector3{{X: 0}, {X: 1}, {X: 1, Y: 1}, {Y: 1}}},
+		{"clockwise quad", []gome.FloatVector3{{Y: 1}, {X: 1, Y: 1}, {X: 1}, {X: 0}}},
+		{"vertical pentagon", []gome.FloatVector3{{Y: 0}, {Y: 2}, {Y: 3, Z: 1}, {Y: 2, Z: 2}, {Z: 2}}},
+		// concave polygons, which a fan would fold over
+		{"arrow", []gome.FloatVector3{{X: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 1}}},
+		{"L", []gome.FloatVector3{{X: 0}, {X: 2}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 2}, {Y: 2}}},
+		// self-intersecting polygons fall back to a fan
+		{"bow tie", []gome.FloatVector3{{X: 0}, {X: 1, Y: 1}, {X: 1}, {Y: 1}}},
+		{"degenerate", []gome.FloatVector3{{X: 0}, {X: 1}, {X: 2}, {X: 3}}},
+	}
+
+	for _, test := range tests {
+		triangles := triangulate(test.polygon)
+		if len(triangles) != len(test.polygon)-2 {
+			t.Errorf("%s: got %d triangles, want %d", test.name, len(triangles), len(test.polygon)-2)
+			continue
+		}
+
+		// every corner is used and the total area is the area of the polygon
+		points := projectPolygon(test.polygon)
+		used := make(map[int]bool)
+		area := float32(0)
+		for _, triangle := range triangles {
+			for _, corner := range triangle {
+				used[corner] = true
+			}
+			area += signedArea([]gome.FloatVector2{points[triangle[0]], points[triangle[1]], points[triangle[2]]})
+		}
+		if len(used) != len(test.polygon) {
+			t.Errorf("%s: %d of %d corners used", test.name, len(used), len(test.polygon))
+		}
+
+		if test.name == "bow tie" {
+			continue // its area cancels out
+		}
+		if want := signedArea(points); abs32(area-want) > 1e-5 {
+			t.Errorf("%s: triangles cover an area of %v, want %v", test.name, area/2, want/2)
+		}
+
+		// no triangle is flipped against the polygon
+		for _, triangle := range triangles {
+			a := signedArea([]gome.FloatVector2{points[triangle[0]], points[triangle[1]], points[triangle[2]]})
+			if a*signedArea(points) < 0 {
+				t.Errorf("%s: triangle %v is flipped", test.name, triangle)
+			}
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package common
 
-import "gitlocal/gome"
+import (
+	"gitlocal/gome"
+	"math"
+	"sort"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
 
 /*
 	LightComponent
@@ -9,14 +15,37 @@ import "gitlocal/gome"
 type LightType uint32
 
 const (
-	POINT_LIGHT = iota
+	POINT_LIGHT LightType = iota
 	DIRECTIONAL_LIGHT
+	SPOT_LIGHT
 )
 
+// MaxLights is the number of lights the default shader can draw at once. If a
+// scene has more, the directional lights and the lights closest to the camera
+// are used.
+const MaxLights = 64
+
+// A LightComponent makes its entity emit light. Directional and spot lights
+// shine along the -Z axis of their entity, rotate it to aim them.
 type LightComponent struct {
-	Color       gome.FloatVector3
+	// Color is the color and intensity of the light. Use values above 1 for
+	// brighter lights.
+	Color gome.FloatVector3
+
+	// Attenuation is how fast point and spot lights fade with the distance d:
+	// the intensity is 1 / (1 + Attenuation * d²).
 	Attenuation float32
-	Type        LightType
+
+	Type LightType
+
+	// InnerCone and OuterCone are the angles in radians between the direction
+	// of a spot light and the edge of its full and its faded light.
+	InnerCone, OuterCone float32
+
+	// CastShadows makes objects block the light. The first directional light
+	// casting shadows gets a cascaded shadow map, and up to MaxPointShadows
+	// point and spot lights a cube shadow map each.
+	CastShadows bool
 }
 
 func (lc *LightComponent) Name() string { return "Light" }
@@ -31,10 +60,20 @@ type LightSource struct {
 	Direction   gome.FloatVector3
 	Color       gome.FloatVector3
 	Attenuation float32
+	InnerCone   float32
+	OuterCone   float32
+	CastShadows bool
+
+	// shadow is the index of the shadow map of the light, -1 if it has none.
+	shadow int
 }
 
 type LightSystem struct {
 	gome.MultiSystem
+
+	// Ambient is the light that reaches every surface. If the scene has no
+	// lights and no ambient light, everything is drawn unlit.
+	Ambient gome.FloatVector3
 }
 
 // getLightSources returns all the registered light sources:
@@ -46,12 +85,23 @@ func (ls *LightSystem) getLightSources() []LightSource {
 		lightComponent := components[0].(*LightComponent)
 		spaceComponent := components[1].(*SpaceComponent)
 
+		world := spaceComponent.worldMatrix()
+		position := world.Col(3)
+		direction := world.Mul4x1(mgl32.Vec4{0, 0, -1, 0}).Vec3()
+		if direction.Len() > 0 {
+			direction = direction.Normalize()
+		}
+
 		sources[index] = LightSource{
 			Type:        lightComponent.Type,
-			Position:    spaceComponent.GetPosition(),
-			Direction:   spaceComponent.GetRotation(),
+			Position:    gome.FloatVector3{X: position.X(), Y: position.Y(), Z: position.Z()},
+			Direction:   gome.FloatVector3{X: direction.X(), Y: direction.Y(), Z: direction.Z()},
 			Attenuation: lightComponent.Attenuation,
 			Color:       lightComponent.Color,
+			InnerCone:   lightComponent.InnerCone,
+			OuterCone:   lightComponent.OuterCone,
+			CastShadows: lightComponent.CastShadows,
+			shadow:      -1,
 		}
 
 		index++
@@ -60,6 +110,68 @@ func (ls *LightSystem) getLightSources() []LightSource {
 	return sources
 }
 
+// lightBlock mirrors the u_Lights uniform block of the default shader, in the
+// std140 layout.
+type lightBlock struct {
+	Ambient [4]float32
+	Count   [4]int32
+	Lights  [MaxLights]lightData
+}
+
+type lightData struct {
+	Position  [4]float32 // w is the type
+	Direction [4]float32
+	Color     [4]float32 // w is the attenuation
+	Cone      [4]float32 // cosines of the inner and outer cone, shadow map
+}
+
+// lights returns the light sources to draw as seen from a camera position.
+func (ls *LightSystem) lights(camera gome.FloatVector3) []LightSource {
+	sources := ls.getLightSources()
+
+	// keep the lights that matter the most if there are too many
+	if len(sources) > MaxLights {
+		distance := func(source LightSource) float32 {
+			if source.Type == DIRECTIONAL_LIGHT {
+				return -1
+			}
+			x, y, z := source.Position.X-camera.X, source.Position.Y-camera.Y, source.Position.Z-camera.Z
+			return x*x + y*y + z*z
+		}
+
+		sort.Slice(sources, func(i, j int) bool { return distance(sources[i]) < distance(sources[j]) })
+		sources = sources[:MaxLights]
+	}
+
+	return sources
+}
+
+// lightBlock returns the uniform block of light sources.
+func (ls *LightSystem) lightBlock(sources []LightSource) *lightBlock {
+	block := &lightBlock{}
+	ambient := ls.Ambient
+	if len(sources) == 0 && ambient == (gome.FloatVector3{}) {
+		ambient = gome.FloatVector3{X: 1, Y: 1, Z: 1}
+	}
+	block.Ambient = [4]float32{ambient.X, ambient.Y, ambient.Z, 0}
+	block.Count[0] = int32(len(sources))
+
+	for i, source := range sources {
+		block.Lights[i] = lightData{
+			Position:  [4]float32{source.Position.X, source.Position.Y, source.Position.Z, float32(source.Type)},
+			Direction: [4]float32{source.Direction.X, source.Direction.Y, source.Direction.Z, 0},
+			Color:     [4]float32{source.Color.X, source.Color.Y, source.Color.Z, source.Attenuation},
+			Cone: [4]float32{
+				float32(math.Cos(float64(source.InnerCone))),
+				float32(math.Cos(float64(source.OuterCone))),
+				float32(source.shadow),
+			},
+		}
+	}
+
+	return block
+}
+
 func (ls *LightSystem) Name() string { return "Light" }
 
 func (ls *LightSystem) RequiredComponents() []string { return []string{"Light", "Space"} }
@@ -0,0 +1,191 @@
+package common
+
+import (
+	"fmt"
+	"gitlocal/gome"
+	"gitlocal/gome/common/graphics"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	Skin
+*/
+
+// A Skin deforms a mesh by the transforms of the entities of a skeleton.
+type Skin struct {
+	// Joints are the space components of the joint entities.
+	Joints []*SpaceComponent
+
+	// InverseBindMatrices transform the mesh into the space of each joint.
+	InverseBindMatrices []mgl32.Mat4
+}
+
+// jointMatrices returns the transform of every joint relative to the entity
+// of the mesh, with the model matrix of that entity.
+func (s *Skin) jointMatrices(model mgl32.Mat4) []mgl32.Mat4 {
+	inverse := model.Inv()
+
+	matrices := make([]mgl32.Mat4, len(s.Joints))
+	for i, joint := range s.Joints {
+		matrices[i] = inverse.Mul4(joint.worldMatrix()).Mul4(s.InverseBindMatrices[i])
+	}
+
+	return matrices
+}
+
+/*
+	ModelEntity
+*/
+
+// A ModelEntity is an entity of a model file: either the root of the whole
+// model, or one of its nodes.
+type ModelEntity struct {
+	gome.BaseEntity
+
+	// NodeName is the name of the node in the file, empty for the root.
+	NodeName string
+}
+
+// New gives the entity a SpaceComponent.
+func (me *ModelEntity) New() error {
+	me.BaseEntity.Components = map[string]gome.Component{
+		"Space": &SpaceComponent{},
+	}
+
+	return nil
+}
+
+// Space returns the SpaceComponent of the entity.
+func (me *ModelEntity) Space() *SpaceComponent {
+	return me.BaseEntity.Components["Space"].(*SpaceComponent)
+}
+
+/*
+	Model
+*/
+
+// A Model is the entity hierarchy of a model file.
+type Model struct {
+	// Root is the parent of all nodes. Move, rotate or scale it to transform
+	// the whole model. If the file has animations, it has an AnimationComponent.
+	Root *ModelEntity
+
+	// Nodes are the entities of the nodes of the scene, parents before their
+	// children.
+	Nodes []*ModelEntity
+
+	// Animator plays the animations of the file. It is nil if there are none.
+	Animator *AnimationComponent
+}
+
+// Entities returns the root and all nodes, to be added to a scene.
+func (m *Model) Entities() []gome.Entity {
+	entities := []gome.Entity{m.Root}
+	for _, node := range m.Nodes {
+		entities = append(entities, node)
+	}
+
+	return entities
+}
+
+// Node returns the first node with a name, or nil if there is none.
+func (m *Model) Node(name string) *ModelEntity {
+	for _, node := range m.Nodes {
+		if node.NodeName == name {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// LoadModel loads a model file (see graphics.LoadModel) and creates an entity
+// for every node of its scene, starting from the roots, with a SpaceComponent
+// relative to its parent and a RenderComponent if it has a mesh. Nodes outside
+// the scene get no entity. The meshes are uploaded when the entities are added
+// to a scene with a RenderSystem. Animations are played by an AnimationSystem,
+// which has to be added to the scene.
+func LoadModel(path string) (*Model, error) {
+	data, err := graphics.LoadModel(path)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &Model{Root: &ModelEntity{}}
+	model.Root.New()
+
+	// create the nodes first, so they can refer to each other. Nodes outside
+	// the scene stay nil.
+	entities := make([]*ModelEntity, len(data.Nodes))
+	spaces := make([]*SpaceComponent, len(data.Nodes))
+
+	var instantiate func(index int, parent *SpaceComponent)
+	instantiate = func(index int, parent *SpaceComponent) {
+		node := data.Nodes[index]
+		entity := &ModelEntity{NodeName: node.Name}
+		entity.New()
+
+		space := entity.Space()
+		space.SetPosition(gome.FloatVector3{X: node.Translation[0], Y: node.Translation[1], Z: node.Translation[2]})
+		space.SetOrientation(node.Rotation)
+		space.SetSize(gome.FloatVector3{X: node.Scale[0], Y: node.Scale[1], Z: node.Scale[2]})
+		space.SetParent(parent)
+
+		model.Nodes = append(model.Nodes, entity)
+		entities[index] = entity
+		spaces[index] = space
+
+		for _, child := range node.Children {
+			instantiate(child, space)
+		}
+	}
+	for _, root := range data.Roots {
+		instantiate(root, model.Root.Space())
+	}
+
+	for i, node := range data.Nodes {
+		if entities[i] == nil || node.Mesh < 0 {
+			continue
+		}
+
+		render := &RenderComponent{
+			Mesh:    data.Meshes[node.Mesh],
+			MeshKey: fmt.Sprintf("%s#mesh%d", path, node.Mesh),
+		}
+		if node.Skin >= 0 {
+			render.Skin = newSkin(data.Skins[node.Skin], spaces)
+			if render.Skin == nil {
+				gome.Log.Warn(gome.CategoryAssets, "skin has joints outside the scene, drawing the mesh unskinned",
+					"path", path, "node", node.Name)
+			}
+		}
+		entities[i].Components["Render"] = render
+	}
+
+	if len(data.Animations) > 0 {
+		model.Animator = &AnimationComponent{
+			Animations: data.Animations,
+			Targets:    spaces,
+			Speed:      1,
+			Loop:       true,
+		}
+		model.Root.Components["Animation"] = model.Animator
+	}
+
+	return model, nil
+}
+
+// newSkin returns the skin of a model with the space components of its joints,
+// or nil if a joint has none.
+func newSkin(data graphics.ModelSkin, spaces []*SpaceComponent) *Skin {
+	skin := &Skin{InverseBindMatrices: data.InverseBindMatrices}
+	for _, joint := range data.Joints {
+		if spaces[joint] == nil {
+			return nil
+		}
+		skin.Joints = append(skin.Joints, spaces[joint])
+	}
+
+	return skin
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadModelScene(t *testing.T) {
+	// node 2 is not part of the scene, node 3 is a root of another scene
+	gome.Files.Mount("modeltest", fstest.MapFS{
+		"model.gltf": {Data: []byte(`{
+			"asset": {"version": "2.0"},
+			"scene": 0,
+			"scenes": [{"nodes": [0]}, {"nodes": [3]}],
+			"nodes": [
+				{"name": "root", "children": [1], "translation": [1, 2, 3]},
+				{"name": "child"},
+				{"name": "orphan"},
+				{"name": "other scene"}
+			]
+		}`)},
+	})
+	defer gome.Files.Unmount("modeltest")
+
+	model, err := LoadModel("modeltest/model.gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(model.Nodes) != 2 {
+		t.Fatalf("got %d nodes, want 2", len(model.Nodes))
+	}
+	root, child := model.Node("root"), model.Node("child")
+	if root == nil || child == nil {
+		t.Fatalf("got nodes %v, want root and child", model.Nodes)
+	}
+	if model.Node("orphan") != nil || model.Node("other scene") != nil {
+		t.Error("nodes outside the scene got entities")
+	}
+
+	if root.Space().GetParent() != model.Root.Space() {
+		t.Error("root is not a child of the model root")
+	}
+	if child.Space().GetParent() != root.Space() {
+		t.Error("child is not a child of root")
+	}
+}
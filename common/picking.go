@@ -0,0 +1,271 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"gitlocal/gome/common/graphics"
+	"math"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+/*
+	Raycasts
+*/
+
+// A RaycastHit is where a ray hit an entity.
+type RaycastHit struct {
+	Entity uint
+	Point  gome.FloatVector3
+
+	// Normal is the normal of the surface that was hit, facing the origin of
+	// the ray.
+	Normal gome.FloatVector3
+
+	// Distance is how far along the ray the entity was hit.
+	Distance float32
+}
+
+// RaycastOptions narrow down what a raycast can hit.
+type RaycastOptions struct {
+	// MaxDistance is how far along the ray entities can be hit, unlimited if 0.
+	MaxDistance float32
+
+	// Layers are the render layers of the entities that can be hit, all if 0.
+	Layers RenderLayer
+
+	// BoundsOnly hits the bounding boxes of the meshes instead of their
+	// triangles, which is faster, but less exact.
+	BoundsOnly bool
+}
+
+// Raycast returns the closest entity of the system a ray hits. Skinned meshes
+// are hit in their rest pose, use Pick to hit them as they are drawn.
+func (rs *RenderSystem) Raycast(ray gome.Ray, options RaycastOptions) (hit RaycastHit, ok bool) {
+	// entities may have moved since the last frame
+	rs.updateBounds()
+
+	_, _, ok = rs.bvh.Raycast(ray, options.MaxDistance, func(id uint, maxDistance float32) (float32, bool) {
+		components := rs.MultiSystem.Entities[id]
+		renderComponent := components[0].(*RenderComponent)
+		spaceComponent := components[1].(*SpaceComponent)
+		if !renderComponent.Layer.matches(options.Layers) {
+			return 0, false
+		}
+
+		meshHit, hitMesh := raycastMesh(ray, renderComponent.mesh, spaceComponent.worldMatrix(), options.BoundsOnly, maxDistance)
+		if hitMesh {
+			meshHit.Entity = id
+			hit = meshHit
+		}
+		return meshHit.Distance, hitMesh
+	})
+
+	return hit, ok
+}
+
+// raycastMesh returns where a ray hits a mesh with a model matrix, if it hits
+// it before maxDistance.
+func raycastMesh(ray gome.Ray, mesh *graphics.MeshAsset, model mgl32.Mat4, boundsOnly bool, maxDistance float32) (RaycastHit, bool) {
+	// test in the space of the mesh; the direction isn't normalized again, so
+	// distances stay the same
+	inverse := model.Inv()
+	origin := inverse.Mul4x1(mgl32.Vec4{ray.Origin.X, ray.Origin.Y, ray.Origin.Z, 1}).Vec3()
+	direction := inverse.Mul4x1(mgl32.Vec4{ray.Direction.X, ray.Direction.Y, ray.Direction.Z, 0}).Vec3()
+	local := gome.Ray{
+		Origin:    gome.FloatVector3{X: origin.X(), Y: origin.Y(), Z: origin.Z()},
+		Direction: gome.FloatVector3{X: direction.X(), Y: direction.Y(), Z: direction.Z()},
+	}
+
+	distance, ok := local.IntersectAABB(mesh.Bounds)
+	if !ok || distance > maxDistance {
+		return RaycastHit{}, false
+	}
+
+	var normal mgl32.Vec3
+	if boundsOnly {
+		normal = boxNormal(mesh.Bounds, local.At(distance), direction)
+	} else {
+		distance, normal, ok = raycastTriangles(local, mesh, maxDistance)
+		if !ok {
+			return RaycastHit{}, false
+		}
+	}
+
+	at := local.At(distance)
+	point := model.Mul4x1(mgl32.Vec4{at.X, at.Y, at.Z, 1}).Vec3()
+	normal = model.Mat3().Inv().Transpose().Mul3x1(normal)
+	if normal.Len() > 0 {
+		normal = normal.Normalize()
+	}
+	if normal.Dot(mgl32.Vec3{ray.Direction.X, ray.Direction.Y, ray.Direction.Z}) > 0 {
+		normal = normal.Mul(-1)
+	}
+
+	return RaycastHit{
+		Point:    gome.FloatVector3{X: point.X(), Y: point.Y(), Z: point.Z()},
+		Normal:   gome.FloatVector3{X: normal.X(), Y: normal.Y(), Z: normal.Z()},
+		Distance: distance,
+	}, true
+}
+
+// raycastTriangles returns the distance and the normal of the closest
+// triangle of a mesh a ray hits before maxDistance.
+func raycastTriangles(ray gome.Ray, mesh *graphics.MeshAsset, maxDistance float32) (distance float32, normal mgl32.Vec3, ok bool) {
+	distance = maxDistance
+	for i := 0; i+2 < len(mesh.Indices); i += 3 {
+		a := mesh.Positions[mesh.Indices[i]]
+		b := mesh.Positions[mesh.Indices[i+1]]
+		c := mesh.Positions[mesh.Indices[i+2]]
+
+		t, hit := ray.IntersectTriangle(a, b, c)
+		if !hit || t > distance {
+			continue
+		}
+
+		distance, ok = t, true
+		ab := mgl32.Vec3{b.X - a.X, b.Y - a.Y, b.Z - a.Z}
+		ac := mgl32.Vec3{c.X - a.X, c.Y - a.Y, c.Z - a.Z}
+		normal = ab.Cross(ac)
+	}
+
+	return
+}
+
+// boxNormal returns the normal of the face of a box closest to a point on it,
+// or the opposite of the direction of a ray starting inside of it.
+func boxNormal(box gome.AABB, point gome.FloatVector3, direction mgl32.Vec3) mgl32.Vec3 {
+	low, high, p := box.Min.ToArray(), box.Max.ToArray(), point.ToArray()
+
+	normal, closest := direction.Mul(-1), float32(math.Inf(1))
+	for axis := 0; axis < 3; axis++ {
+		for _, face := range []struct {
+			distance, sign float32
+		}{{p[axis] - low[axis], -1}, {high[axis] - p[axis], 1}} {
+			if d := float32(math.Abs(float64(face.distance))); d < closest {
+				closest = d
+				normal = mgl32.Vec3{}
+				normal[axis] = face.sign
+			}
+		}
+	}
+
+	return normal
+}
+
+/*
+	Picking
+*/
+
+// picker draws the IDs of entities into a single pixel.
+type picker struct {
+	shader *graphics.ShaderAsset
+	target *graphics.RenderTarget
+}
+
+// init loads the shader and creates the target on first use.
+func (p *picker) init(assets *graphics.AssetManager) error {
+	if p.target != nil {
+		return nil
+	}
+
+	shader, err := assets.Shader(graphics.PickShader)
+	if err != nil {
+		return err
+	}
+	target, err := graphics.NewRenderTarget(1, 1, graphics.RenderTargetOptions{
+		Colors: []graphics.TargetFormat{graphics.TARGET_R32UI},
+	})
+	if err != nil {
+		shader.Release()
+		return err
+	}
+
+	p.shader, p.target = shader, target
+	return nil
+}
+
+// delete frees the target and releases the shader.
+func (p *picker) delete() {
+	if p.target == nil {
+		return
+	}
+
+	p.target.Delete()
+	p.shader.Release()
+	*p = picker{}
+}
+
+// Pick returns the entity drawn at a position of the window, in the
+// coordinates of mouse messages. It draws the IDs of the entities under the
+// cursor on the GPU, so unlike Raycast it is exact for skinned meshes and only
+// finds entities that aren't covered, but it waits for the GPU to finish.
+func (rs *RenderSystem) Pick(x, y float32) (id uint, ok bool) {
+	cameraID, ok := rs.cameraSystem.CameraAt(x, y)
+	if !ok {
+		return 0, false
+	}
+	camera, _ := rs.cameraSystem.camera(cameraID)
+
+	if err := rs.picker.init(rs.Assets); err != nil {
+		gome.Log.Error(gome.CategoryRender, "could not pick", "err", err)
+		return 0, false
+	}
+	shader := rs.picker.shader
+
+	// zoom in on the cursor, so the pixel under it fills the target
+	viewport := camera.Viewport.pixels(rs.cameraSystem.screen)
+	vx, vy := rs.cameraSystem.toViewport(camera, x, y)
+	zoom := mgl32.Scale3D(float32(viewport[2]), float32(viewport[3]), 1).Mul4(mgl32.Translate3D(-vx, -vy, 0))
+	PVM := zoom.Mul4(camera.projectionViewMatrix(viewport))
+
+	// keep the state of the frame
+	var framebuffer int32
+	previous := [4]int32{}
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &framebuffer)
+	gl.GetIntegerv(gl.VIEWPORT, &previous[0])
+	blend := gl.IsEnabled(gl.BLEND)
+	defer func() {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(framebuffer))
+		gl.Viewport(previous[0], previous[1], previous[2], previous[3])
+		if blend {
+			gl.Enable(gl.BLEND)
+		}
+	}()
+
+	rs.picker.target.Bind()
+	gl.Disable(gl.BLEND)
+	nothing := uint32(0)
+	gl.ClearBufferuiv(gl.COLOR, 0, &nothing)
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+
+	gl.UseProgram(shader.Program)
+	for entity, components := range rs.MultiSystem.Entities {
+		renderComponent := components[0].(*RenderComponent)
+		spaceComponent := components[1].(*SpaceComponent)
+		mesh := renderComponent.mesh
+		if mesh == nil || mesh.Array.Empty() || !camera.shows(renderComponent.Layer) {
+			continue
+		}
+
+		model := spaceComponent.worldMatrix()
+		shader.SetUniformFMat4("u_MVP", PVM.Mul4(model))
+		if skin := renderComponent.Skin; skin != nil {
+			shader.SetUniformInt("u_Skinned", 1)
+			shader.SetUniformFMat4Array("u_Joints", skin.jointMatrices(model))
+		} else {
+			shader.SetUniformInt("u_Skinned", 0)
+		}
+
+		// 0 is nothing
+		shader.SetUniformInt("u_ID", int32(entity+1))
+		mesh.Array.Draw()
+	}
+
+	var picked uint32
+	gl.ReadPixels(0, 0, 1, 1, gl.RED_INTEGER, gl.UNSIGNED_INT, gl.Ptr(&picked))
+	if picked == 0 {
+		return 0, false
+	}
+	return uint(picked - 1), true
+}
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"gitlocal/gome"
 	"gitlocal/gome/common/graphics"
-	"go/build"
-	"os"
+	"math"
 	"time"
 	"unsafe"
 
 	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
 )
 
 /*
@@ -22,12 +22,40 @@ type RenderComponent struct {
 	OBJPath      string
 	ModelUpdated bool
 
-	array   graphics.VertexArray
-	texture uint32
+	// Mesh is drawn instead of the file at OBJPath if set, e.g. for meshes of
+	// model files or primitives like graphics.Sphere. Entities with the same
+	// MeshKey share one uploaded mesh, without a key every entity uploads its own.
+	Mesh    *graphics.Mesh
+	MeshKey string
+
+	// Layer are the render layers of the entity, DEFAULT_LAYER if 0. Cameras
+	// only show the entities on the layers of their LayerMask.
+	Layer RenderLayer
+
+	// Skin deforms the mesh by the transforms of joint entities. It is nil for
+	// meshes that are not skinned.
+	Skin *Skin
+
+	mesh *graphics.MeshAsset
 }
 
 func (rc *RenderComponent) Name() string { return "Render" }
 
+// A RenderLayer is a set of layers, one per bit.
+type RenderLayer uint32
+
+// DEFAULT_LAYER is the layer of entities that don't set one.
+const DEFAULT_LAYER RenderLayer = 1
+
+// matches returns whether the layers of an entity are in a mask, which
+// contains all layers if it's 0.
+func (rl RenderLayer) matches(mask RenderLayer) bool {
+	if rl == 0 {
+		rl = DEFAULT_LAYER
+	}
+	return mask == 0 || mask&rl != 0
+}
+
 /*
 	RenderSystem
 */
@@ -36,31 +64,84 @@ func (rc *RenderComponent) Name() string { return "Render" }
 type RenderSystem struct {
 	gome.MultiSystem
 
-	graphics.Shader
+	// Assets caches the meshes, textures and shaders of the scene.
+	Assets *graphics.AssetManager
+
+	// AsyncLoading makes models load in the background instead of blocking
+	// when an entity gets added. Entities show up once their mesh is loaded, and
+	// load errors are not returned by Scene.AddEntity anymore.
+	AsyncLoading bool
+
+	// HotReload reloads shaders, textures and meshes when their files change.
+	// It is always enabled in debug mode. If it is set, the engine shaders are
+	// reloaded from the source directory of the graphics package as well, if
+	// it exists (see graphics.MountSourceAssets), until Shutdown.
+	HotReload bool
+
+	// EnvironmentMap are the faces of a cubemap (see graphics.LoadEnvironment)
+	// that lights and is reflected by PBR materials. It is loaded by Init, use
+	// SetEnvironment to change it later.
+	EnvironmentMap [6]string
+
+	// ShadowResolution is the size of the shadow maps of directional lights in
+	// texels, 2048 if 0. Point lights use half of it per cube face.
+	ShadowResolution int
+
+	// ShadowCascades is how many slices of the view the shadow map of a
+	// directional light is split into, from 1 to MaxCascades. 3 if 0.
+	ShadowCascades int
+
+	// ShadowDistance is how far from the camera directional lights cast
+	// shadows, and how far point lights cast them at most. 50 if 0.
+	ShadowDistance float32
+
+	// Target is drawn into instead of the window if set. The target is
+	// resolved after every frame, so its textures can be used right away.
+	Target *graphics.RenderTarget
+
+	shader       *graphics.ShaderAsset
+	white        *graphics.TextureAsset
+	environment  *graphics.Environment
+	shadows      shadowRenderer
+	posts        map[uint]*graphics.PostProcessor
+	picker       picker
+	bvh          BVH
+	bounds       map[uint]gome.AABB
+	skinned      []uint
 	cameraSystem *CameraSystem
 	lightSystem  *LightSystem
+	scene        *gome.Scene
+	gpuTimer     graphics.GPUTimer
+
+	// sourceMounted is true if Init called graphics.MountSourceAssets
+	sourceMounted bool
 }
 
 func (*RenderSystem) RequiredComponents() []string { return []string{"Render", "Space"} }
 
-func (rs *RenderSystem) Init(scene *gome.Scene) {
+func (rs *RenderSystem) Init(scene *gome.Scene) error {
 	// initialize the base system
 	rs.MultiSystem.Init(scene)
+	rs.scene = scene
 
 	// initialize OpenGL
-	gl.Init()
+	if err := gl.Init(); err != nil {
+		return fmt.Errorf("could not initialize OpenGL: %w", err)
+	}
 
 	// Configure global opengl settings
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 	gl.ClearColor(0, 0, 0, 0) // set the clear color
 
-	// if debug is enabled, show debug output
-	if scene.WindowArgs.Debug {
-		// opengl version
-		fmt.Println("OpenGL Version:", gl.GoStr(gl.GetString(gl.VERSION)))
+	// blend materials that are not fully opaque
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
 
-		// error and debug outptut
+	gome.Log.Debug(gome.CategoryRender, "initialized OpenGL", "version", gl.GoStr(gl.GetString(gl.VERSION)))
+
+	// if debug is enabled, route the OpenGL debug output to the log
+	if scene.WindowArgs.Debug {
 		gl.Enable(gl.DEBUG_OUTPUT)
 		gl.DebugMessageCallback(func(
 			source uint32,
@@ -71,36 +152,59 @@ func (rs *RenderSystem) Init(scene *gome.Scene) {
 			message string,
 			userParam unsafe.Pointer) {
 
-			// warn if it's an error
-			errWarning := ""
-			if gltype == gl.DEBUG_TYPE_ERROR {
-				errWarning = "** ERROR **"
-			}
-
-			fmt.Printf("GL CALLBACK: %s type = 0x%x, severity = 0x%x, message = %s\n",
-				errWarning, gltype, severity, message)
+			gome.Log.Write(glDebugLevel(gltype, severity), gome.CategoryRender, "GL callback: "+message,
+				"type", fmt.Sprintf("0x%x", gltype), "id", id)
 		}, gl.Ptr(nil))
 	}
 
+	rs.Assets = graphics.NewAssetManager()
+	if rs.HotReload || scene.WindowArgs.Debug {
+		rs.Assets.EnableHotReload(time.Second)
+	}
+	if rs.HotReload {
+		rs.sourceMounted = true
+		if !graphics.MountSourceAssets() {
+			gome.Log.Debug(gome.CategoryAssets, "engine assets are embedded, their changes are not reloaded")
+		}
+	}
+
 	// init shader
-	// TODO change this hacky code
-	shaderFile, err := os.Open(build.Default.GOPATH + "/src/github.com/lbuchli/gome/common/graphics/default.shader")
+	shader, err := rs.Assets.Shader(graphics.DefaultShader)
 	if err != nil {
-		panic("Could not find shader file")
+		return err
+	}
+	rs.shader = shader
+
+	if rs.shadows.shader, err = rs.Assets.Shader(graphics.ShadowShader); err != nil {
+		return err
+	}
+
+	// parts without a diffuse texture are drawn with a white one
+	rs.white = rs.Assets.White()
+
+	rs.posts = make(map[uint]*graphics.PostProcessor)
+
+	if rs.EnvironmentMap != [6]string{} {
+		if err := rs.SetEnvironment(rs.EnvironmentMap); err != nil {
+			return err
+		}
 	}
-	rs.Shader.Init(shaderFile)
 
 	// get the camera system, and if there isn't one, add a new instance to the scene.
 	if scene.HasSystem("Camera") {
 		rs.cameraSystem = scene.GetSystem("Camera").(*CameraSystem)
 	} else {
 		rs.cameraSystem = &CameraSystem{}
-		scene.AddSystem(rs.cameraSystem)
+		if err := scene.AddSystem(rs.cameraSystem); err != nil {
+			return err
+		}
 
 		// there's probably also no camera entity, so add it as well
 		cameraEntity := &CameraEntity{}
 		cameraEntity.New()
-		scene.AddEntity(cameraEntity)
+		if err := scene.AddEntity(cameraEntity); err != nil {
+			return err
+		}
 	}
 
 	// add a LightSystem if there isn't one
@@ -108,53 +212,549 @@ func (rs *RenderSystem) Init(scene *gome.Scene) {
 		rs.lightSystem = scene.GetSystem("Light").(*LightSystem)
 	} else {
 		rs.lightSystem = &LightSystem{}
-		scene.AddSystem(rs.lightSystem)
+		if err := scene.AddSystem(rs.lightSystem); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
-func (rs *RenderSystem) Add(id uint, components []gome.Component) {
-	rs.MultiSystem.Add(id, components)
+// glDebugLevel returns the log level of an OpenGL debug message.
+func glDebugLevel(gltype, severity uint32) gome.LogLevel {
+	if gltype == gl.DEBUG_TYPE_ERROR {
+		return gome.LevelError
+	}
 
+	switch severity {
+	case gl.DEBUG_SEVERITY_HIGH:
+		return gome.LevelError
+	case gl.DEBUG_SEVERITY_MEDIUM:
+		return gome.LevelWarn
+	case gl.DEBUG_SEVERITY_LOW:
+		return gome.LevelInfo
+	default:
+		return gome.LevelDebug
+	}
+}
+
+// Add loads the model of the entity. Entities with the same OBJPath (or MeshKey)
+// share one mesh. If loading fails, gome.HandleError decides if the entity gets
+// added with placeholders instead.
+func (rs *RenderSystem) Add(id uint, components []gome.Component) error {
 	renderComponent := components[0].(*RenderComponent)
 
-	f, err := os.Open(renderComponent.OBJPath)
-	if err != nil {
-		gome.Throw(err, "Could not open OBJ file "+renderComponent.OBJPath)
+	if renderComponent.Mesh != nil {
+		mesh, err := rs.Assets.UploadMesh(renderComponent.MeshKey, renderComponent.Mesh)
+		return rs.addMesh(id, components, mesh, renderComponent.MeshKey, err)
 	}
 
-	reader := &graphics.OBJFileReader{}
-	va, texture, err := reader.Data(f)
+	if rs.AsyncLoading {
+		rs.release(id)
+		renderComponent.mesh = rs.Assets.MeshAsync(renderComponent.OBJPath)
+		return rs.MultiSystem.Add(id, components)
+	}
+
+	mesh, err := rs.Assets.Mesh(renderComponent.OBJPath)
+	return rs.addMesh(id, components, mesh, renderComponent.OBJPath, err)
+}
+
+// addMesh adds an entity with its loaded mesh. Load errors are passed to
+// gome.HandleError.
+func (rs *RenderSystem) addMesh(id uint, components []gome.Component, mesh *graphics.MeshAsset, path string, err error) error {
 	if err != nil {
-		gome.Throw(err, "Could not read data from object file")
+		if herr := gome.HandleError(err); herr != nil {
+			if mesh != nil {
+				mesh.Release()
+			}
+			return herr
+		}
+		gome.Log.Warn(gome.CategoryAssets, "using placeholder", "path", path, "err", err)
+		if mesh == nil {
+			mesh = rs.Assets.PlaceholderMesh()
+		}
 	}
 
-	renderComponent.array = va
-	renderComponent.texture = texture
+	// release the mesh of an overwritten entity
+	rs.release(id)
+	components[0].(*RenderComponent).mesh = mesh
+
+	return rs.MultiSystem.Add(id, components)
+}
+
+// Remove removes the entity and releases its mesh.
+func (rs *RenderSystem) Remove(id uint) {
+	rs.release(id)
+	rs.MultiSystem.Remove(id)
+}
+
+// release releases the mesh of an entity if it's in the system.
+func (rs *RenderSystem) release(id uint) {
+	if components, ok := rs.MultiSystem.Entities[id]; ok {
+		renderComponent := components[0].(*RenderComponent)
+		if renderComponent.mesh != nil {
+			renderComponent.mesh.Release()
+			renderComponent.mesh = nil
+		}
+	}
 }
 
 func (rs *RenderSystem) Update(delta time.Duration) {
-	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT) // apply clear color
+	// measure the GPU time if the scene gets profiled
+	profiler := rs.scene.Profiler
+	if profiler != nil {
+		if duration, ok := rs.gpuTimer.Result(); ok {
+			profiler.RecordGPUTime(rs.Name(), duration)
+		}
+
+		rs.gpuTimer.Begin()
+		defer rs.gpuTimer.End()
+	}
 
-	gl.UseProgram(rs.Shader.Program)
+	// upload the assets that finished loading in the background or changed
+	rs.Assets.Poll()
+	rs.updateBounds()
+
+	// the screen is the window, or the target of the system if it's set
+	screen := [4]int32{}
+	gl.GetIntegerv(gl.VIEWPORT, &screen[0])
+	defer gl.Viewport(screen[0], screen[1], screen[2], screen[3])
+	if rs.Target != nil {
+		rs.Target.Bind()
+		defer func() {
+			rs.Target.Resolve()
+			rs.Target.Unbind()
+		}()
+		screen = [4]int32{0, 0, int32(rs.Target.Width), int32(rs.Target.Height)}
+	}
+	var framebuffer int32
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &framebuffer)
+	rs.cameraSystem.screen = screen
+
+	// parts of the screen no camera draws into stay black
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	cameras := rs.cameraSystem.cameras()
+	rs.deletePostProcessors(cameras)
+	for _, camera := range cameras {
+		if camera.Target == nil {
+			rs.renderCamera(camera, screen)
+			continue
+		}
+
+		camera.Target.Bind()
+		rs.renderCamera(camera, [4]int32{0, 0, int32(camera.Target.Width), int32(camera.Target.Height)})
+		camera.Target.Resolve()
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(framebuffer))
+	}
+}
 
-	// set the light uniforms
-	//lightSources := rs.lightSystem.getLightSources()
-	//rs.Shader.SetUniformBlock("u_Lights", lightSources, 12*4*4)
+// renderCamera draws what a camera sees into its viewport of an area of the
+// bound framebuffer.
+func (rs *RenderSystem) renderCamera(camera camera, area [4]int32) {
+	viewport := camera.Viewport.pixels(area)
+	if viewport[2] <= 0 || viewport[3] <= 0 {
+		return
+	}
+	gl.Viewport(viewport[0], viewport[1], viewport[2], viewport[3])
 
 	// Projection View Matrix
-	PVM := rs.cameraSystem.projectionViewMatrix()
+	PVM := camera.projectionViewMatrix(viewport)
+
+	// render the shadow maps before the scene, which uses them
+	position := camera.position()
+	lights := rs.lightSystem.lights(position)
+	rs.renderShadows(lights, PVM)
+
+	// draw the scene into an offscreen image, which the post effects of the
+	// camera then draw into the viewport. The image keeps the linear colors,
+	// the post processor encodes them for the screen after the last effect.
+	linear := false
+	if len(camera.PostEffects) > 0 {
+		post := rs.postProcessor(camera.id)
+		if err := post.Begin(); err != nil {
+			gome.Log.Error(gome.CategoryRender, "could not post-process", "err", err)
+		} else {
+			linear = true
+			defer post.End(camera.PostEffects)
+		}
+	}
+
+	rs.clear(camera.CameraComponent, linear)
+	rs.drawScene(camera, PVM, position, lights, linear)
+}
 
-	for _, components := range rs.MultiSystem.Entities {
+// clear clears the viewport as the settings of a camera say. If linear is
+// true, the clear color is converted to linear color space, so it looks the
+// same after the post effects encoded it again.
+func (rs *RenderSystem) clear(camera *CameraComponent, linear bool) {
+	var mask uint32
+	switch camera.Clear {
+	case CLEAR_ALL:
+		mask = gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT
+	case CLEAR_DEPTH:
+		mask = gl.DEPTH_BUFFER_BIT
+	default:
+		return
+	}
+
+	// clearing ignores the viewport, but not the scissor box
+	viewport := [4]int32{}
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(viewport[0], viewport[1], viewport[2], viewport[3])
+
+	color := camera.ClearColor
+	if linear {
+		color = gome.FloatVector3{X: linearColor(color.X), Y: linearColor(color.Y), Z: linearColor(color.Z)}
+	}
+	gl.ClearColor(color.X, color.Y, color.Z, 1)
+	gl.Clear(mask)
+	gl.Disable(gl.SCISSOR_TEST)
+}
+
+// linearColor converts a color channel from the display to linear color space.
+func linearColor(value float32) float32 {
+	return float32(math.Pow(float64(value), 2.2))
+}
+
+// drawScene draws the entities a camera shows. If linear is true, the colors
+// are left in linear color space instead of being encoded for the screen.
+func (rs *RenderSystem) drawScene(camera camera, PVM mgl32.Mat4, position gome.FloatVector3, lights []LightSource, linear bool) {
+	gl.UseProgram(rs.shader.Program)
+
+	if linear {
+		rs.shader.SetUniformInt("u_LinearOutput", 1)
+	} else {
+		rs.shader.SetUniformInt("u_LinearOutput", 0)
+	}
+
+	// set the light uniforms
+	block := rs.lightSystem.lightBlock(lights)
+	rs.shader.SetUniformBlock("u_Lights", block, int(unsafe.Sizeof(*block)))
+	rs.shader.SetUniformFVec3("u_CameraPosition", position)
+	rs.setShadowUniforms()
+
+	// every map of a material has its own texture unit, the environment comes
+	// after them
+	for unit, name := range materialSamplers {
+		rs.shader.SetUniformInt(name, int32(unit))
+	}
+	rs.shader.SetUniformInt("u_Environment", environmentUnit)
+	if rs.environment != nil {
+		rs.shader.SetUniformInt("u_HasEnvironment", 1)
+		rs.shader.SetUniformFloat("u_EnvironmentLevels", float32(rs.environment.Levels))
+		rs.shader.SetUniformFVec3Array("u_Irradiance", rs.environment.Irradiance[:])
+
+		gl.ActiveTexture(gl.TEXTURE0 + environmentUnit)
+		gl.BindTexture(gl.TEXTURE_CUBE_MAP, rs.environment.ID)
+	} else {
+		rs.shader.SetUniformInt("u_HasEnvironment", 0)
+	}
+
+	ids := rs.visible(NewFrustum(PVM))
+	shown := ids[:0]
+	for _, id := range ids {
+		if camera.shows(rs.MultiSystem.Entities[id][0].(*RenderComponent).Layer) {
+			shown = append(shown, id)
+		}
+	}
+	single, batches := rs.batches(shown)
+
+	profiler := rs.scene.Profiler
+	rs.shader.SetUniformInt("u_Instanced", 0)
+	for _, id := range single {
+		components := rs.MultiSystem.Entities[id]
 		renderComponent := components[0].(*RenderComponent)
 		spaceComponent := components[1].(*SpaceComponent)
-		VAO := &renderComponent.array
+		mesh := renderComponent.mesh
+
+		model := spaceComponent.worldMatrix()
+		MVP := PVM.Mul4(model)
+		rs.shader.SetUniformFMat4("u_MVP", MVP)
+		rs.shader.SetUniformFMat4("u_Model", model)
+		rs.shader.SetUniformFMat3("u_NormalMatrix", model.Mat3().Inv().Transpose())
+
+		if skin := renderComponent.Skin; skin != nil {
+			rs.shader.SetUniformInt("u_Skinned", 1)
+			rs.shader.SetUniformFMat4Array("u_Joints", skin.jointMatrices(model))
+		} else {
+			rs.shader.SetUniformInt("u_Skinned", 0)
+		}
+
+		for i := range mesh.Parts {
+			part := &mesh.Parts[i]
+			rs.setMaterial(part)
+			mesh.Array.DrawRange(part.First, part.Count)
+		}
+
+		if profiler != nil {
+			profiler.AddDrawCalls(len(mesh.Parts))
+		}
+	}
+
+	if len(batches) == 0 {
+		return
+	}
+	rs.shader.SetUniformInt("u_Instanced", 1)
+	rs.shader.SetUniformInt("u_Skinned", 0)
+	rs.shader.SetUniformFMat4("u_ViewProjection", PVM)
+	for _, batch := range batches {
+		mesh := batch.mesh
+		mesh.Array.SetInstanceData(batch.models)
+		for i := range mesh.Parts {
+			part := &mesh.Parts[i]
+			rs.setMaterial(part)
+			mesh.Array.DrawInstancedRange(part.First, part.Count, len(batch.models))
+		}
+
+		if profiler != nil {
+			profiler.AddDrawCalls(len(mesh.Parts))
+		}
+	}
+}
+
+// An instanceBatch is a mesh drawn once for several entities.
+type instanceBatch struct {
+	mesh   *graphics.MeshAsset
+	models []mgl32.Mat4
+}
+
+// batches groups the entities sharing a mesh into instanced draws. Skinned
+// entities and those whose mesh nobody else shows are returned as single,
+// they are drawn one by one.
+func (rs *RenderSystem) batches(ids []uint) (single []uint, batches []instanceBatch) {
+	groups := make(map[*graphics.MeshAsset][]uint)
+	var meshes []*graphics.MeshAsset
+	for _, id := range ids {
+		renderComponent := rs.MultiSystem.Entities[id][0].(*RenderComponent)
+		if renderComponent.Skin != nil {
+			single = append(single, id)
+			continue
+		}
+
+		mesh := renderComponent.mesh
+		if _, ok := groups[mesh]; !ok {
+			meshes = append(meshes, mesh)
+		}
+		groups[mesh] = append(groups[mesh], id)
+	}
+
+	// keep the order the entities came in, so the frames don't flicker
+	for _, mesh := range meshes {
+		group := groups[mesh]
+		if len(group) == 1 {
+			single = append(single, group[0])
+			continue
+		}
+
+		batch := instanceBatch{mesh: mesh, models: make([]mgl32.Mat4, len(group))}
+		for i, id := range group {
+			batch.models[i] = rs.MultiSystem.Entities[id][1].(*SpaceComponent).worldMatrix()
+		}
+		batches = append(batches, batch)
+	}
+
+	return single, batches
+}
+
+// updateBounds updates the bounds of the entities in the BVH, rebuilding it
+// if entities were added or removed.
+func (rs *RenderSystem) updateBounds() {
+	if rs.bounds == nil {
+		rs.bounds = make(map[uint]gome.AABB)
+	}
+	for id := range rs.bounds {
+		delete(rs.bounds, id)
+	}
+	rs.skinned = rs.skinned[:0]
+
+	for id, components := range rs.MultiSystem.Entities {
+		renderComponent := components[0].(*RenderComponent)
+		spaceComponent := components[1].(*SpaceComponent)
+		mesh := renderComponent.mesh
+
+		// entities whose model failed or is still loading have nothing to draw
+		if mesh == nil || mesh.Array.Empty() {
+			continue
+		}
+
+		rs.bounds[id] = transformAABB(mesh.Bounds, spaceComponent.worldMatrix())
+		if renderComponent.Skin != nil {
+			rs.skinned = append(rs.skinned, id)
+		}
+	}
+
+	changed := len(rs.bounds) != rs.bvh.Len()
+	for id, box := range rs.bounds {
+		if changed {
+			break
+		}
+		changed = !rs.bvh.Set(id, box)
+	}
+
+	if changed {
+		rs.bvh.Build(rs.bounds)
+	} else {
+		rs.bvh.Refit()
+	}
+}
+
+// visible returns the entities with a mesh that may be inside a frustum.
+// Skinned entities are always included, since their animation can move them
+// out of their bounds.
+func (rs *RenderSystem) visible(frustum Frustum) []uint {
+	ids := append([]uint{}, rs.skinned...)
+	rs.bvh.QueryFrustum(frustum, func(id uint) bool {
+		if rs.MultiSystem.Entities[id][0].(*RenderComponent).Skin == nil {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	return ids
+}
 
-		MVP := PVM.Mul4(spaceComponent.modelMatrix())
-		rs.Shader.SetUniformFMat4("u_MVP", MVP)
+// BVH returns the bounding volume hierarchy of the entities with a mesh,
+// with their bounds in world space as of the last frame.
+func (rs *RenderSystem) BVH() *BVH { return &rs.bvh }
+
+// postProcessor returns the post processor of a camera. Every camera has its
+// own, since they keep images of the size of the viewport.
+func (rs *RenderSystem) postProcessor(camera uint) *graphics.PostProcessor {
+	post, ok := rs.posts[camera]
+	if !ok {
+		post = graphics.NewPostProcessor(rs.Assets)
+		rs.posts[camera] = post
+	}
+	return post
+}
 
-		gl.BindTexture(gl.TEXTURE_2D, renderComponent.texture)
+// deletePostProcessors deletes the post processors of cameras that were
+// removed or have no post effects anymore.
+func (rs *RenderSystem) deletePostProcessors(cameras []camera) {
+	used := make(map[uint]bool, len(cameras))
+	for _, camera := range cameras {
+		used[camera.id] = len(camera.PostEffects) > 0
+	}
+
+	for id, post := range rs.posts {
+		if !used[id] {
+			post.Delete()
+			delete(rs.posts, id)
+		}
+	}
+}
+
+// environmentUnit is the texture unit of the environment cubemap.
+const environmentUnit = 7
+
+// materialSamplers are the samplers of the maps of a mesh part, in the order
+// of their texture units.
+var materialSamplers = []string{
+	"tex", "u_SpecularMap", "u_NormalMap",
+	"u_MetallicMap", "u_RoughnessMap", "u_OcclusionMap", "u_EmissiveMap",
+}
+
+// setMaterial sets the uniforms and binds the textures of the material of a
+// mesh part.
+func (rs *RenderSystem) setMaterial(part *graphics.MeshPart) {
+	material := part.Material
+	rs.shader.SetUniformFVec3("u_Diffuse", material.Diffuse)
+	rs.shader.SetUniformFVec3("u_Specular", material.Specular)
+	rs.shader.SetUniformFloat("u_Shininess", material.Shininess)
+	rs.shader.SetUniformFloat("u_Dissolve", material.Dissolve)
+	rs.shader.SetUniformFVec3("u_Emissive", material.Emissive)
+
+	pbr := int32(0)
+	if material.PBR {
+		pbr = 1
+	}
+	rs.shader.SetUniformInt("u_PBR", pbr)
+	rs.shader.SetUniformFloat("u_Metallic", material.Metallic)
+	rs.shader.SetUniformFloat("u_Roughness", material.Roughness)
+
+	normalMapped := int32(0)
+	if part.NormalMap != nil {
+		normalMapped = 1
+	}
+	rs.shader.SetUniformInt("u_NormalMapped", normalMapped)
+
+	// maps a material doesn't have are white, which leaves the factors as they
+	// are; the emissive map is white too, but the emissive factor is black by
+	// default
+	for unit, texture := range []*graphics.TextureAsset{
+		part.DiffuseMap, part.SpecularMap, part.NormalMap,
+		part.MetallicMap, part.RoughnessMap, part.OcclusionMap, part.EmissiveMap,
+	} {
+		rs.bindTexture(uint32(unit), texture)
+	}
+}
+
+// SetEnvironment loads the faces of a cubemap (see graphics.LoadEnvironment)
+// as the environment of the scene, replacing the current one. Errors are
+// passed to gome.HandleError; if it ignores them, the scene keeps the old
+// environment.
+func (rs *RenderSystem) SetEnvironment(faces [6]string) error {
+	environment, err := graphics.LoadEnvironment(faces)
+	if err != nil {
+		if herr := gome.HandleError(err); herr != nil {
+			return herr
+		}
+		gome.Log.Warn(gome.CategoryAssets, "keeping the old environment", "err", err)
+		return nil
+	}
+
+	if rs.environment != nil {
+		rs.environment.Delete()
+	}
+	rs.environment = environment
+	rs.EnvironmentMap = faces
+
+	return nil
+}
+
+// bindTexture binds a texture to a texture unit, or the white texture if it
+// is nil.
+func (rs *RenderSystem) bindTexture(unit uint32, texture *graphics.TextureAsset) {
+	if texture == nil {
+		texture = rs.white
+	}
+
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(gl.TEXTURE_2D, texture.ID)
+}
+
+// Shutdown frees the GPU memory of all assets. It can be called even if Init
+// failed or never ran.
+func (rs *RenderSystem) Shutdown(scene *gome.Scene) {
+	// Init failed before the asset manager was created, so nothing was uploaded
+	if rs.Assets == nil {
+		return
+	}
+
+	for id := range rs.MultiSystem.Entities {
+		rs.release(id)
+	}
+
+	if rs.white != nil {
+		rs.white.Release()
+		rs.white = nil
+	}
+	rs.shadows.delete()
+	rs.picker.delete()
+	rs.deletePostProcessors(nil)
+	if rs.environment != nil {
+		rs.environment.Delete()
+		rs.environment = nil
+	}
+	rs.Assets.Clear()
+	rs.Assets = nil
+	rs.gpuTimer.Delete()
 
-		VAO.Draw()
+	if rs.sourceMounted {
+		graphics.UnmountSourceAssets()
+		rs.sourceMounted = false
 	}
 }
 
@@ -0,0 +1,348 @@
+package common
+
+import (
+	"gitlocal/gome"
+	"gitlocal/gome/common/graphics"
+	"math"
+
+	"github.com/go-gl/gl/v4.6-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// MaxCascades is the maximum number of cascades of a directional shadow map.
+const MaxCascades = 4
+
+// MaxPointShadows is the number of point and spot lights that can cast
+// shadows at once.
+const MaxPointShadows = 4
+
+// The texture units of the shadow maps in the default shader, after the
+// material maps and the environment.
+const (
+	cascadeUnit     = 8
+	pointShadowUnit = 9
+)
+
+/*
+	Shadows
+*/
+
+// shadowRenderer renders the shadow maps of the lights casting shadows.
+type shadowRenderer struct {
+	shader   *graphics.ShaderAsset
+	cascades *graphics.ShadowMap
+	cubes    []*graphics.ShadowMap
+
+	// the results of the last pass, set as uniforms of the default shader
+	cascadeMatrices []mgl32.Mat4
+	cascadeSplits   [MaxCascades]float32
+	pointFar        [MaxPointShadows]float32
+	origin, forward mgl32.Vec3
+}
+
+// shadowSettings returns the shadow settings of the system with defaults
+// filled in.
+func (rs *RenderSystem) shadowSettings() (resolution, cascades int, distance float32) {
+	resolution, cascades, distance = rs.ShadowResolution, rs.ShadowCascades, rs.ShadowDistance
+	if resolution <= 0 {
+		resolution = 2048
+	}
+	if cascades <= 0 {
+		cascades = 3
+	}
+	if cascades > MaxCascades {
+		cascades = MaxCascades
+	}
+	if distance <= 0 {
+		distance = 50
+	}
+	return
+}
+
+// renderShadows assigns shadow maps to the lights casting shadows and renders
+// them. PVM is the projection view matrix of the camera.
+func (rs *RenderSystem) renderShadows(lights []LightSource, PVM mgl32.Mat4) {
+	sr := &rs.shadows
+	sr.cascadeMatrices = sr.cascadeMatrices[:0]
+
+	// pick the lights first, so nothing gets rendered without shadows
+	directional, points := -1, []int{}
+	for i, light := range lights {
+		if !light.CastShadows {
+			continue
+		}
+
+		switch {
+		case light.Type == DIRECTIONAL_LIGHT && directional < 0:
+			directional = i
+		case light.Type != DIRECTIONAL_LIGHT && len(points) < MaxPointShadows:
+			points = append(points, i)
+		}
+	}
+	if directional < 0 && len(points) == 0 {
+		return
+	}
+
+	// keep the framebuffer and viewport the scene gets drawn to
+	viewport := [4]int32{}
+	var framebuffer int32
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+	gl.GetIntegerv(gl.DRAW_FRAMEBUFFER_BINDING, &framebuffer)
+	defer func() {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(framebuffer))
+		gl.Viewport(viewport[0], viewport[1], viewport[2], viewport[3])
+		gl.Disable(gl.POLYGON_OFFSET_FILL)
+	}()
+
+	gl.UseProgram(sr.shader.Program)
+	gl.Enable(gl.POLYGON_OFFSET_FILL)
+	gl.PolygonOffset(2, 4)
+
+	resolution, cascades, distance := rs.shadowSettings()
+
+	if directional >= 0 && rs.shadowMap(&sr.cascades, resolution, cascades, false) {
+		light := &lights[directional]
+		light.shadow = 0
+
+		direction := mgl32.Vec3{light.Direction.X, light.Direction.Y, light.Direction.Z}
+		matrices, splits, origin, forward := cascadeMatrices(PVM, direction, cascades, distance, resolution)
+		sr.cascadeMatrices, sr.origin, sr.forward = matrices, origin, forward
+		copy(sr.cascadeSplits[:], splits)
+
+		sr.shader.SetUniformInt("u_LinearDepth", 0)
+		for i, matrix := range matrices {
+			sr.cascades.Bind(i)
+			rs.drawShadowCasters(matrix)
+		}
+	}
+
+	for slot, index := range points {
+		for len(sr.cubes) <= slot {
+			sr.cubes = append(sr.cubes, nil)
+		}
+		if !rs.shadowMap(&sr.cubes[slot], resolution/2, 6, true) {
+			continue
+		}
+
+		light := &lights[index]
+		light.shadow = slot
+
+		// the light fades to less than 1% at the far plane
+		far := distance
+		if light.Attenuation > 0 {
+			far = float32(math.Min(float64(far), math.Sqrt(99/float64(light.Attenuation))))
+		}
+		sr.pointFar[slot] = far
+
+		position := mgl32.Vec3{light.Position.X, light.Position.Y, light.Position.Z}
+		projection := mgl32.Perspective(mgl32.DegToRad(90), 1, 0.05, far)
+
+		sr.shader.SetUniformInt("u_LinearDepth", 1)
+		sr.shader.SetUniformFVec3("u_LightPosition", light.Position)
+		sr.shader.SetUniformFloat("u_Far", far)
+		for face, view := range graphics.CubeFaceViews(position) {
+			sr.cubes[slot].Bind(face)
+			rs.drawShadowCasters(projection.Mul4(view))
+		}
+	}
+}
+
+// shadowMap makes sure a shadow map of a size exists, recreating it if the
+// settings changed. It returns false if the map can't be created.
+func (rs *RenderSystem) shadowMap(shadowMap **graphics.ShadowMap, size, layers int, cube bool) bool {
+	if *shadowMap != nil && (*shadowMap).Size == size && (*shadowMap).Layers == layers {
+		return true
+	}
+
+	if *shadowMap != nil {
+		(*shadowMap).Delete()
+		*shadowMap = nil
+	}
+
+	var created *graphics.ShadowMap
+	var err error
+	if cube {
+		created, err = graphics.NewCubeShadowMap(size)
+	} else {
+		created, err = graphics.NewShadowMap(size, layers)
+	}
+	if err != nil {
+		gome.Log.Error(gome.CategoryRender, "could not create shadow map", "err", err)
+		return false
+	}
+
+	*shadowMap = created
+	return true
+}
+
+// drawShadowCasters draws the depth of all entities as seen by a light.
+func (rs *RenderSystem) drawShadowCasters(lightMatrix mgl32.Mat4) {
+	shader := rs.shadows.shader
+	shader.SetUniformFMat4("u_LightMatrix", lightMatrix)
+
+	single, batches := rs.batches(rs.visible(NewFrustum(lightMatrix)))
+	shader.SetUniformInt("u_Instanced", 0)
+	for _, id := range single {
+		components := rs.MultiSystem.Entities[id]
+		renderComponent := components[0].(*RenderComponent)
+		spaceComponent := components[1].(*SpaceComponent)
+		mesh := renderComponent.mesh
+
+		model := spaceComponent.worldMatrix()
+		shader.SetUniformFMat4("u_Model", model)
+		if skin := renderComponent.Skin; skin != nil {
+			shader.SetUniformInt("u_Skinned", 1)
+			shader.SetUniformFMat4Array("u_Joints", skin.jointMatrices(model))
+		} else {
+			shader.SetUniformInt("u_Skinned", 0)
+		}
+
+		mesh.Array.Draw()
+	}
+
+	if len(batches) == 0 {
+		return
+	}
+	shader.SetUniformInt("u_Instanced", 1)
+	shader.SetUniformInt("u_Skinned", 0)
+	for _, batch := range batches {
+		array := batch.mesh.Array
+		array.SetInstanceData(batch.models)
+		for _, part := range batch.mesh.Parts {
+			array.DrawInstancedRange(part.First, part.Count, len(batch.models))
+		}
+	}
+}
+
+// setShadowUniforms sets the uniforms of the shadow maps of the last pass in
+// the default shader and binds them.
+func (rs *RenderSystem) setShadowUniforms() {
+	sr := &rs.shadows
+	shader := rs.shader
+
+	shader.SetUniformInt("u_CascadeMap", cascadeUnit)
+	shader.SetUniformInt("u_CascadeCount", int32(len(sr.cascadeMatrices)))
+	if len(sr.cascadeMatrices) > 0 {
+		shader.SetUniformFMat4Array("u_CascadeMatrices", sr.cascadeMatrices)
+		// FloatVector4 starts with W
+		shader.SetUniformFVec4("u_CascadeSplits", gome.FloatVector4{
+			W: sr.cascadeSplits[0], X: sr.cascadeSplits[1], Y: sr.cascadeSplits[2], Z: sr.cascadeSplits[3],
+		})
+		shader.SetUniformFVec3("u_CascadeOrigin", gome.FloatVector3{X: sr.origin.X(), Y: sr.origin.Y(), Z: sr.origin.Z()})
+		shader.SetUniformFVec3("u_CascadeForward", gome.FloatVector3{X: sr.forward.X(), Y: sr.forward.Y(), Z: sr.forward.Z()})
+
+		gl.ActiveTexture(gl.TEXTURE0 + cascadeUnit)
+		gl.BindTexture(gl.TEXTURE_2D_ARRAY, sr.cascades.Texture)
+	}
+
+	for slot := 0; slot < MaxPointShadows; slot++ {
+		shader.SetUniformInt(pointShadowSamplers[slot], int32(pointShadowUnit+slot))
+		if slot < len(sr.cubes) && sr.cubes[slot] != nil {
+			gl.ActiveTexture(gl.TEXTURE0 + uint32(pointShadowUnit+slot))
+			gl.BindTexture(gl.TEXTURE_CUBE_MAP, sr.cubes[slot].Texture)
+		}
+	}
+	shader.SetUniformFVec4("u_PointShadowFar", gome.FloatVector4{
+		W: sr.pointFar[0], X: sr.pointFar[1], Y: sr.pointFar[2], Z: sr.pointFar[3],
+	})
+}
+
+// pointShadowSamplers are the samplers of the cube shadow maps, which can't
+// be an array in GLSL 3.30.
+var pointShadowSamplers = [MaxPointShadows]string{
+	"u_PointShadow0", "u_PointShadow1", "u_PointShadow2", "u_PointShadow3",
+}
+
+// delete frees the shadow maps.
+func (sr *shadowRenderer) delete() {
+	if sr.cascades != nil {
+		sr.cascades.Delete()
+		sr.cascades = nil
+	}
+	for _, cube := range sr.cubes {
+		if cube != nil {
+			cube.Delete()
+		}
+	}
+	sr.cubes = nil
+}
+
+// cascadeMatrices splits the view frustum of a camera into cascades up to a
+// distance and returns the light matrix of every cascade and the distances
+// where the cascades end, measured from the center of the near plane (origin)
+// in the direction the camera looks at (forward).
+func cascadeMatrices(PVM mgl32.Mat4, direction mgl32.Vec3, cascades int, distance float32, resolution int) (matrices []mgl32.Mat4, splits []float32, origin, forward mgl32.Vec3) {
+	inverse := PVM.Inv()
+	unproject := func(x, y, z float32) mgl32.Vec3 {
+		v := inverse.Mul4x1(mgl32.Vec4{x, y, z, 1})
+		return v.Vec3().Mul(1 / v.W())
+	}
+
+	// the corners of the near and far plane, and the depth of the planes
+	nearCorners, farCorners := [4]mgl32.Vec3{}, [4]mgl32.Vec3{}
+	for i, corner := range [4][2]float32{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		nearCorners[i] = unproject(corner[0], corner[1], -1)
+		farCorners[i] = unproject(corner[0], corner[1], 1)
+	}
+	origin = unproject(0, 0, -1)
+	forward = unproject(0, 0, 1).Sub(origin).Normalize()
+
+	near := float32(0)
+	far := unproject(0, 0, 1).Sub(origin).Dot(forward)
+	end := float32(math.Min(float64(far), float64(distance)))
+
+	// mix logarithmic and uniform splits, logarithmic ones need a positive start
+	start := float32(math.Max(0.1, float64(near)))
+	depths := []float32{near}
+	for i := 1; i <= cascades; i++ {
+		f := float64(i) / float64(cascades)
+		logarithmic := float64(start) * math.Pow(float64(end/start), f)
+		uniform := float64(near) + float64(end-near)*f
+		depths = append(depths, float32(0.5*logarithmic+0.5*uniform))
+	}
+
+	up := mgl32.Vec3{0, 1, 0}
+	if math.Abs(float64(direction.Dot(up))) > 0.99 {
+		up = mgl32.Vec3{0, 0, 1}
+	}
+
+	for i := 0; i < cascades; i++ {
+		// the corners of the slice of the frustum
+		points := []mgl32.Vec3{}
+		for _, depth := range depths[i : i+2] {
+			t := depth / far
+			for c := range nearCorners {
+				points = append(points, nearCorners[c].Add(farCorners[c].Sub(nearCorners[c]).Mul(t)))
+			}
+		}
+
+		// a bounding sphere doesn't change size when the camera rotates, so
+		// the shadows don't flicker
+		center := mgl32.Vec3{}
+		for _, point := range points {
+			center = center.Add(point)
+		}
+		center = center.Mul(1 / float32(len(points)))
+
+		radius := float32(0)
+		for _, point := range points {
+			radius = float32(math.Max(float64(radius), float64(point.Sub(center).Len())))
+		}
+		radius = float32(math.Ceil(float64(radius)*16) / 16)
+
+		// objects outside of the cascade can still cast shadows into it
+		eye := center.Sub(direction.Mul(radius + distance))
+		view := mgl32.LookAtV(eye, center, up)
+		projection := mgl32.Ortho(-radius, radius, -radius, radius, 0, 2*radius+distance)
+
+		// move in steps of whole texels, so edges don't shimmer
+		origin := projection.Mul4(view).Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Mul(float32(resolution) / 2)
+		projection[12] += (float32(math.Round(float64(origin.X()))) - origin.X()) * 2 / float32(resolution)
+		projection[13] += (float32(math.Round(float64(origin.Y()))) - origin.Y()) * 2 / float32(resolution)
+
+		matrices = append(matrices, projection.Mul4(view))
+		splits = append(splits, depths[i+1])
+	}
+
+	return matrices, splits, origin, forward
+}
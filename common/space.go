@@ -11,6 +11,8 @@ type SpaceComponent struct {
 	translationMatrix mgl32.Mat4
 	rotationQuat      mgl32.Quat
 	scaleMatrix       mgl32.Mat4
+
+	parent *SpaceComponent
 }
 
 func (*SpaceComponent) Name() string { return "Space" }
@@ -37,6 +39,25 @@ func (sc *SpaceComponent) modelMatrix() mgl32.Mat4 {
 		Mul4(sc.scaleMatrix)
 }
 
+// worldMatrix calculates the model matrix of the entity including the
+// transforms of its parents.
+func (sc *SpaceComponent) worldMatrix() mgl32.Mat4 {
+	if sc.parent == nil {
+		return sc.modelMatrix()
+	}
+	return sc.parent.worldMatrix().Mul4(sc.modelMatrix())
+}
+
+// SetParent makes the position, rotation and size of the entity relative to
+// the ones of another entity, so it moves along with it. A nil parent makes
+// them absolute again. Parents must not form a cycle.
+func (sc *SpaceComponent) SetParent(parent *SpaceComponent) {
+	sc.parent = parent
+}
+
+// GetParent returns the parent set with SetParent.
+func (sc *SpaceComponent) GetParent() *SpaceComponent { return sc.parent }
+
 // SetPosition sets the entities position in 3-dimensional space.
 func (sc *SpaceComponent) SetPosition(pos gome.FloatVector3) {
 	sc.translationMatrix = mgl32.Translate3D(pos.X, pos.Y, pos.Z)
@@ -48,6 +69,19 @@ func (sc *SpaceComponent) AddRotation(axis gome.FloatVector3, angle float32) {
 	sc.rotationQuat = sc.rotationQuat.Mul(mgl32.QuatRotate(angle, mglAxis))
 }
 
+// SetOrientation sets the rotation of the entity.
+func (sc *SpaceComponent) SetOrientation(rotation mgl32.Quat) {
+	sc.rotationQuat = rotation
+}
+
+// GetOrientation returns the rotation of the entity.
+func (sc *SpaceComponent) GetOrientation() mgl32.Quat {
+	if sc.rotationQuat == (mgl32.Quat{}) {
+		return mgl32.QuatIdent()
+	}
+	return sc.rotationQuat
+}
+
 // SetSize sets the 3-dimensional scale of the entity.
 func (sc *SpaceComponent) SetSize(size gome.FloatVector3) {
 	sc.scaleMatrix = mgl32.Scale3D(size.X, size.Y, size.Z)
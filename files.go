@@ -0,0 +1,169 @@
+package gome
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A mount is a file system mounted into a FileSystem under a prefix.
+type mount struct {
+	prefix string
+	fsys   fs.FS
+	closer io.Closer
+}
+
+// A FileSystem is a virtual file system made of other file systems (directories,
+// zip archives, embedded files, ...) mounted under path prefixes. When opening a
+// file, the mounts are searched from the last mounted to the first, so later
+// mounts can override files of earlier ones.
+type FileSystem struct {
+	mutex  sync.RWMutex
+	mounts []mount
+}
+
+// Files is the file system all assets of the engine are loaded from. By default,
+// the working directory is mounted at the root, and the assets of the engine
+// itself under "gome".
+var Files = &FileSystem{
+	mounts: []mount{{prefix: "", fsys: os.DirFS(".")}},
+}
+
+// Mount mounts a file system under a prefix. With an empty prefix, its files
+// are found at the root.
+func (fsys *FileSystem) Mount(prefix string, mounted fs.FS) {
+	fsys.mount(mount{prefix: cleanPrefix(prefix), fsys: mounted})
+}
+
+// MountDir mounts a directory of the operating system under a prefix.
+func (fsys *FileSystem) MountDir(prefix string, dir string) {
+	fsys.Mount(prefix, os.DirFS(dir))
+}
+
+// MountZip mounts the contents of a zip archive under a prefix. The archive stays
+// open until it gets unmounted.
+func (fsys *FileSystem) MountZip(prefix string, file string) error {
+	reader, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+
+	fsys.mount(mount{prefix: cleanPrefix(prefix), fsys: reader, closer: reader})
+	return nil
+}
+
+func (fsys *FileSystem) mount(m mount) {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	fsys.mounts = append(fsys.mounts, m)
+}
+
+// Unmount removes all file systems mounted under a prefix.
+func (fsys *FileSystem) Unmount(prefix string) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	prefix = cleanPrefix(prefix)
+	var err error
+	kept := fsys.mounts[:0]
+	for _, m := range fsys.mounts {
+		if m.prefix != prefix {
+			kept = append(kept, m)
+			continue
+		}
+
+		if m.closer != nil {
+			if cerr := m.closer.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	fsys.mounts = kept
+
+	return err
+}
+
+// UnmountFS removes the last mount of a file system under a prefix, keeping the
+// other file systems mounted under it. The file system has to be comparable,
+// e.g. a pointer or one returned by os.DirFS.
+func (fsys *FileSystem) UnmountFS(prefix string, mounted fs.FS) error {
+	fsys.mutex.Lock()
+	defer fsys.mutex.Unlock()
+
+	prefix = cleanPrefix(prefix)
+	for i := len(fsys.mounts) - 1; i >= 0; i-- {
+		m := fsys.mounts[i]
+		if m.prefix != prefix || m.fsys != mounted {
+			continue
+		}
+
+		fsys.mounts = append(fsys.mounts[:i], fsys.mounts[i+1:]...)
+		if m.closer != nil {
+			return m.closer.Close()
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// cleanPrefix brings a mount prefix into the form of a valid fs.FS path.
+func cleanPrefix(prefix string) string {
+	prefix = path.Clean("/" + filepath.ToSlash(prefix))
+	return strings.TrimPrefix(prefix, "/")
+}
+
+// Open opens a file. Paths use forward slashes, like with fs.FS. Absolute paths
+// and paths leading out of the root (like "../file") are not part of the virtual
+// file system and get opened from the operating system directly.
+func (fsys *FileSystem) Open(name string) (fs.File, error) {
+	clean := path.Clean(filepath.ToSlash(name))
+	if filepath.IsAbs(name) || !fs.ValidPath(clean) {
+		return os.Open(name)
+	}
+
+	fsys.mutex.RLock()
+	defer fsys.mutex.RUnlock()
+
+	for i := len(fsys.mounts) - 1; i >= 0; i-- {
+		m := fsys.mounts[i]
+
+		// get the path inside the mounted file system
+		inner := clean
+		if m.prefix != "" {
+			if clean == m.prefix {
+				inner = "."
+			} else if strings.HasPrefix(clean, m.prefix+"/") {
+				inner = clean[len(m.prefix)+1:]
+			} else {
+				continue
+			}
+		}
+
+		file, err := m.fsys.Open(inner)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return file, err
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// Stat returns information about a file.
+func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return file.Stat()
+}
@@ -0,0 +1,222 @@
+package gome
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Levels and Categories
+*/
+
+// A LogLevel tells how important a log message is.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+
+	// LevelOff can be used as a minimum level to silence a category.
+	LevelOff
+)
+
+func (ll LogLevel) String() string {
+	switch ll {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "OFF"
+	}
+}
+
+// A LogCategory tells which part of the engine a log message comes from.
+type LogCategory string
+
+const (
+	CategoryEngine = LogCategory("engine")
+	CategoryRender = LogCategory("render")
+	CategoryScene  = LogCategory("scene")
+	CategoryAssets = LogCategory("assets")
+	CategoryInput  = LogCategory("input")
+)
+
+/*
+	Sinks
+*/
+
+// A LogSink receives every log message that passed the level filter. Attributes
+// are given as alternating keys and values, like with log/slog.
+type LogSink interface {
+	Write(level LogLevel, category LogCategory, msg string, attrs ...interface{})
+}
+
+// A WriterSink writes log messages as single lines of text to a writer.
+type WriterSink struct {
+	Writer io.Writer
+	mutex  sync.Mutex
+}
+
+func (ws *WriterSink) Write(level LogLevel, category LogCategory, msg string, attrs ...interface{}) {
+	line := strings.Builder{}
+	fmt.Fprintf(&line, "%s %-5s [%s] %s", time.Now().Format("15:04:05.000"), level, category, msg)
+
+	for i := 0; i < len(attrs); i += 2 {
+		if i+1 < len(attrs) {
+			fmt.Fprintf(&line, " %v=%v", attrs[i], attrs[i+1])
+		} else {
+			fmt.Fprintf(&line, " %v", attrs[i])
+		}
+	}
+	line.WriteByte('\n')
+
+	ws.mutex.Lock()
+	io.WriteString(ws.Writer, line.String())
+	ws.mutex.Unlock()
+}
+
+// A SlogSink passes log messages on to a log/slog logger. The category is added
+// as the "category" attribute.
+type SlogSink struct {
+	Logger *slog.Logger
+}
+
+func (ss *SlogSink) Write(level LogLevel, category LogCategory, msg string, attrs ...interface{}) {
+	slevel := slog.LevelDebug
+	switch level {
+	case LevelInfo:
+		slevel = slog.LevelInfo
+	case LevelWarn:
+		slevel = slog.LevelWarn
+	case LevelError:
+		slevel = slog.LevelError
+	}
+
+	ss.Logger.Log(context.Background(), slevel, msg,
+		append([]interface{}{"category", string(category)}, attrs...)...)
+}
+
+/*
+	Logger
+*/
+
+type logger struct {
+	mutex      sync.RWMutex
+	sinks      []LogSink
+	level      LogLevel
+	categories map[LogCategory]LogLevel
+}
+
+// Log is the logger used by the engine. By default, it writes messages of level
+// info and above to stderr.
+var Log = &logger{
+	sinks:      []LogSink{&WriterSink{Writer: os.Stderr}},
+	level:      LevelInfo,
+	categories: make(map[LogCategory]LogLevel),
+}
+
+// SetLevel sets the minimum level of messages that get logged for all
+// categories without their own level.
+func (l *logger) SetLevel(level LogLevel) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.level = level
+}
+
+// SetCategoryLevel sets the minimum level of messages that get logged for one category.
+// E.g. to debug the renderer only, set the global level to LevelWarn and the
+// render category to LevelDebug.
+func (l *logger) SetCategoryLevel(category LogCategory, level LogLevel) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.categories[category] = level
+}
+
+// ResetCategoryLevel makes a category use the global level again.
+func (l *logger) ResetCategoryLevel(category LogCategory) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.categories, category)
+}
+
+// SetSinks replaces all sinks. Without sinks, nothing gets logged.
+func (l *logger) SetSinks(sinks ...LogSink) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sinks = sinks
+}
+
+// AddSink adds a sink receiving all log messages.
+func (l *logger) AddSink(sink LogSink) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sinks = append(l.sinks, sink)
+}
+
+// Enabled checks if a message of a specific level and category would be logged.
+// It can be used to skip expensive preparation of log attributes.
+func (l *logger) Enabled(category LogCategory, level LogLevel) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.enabled(category, level)
+}
+
+func (l *logger) enabled(category LogCategory, level LogLevel) bool {
+	if min, ok := l.categories[category]; ok {
+		return level >= min && level != LevelOff
+	}
+	return level >= l.level && level != LevelOff
+}
+
+// Write sends a message to all sinks if its level is enabled for the category.
+func (l *logger) Write(level LogLevel, category LogCategory, msg string, attrs ...interface{}) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if !l.enabled(category, level) {
+		return
+	}
+
+	for _, sink := range l.sinks {
+		sink.Write(level, category, msg, attrs...)
+	}
+}
+
+// Debug logs a message only useful when debugging.
+func (l *logger) Debug(category LogCategory, msg string, attrs ...interface{}) {
+	l.Write(LevelDebug, category, msg, attrs...)
+}
+
+// Info logs an informational message.
+func (l *logger) Info(category LogCategory, msg string, attrs ...interface{}) {
+	l.Write(LevelInfo, category, msg, attrs...)
+}
+
+// Warn logs a message about something that went wrong, but was recovered from.
+func (l *logger) Warn(category LogCategory, msg string, attrs ...interface{}) {
+	l.Write(LevelWarn, category, msg, attrs...)
+}
+
+// Error logs a message about an error.
+func (l *logger) Error(category LogCategory, msg string, attrs ...interface{}) {
+	l.Write(LevelError, category, msg, attrs...)
+}
@@ -1,5 +1,7 @@
 package gome
 
+import "math"
+
 const precision = float32(10 ^ -4)
 
 /*
@@ -96,3 +98,208 @@ func (fv FloatVector4) IsSimilarTo(other FloatVector4) bool {
 		isNear(fv.Y, other.Y) &&
 		isNear(fv.Z, other.Z)
 }
+
+/*
+	Bounding volumes
+*/
+
+// An AABB is an axis-aligned bounding box.
+type AABB struct {
+	Min, Max FloatVector3
+}
+
+// NewAABB returns the smallest box containing all points. Without points,
+// the box is empty.
+func NewAABB(points ...FloatVector3) AABB {
+	box := AABB{}
+	for i, point := range points {
+		if i == 0 {
+			box = AABB{Min: point, Max: point}
+		} else {
+			box = box.Extend(point)
+		}
+	}
+
+	return box
+}
+
+// Extend returns the box grown to contain a point.
+func (box AABB) Extend(point FloatVector3) AABB {
+	box.Min = FloatVector3{X: min32(box.Min.X, point.X), Y: min32(box.Min.Y, point.Y), Z: min32(box.Min.Z, point.Z)}
+	box.Max = FloatVector3{X: max32(box.Max.X, point.X), Y: max32(box.Max.Y, point.Y), Z: max32(box.Max.Z, point.Z)}
+	return box
+}
+
+// Center returns the center of the box.
+func (box AABB) Center() FloatVector3 {
+	return FloatVector3{
+		X: (box.Min.X + box.Max.X) / 2,
+		Y: (box.Min.Y + box.Max.Y) / 2,
+		Z: (box.Min.Z + box.Max.Z) / 2,
+	}
+}
+
+// Size returns the extent of the box along each axis.
+func (box AABB) Size() FloatVector3 {
+	return FloatVector3{X: box.Max.X - box.Min.X, Y: box.Max.Y - box.Min.Y, Z: box.Max.Z - box.Min.Z}
+}
+
+// Union returns the smallest box containing both boxes.
+func (box AABB) Union(other AABB) AABB {
+	return box.Extend(other.Min).Extend(other.Max)
+}
+
+// Intersects returns whether the boxes overlap or touch.
+func (box AABB) Intersects(other AABB) bool {
+	return box.Min.X <= other.Max.X && box.Max.X >= other.Min.X &&
+		box.Min.Y <= other.Max.Y && box.Max.Y >= other.Min.Y &&
+		box.Min.Z <= other.Max.Z && box.Max.Z >= other.Min.Z
+}
+
+// SurfaceArea returns the area of the faces of the box.
+func (box AABB) SurfaceArea() float32 {
+	size := box.Size()
+	return 2 * (size.X*size.Y + size.Y*size.Z + size.Z*size.X)
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// A Sphere is a bounding sphere.
+type Sphere struct {
+	Center FloatVector3
+	Radius float32
+}
+
+// NewSphere returns a sphere containing all points, centered at the center of
+// their bounding box. It is not the smallest possible sphere, but close to it
+// for most shapes.
+func NewSphere(points ...FloatVector3) Sphere {
+	center := NewAABB(points...).Center()
+
+	radius := float32(0)
+	for _, point := range points {
+		dx, dy, dz := point.X-center.X, point.Y-center.Y, point.Z-center.Z
+		radius = max32(radius, dx*dx+dy*dy+dz*dz)
+	}
+
+	return Sphere{Center: center, Radius: float32(math.Sqrt(float64(radius)))}
+}
+
+/*
+	Rays
+*/
+
+// A Ray is a half-line starting at Origin, e.g. from a camera through the
+// cursor. Distances along it are in lengths of Direction.
+type Ray struct {
+	Origin, Direction FloatVector3
+}
+
+// At returns the point at a distance along the ray.
+func (r Ray) At(t float32) FloatVector3 {
+	return FloatVector3{
+		X: r.Origin.X + r.Direction.X*t,
+		Y: r.Origin.Y + r.Direction.Y*t,
+		Z: r.Origin.Z + r.Direction.Z*t,
+	}
+}
+
+// IntersectAABB returns the distance at which the ray enters a box, 0 if it
+// starts inside of it, and whether it hits the box at all.
+func (r Ray) IntersectAABB(box AABB) (t float32, ok bool) {
+	origin := r.Origin.ToArray()
+	direction := r.Direction.ToArray()
+	low, high := box.Min.ToArray(), box.Max.ToArray()
+
+	near, far := float32(0), float32(math.Inf(1))
+	for axis := 0; axis < 3; axis++ {
+		if direction[axis] == 0 {
+			if origin[axis] < low[axis] || origin[axis] > high[axis] {
+				return 0, false
+			}
+			continue
+		}
+
+		t1 := (low[axis] - origin[axis]) / direction[axis]
+		t2 := (high[axis] - origin[axis]) / direction[axis]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		near, far = max32(near, t1), min32(far, t2)
+		if near > far {
+			return 0, false
+		}
+	}
+
+	return near, true
+}
+
+// IntersectSphere returns the distance at which the ray enters a sphere, 0 if
+// it starts inside of it, and whether it hits the sphere at all.
+func (r Ray) IntersectSphere(sphere Sphere) (t float32, ok bool) {
+	ox, oy, oz := r.Origin.X-sphere.Center.X, r.Origin.Y-sphere.Center.Y, r.Origin.Z-sphere.Center.Z
+	dx, dy, dz := r.Direction.X, r.Direction.Y, r.Direction.Z
+
+	// solve |o + t d|² = r² for t
+	a := dx*dx + dy*dy + dz*dz
+	b := ox*dx + oy*dy + oz*dz
+	c := ox*ox + oy*oy + oz*oz - sphere.Radius*sphere.Radius
+	discriminant := b*b - a*c
+	if a == 0 || discriminant < 0 {
+		return 0, false
+	}
+
+	root := float32(math.Sqrt(float64(discriminant)))
+	far := (-b + root) / a
+	if far < 0 {
+		return 0, false
+	}
+	return max32((-b-root)/a, 0), true
+}
+
+// IntersectTriangle returns the distance at which the ray hits a triangle
+// from either side, and whether it hits it at all.
+func (r Ray) IntersectTriangle(a, b, c FloatVector3) (t float32, ok bool) {
+	// Möller–Trumbore
+	e1 := FloatVector3{X: b.X - a.X, Y: b.Y - a.Y, Z: b.Z - a.Z}
+	e2 := FloatVector3{X: c.X - a.X, Y: c.Y - a.Y, Z: c.Z - a.Z}
+	p := cross(r.Direction, e2)
+	determinant := dot(e1, p)
+	if determinant > -1e-12 && determinant < 1e-12 {
+		return 0, false
+	}
+
+	inverse := 1 / determinant
+	s := FloatVector3{X: r.Origin.X - a.X, Y: r.Origin.Y - a.Y, Z: r.Origin.Z - a.Z}
+	u := dot(s, p) * inverse
+	if u < 0 || u > 1 {
+		return 0, false
+	}
+
+	q := cross(s, e1)
+	v := dot(r.Direction, q) * inverse
+	if v < 0 || u+v > 1 {
+		return 0, false
+	}
+
+	t = dot(e2, q) * inverse
+	return t, t >= 0
+}
+
+func dot(a, b FloatVector3) float32 { return a.X*b.X + a.Y*b.Y + a.Z*b.Z }
+
+func cross(a, b FloatVector3) FloatVector3 {
+	return FloatVector3{X: a.Y*b.Z - a.Z*b.Y, Y: a.Z*b.X - a.X*b.Z, Z: a.X*b.Y - a.Y*b.X}
+}
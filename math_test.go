@@ -0,0 +1,123 @@
+package gome
+
+import (
+	"math"
+	"testing"
+)
+
+// near compares distances, independent of the precision of IsSimilarTo.
+func near(a, b float32) bool { return math.Abs(float64(a-b)) < 1e-4 }
+
+func TestRayIntersectAABB(t *testing.T) {
+	box := AABB{Min: FloatVector3{X: -1, Y: -1, Z: -1}, Max: FloatVector3{X: 1, Y: 1, Z: 1}}
+
+	tests := []struct {
+		name string
+		ray  Ray
+		t    float32
+		ok   bool
+	}{
+		{"straight hit", Ray{FloatVector3{Z: 5}, FloatVector3{Z: -1}}, 4, true},
+		{"scaled direction", Ray{FloatVector3{Z: 5}, FloatVector3{Z: -2}}, 2, true},
+		{"diagonal hit", Ray{FloatVector3{X: -3, Y: -3, Z: -3}, FloatVector3{X: 1, Y: 1, Z: 1}}, 2, true},
+		{"inside", Ray{FloatVector3{}, FloatVector3{X: 1}}, 0, true},
+		{"pointing away", Ray{FloatVector3{Z: 5}, FloatVector3{Z: 1}}, 0, false},
+		{"miss", Ray{FloatVector3{X: 2, Z: 5}, FloatVector3{Z: -1}}, 0, false},
+		{"parallel outside", Ray{FloatVector3{Y: 2}, FloatVector3{X: 1}}, 0, false},
+		{"parallel on the face", Ray{FloatVector3{X: -5, Y: 1}, FloatVector3{X: 1}}, 4, true},
+		{"zero direction inside", Ray{FloatVector3{}, FloatVector3{}}, 0, true},
+	}
+
+	for _, test := range tests {
+		got, ok := test.ray.IntersectAABB(box)
+		if ok != test.ok || (ok && !near(got, test.t)) {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.name, got, ok, test.t, test.ok)
+		}
+	}
+}
+
+func TestRayIntersectSphere(t *testing.T) {
+	sphere := Sphere{Center: FloatVector3{Y: 1}, Radius: 2}
+
+	tests := []struct {
+		name string
+		ray  Ray
+		t    float32
+		ok   bool
+	}{
+		{"hit", Ray{FloatVector3{Y: 1, Z: 10}, FloatVector3{Z: -1}}, 8, true},
+		{"tangent", Ray{FloatVector3{X: 2, Y: 1, Z: 10}, FloatVector3{Z: -1}}, 10, true},
+		{"inside", Ray{FloatVector3{Y: 1}, FloatVector3{X: 1}}, 0, true},
+		{"behind", Ray{FloatVector3{Y: 1, Z: 10}, FloatVector3{Z: 1}}, 0, false},
+		{"miss", Ray{FloatVector3{X: 3, Z: 10}, FloatVector3{Z: -1}}, 0, false},
+		{"zero direction", Ray{FloatVector3{Z: 10}, FloatVector3{}}, 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := test.ray.IntersectSphere(sphere)
+		if ok != test.ok || (ok && !near(got, test.t)) {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.name, got, ok, test.t, test.ok)
+		}
+	}
+}
+
+func TestRayIntersectTriangle(t *testing.T) {
+	a, b, c := FloatVector3{}, FloatVector3{X: 1}, FloatVector3{Y: 1}
+
+	tests := []struct {
+		name string
+		ray  Ray
+		t    float32
+		ok   bool
+	}{
+		{"front", Ray{FloatVector3{X: 0.25, Y: 0.25, Z: 2}, FloatVector3{Z: -1}}, 2, true},
+		{"back", Ray{FloatVector3{X: 0.25, Y: 0.25, Z: -3}, FloatVector3{Z: 1}}, 3, true},
+		{"corner", Ray{FloatVector3{X: 1, Z: 1}, FloatVector3{Z: -1}}, 1, true},
+		{"outside the hypotenuse", Ray{FloatVector3{X: 0.6, Y: 0.6, Z: 1}, FloatVector3{Z: -1}}, 0, false},
+		{"outside", Ray{FloatVector3{X: -0.1, Y: 0.5, Z: 1}, FloatVector3{Z: -1}}, 0, false},
+		{"behind", Ray{FloatVector3{X: 0.25, Y: 0.25, Z: 1}, FloatVector3{Z: 1}}, 0, false},
+		{"parallel", Ray{FloatVector3{X: -1, Y: 0.25}, FloatVector3{X: 1}}, 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := test.ray.IntersectTriangle(a, b, c)
+		if ok != test.ok || (ok && !near(got, test.t)) {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.name, got, ok, test.t, test.ok)
+		}
+	}
+}
+
+func TestAABB(t *testing.T) {
+	box := NewAABB(FloatVector3{X: 1, Y: 2, Z: 3}, FloatVector3{X: -1, Y: 0, Z: 5}, FloatVector3{Y: 4})
+	if box.Min != (FloatVector3{X: -1, Y: 0, Z: 0}) || box.Max != (FloatVector3{X: 1, Y: 4, Z: 5}) {
+		t.Errorf("got box %v", box)
+	}
+	if area := box.SurfaceArea(); area != 2*(2*4+4*5+5*2) {
+		t.Errorf("got surface area %v", area)
+	}
+
+	other := AABB{Min: FloatVector3{X: 1, Y: 4, Z: 5}, Max: FloatVector3{X: 2, Y: 5, Z: 6}}
+	tests := []struct {
+		name       string
+		a, b       AABB
+		intersects bool
+	}{
+		{"same", box, box, true},
+		{"touching", box, other, true},
+		{"apart", box, AABB{Min: FloatVector3{X: 1.5, Y: 4, Z: 5}, Max: FloatVector3{X: 2, Y: 5, Z: 6}}, false},
+		{"inside", box, AABB{Min: FloatVector3{Y: 1, Z: 1}, Max: FloatVector3{Y: 1, Z: 1}}, true},
+	}
+	for _, test := range tests {
+		if got := test.a.Intersects(test.b); got != test.intersects {
+			t.Errorf("%s: Intersects is %v", test.name, got)
+		}
+		if got := test.b.Intersects(test.a); got != test.intersects {
+			t.Errorf("%s: reversed Intersects is %v", test.name, got)
+		}
+	}
+
+	union := box.Union(other)
+	if union.Min != box.Min || union.Max != other.Max {
+		t.Errorf("got union %v", union)
+	}
+}
@@ -0,0 +1,247 @@
+package gome
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// A SystemProfile holds the measurements of one system during one frame.
+type SystemProfile struct {
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+
+	// Entities is the number of entities in the system, or -1 if the
+	// system doesn't tell.
+	Entities int
+
+	// GPUTime is the time the GPU spent on the commands of the system. GPU timings
+	// arrive a few frames late, so they get added to the frame they were
+	// reported in.
+	GPUTime time.Duration
+}
+
+// A FrameProfile holds the measurements of one frame.
+type FrameProfile struct {
+	Index     uint64
+	Start     time.Time
+	Duration  time.Duration
+	DrawCalls int
+	Systems   []SystemProfile
+}
+
+// An EntityCounter is a system that can tell how many entities it holds.
+// The base systems implement it.
+type EntityCounter interface {
+	EntityCount() int
+}
+
+// A Profiler records how long the systems of a scene take each frame.
+// Set Scene.Profiler to start recording.
+type Profiler struct {
+	// Budget is the time a frame may take. Frames taking longer get logged
+	// as a warning, together with the slowest system. 0 disables the warning.
+	Budget time.Duration
+
+	frames  []FrameProfile
+	next    int
+	count   uint64
+	current *FrameProfile
+}
+
+// NewProfiler returns a profiler keeping the last n frames.
+func NewProfiler(n int) *Profiler {
+	if n < 1 {
+		n = 1
+	}
+	return &Profiler{frames: make([]FrameProfile, 0, n)}
+}
+
+// beginFrame starts recording a new frame.
+func (p *Profiler) beginFrame() {
+	p.count++
+	p.current = &FrameProfile{
+		Index: p.count,
+		Start: time.Now(),
+	}
+}
+
+// beginSystem starts measuring a system.
+func (p *Profiler) beginSystem(system System) {
+	entities := -1
+	if counter, ok := system.(EntityCounter); ok {
+		entities = counter.EntityCount()
+	}
+
+	p.current.Systems = append(p.current.Systems, SystemProfile{
+		Name:     system.Name(),
+		Entities: entities,
+		Start:    time.Now(),
+	})
+}
+
+// endSystem stops measuring the current system.
+func (p *Profiler) endSystem() {
+	last := &p.current.Systems[len(p.current.Systems)-1]
+	last.Duration = time.Since(last.Start)
+}
+
+// endFrame stops recording the current frame and stores it.
+func (p *Profiler) endFrame() {
+	frame := *p.current
+	frame.Duration = time.Since(frame.Start)
+	p.current = nil
+
+	if cap(p.frames) == 0 {
+		p.frames = make([]FrameProfile, 0, 1)
+	}
+
+	// overwrite the oldest frame once the buffer is full
+	if len(p.frames) < cap(p.frames) {
+		p.frames = append(p.frames, frame)
+	} else {
+		p.frames[p.next] = frame
+	}
+	p.next = (p.next + 1) % cap(p.frames)
+
+	if p.Budget > 0 && frame.Duration > p.Budget {
+		slowest := SystemProfile{}
+		for _, system := range frame.Systems {
+			if system.Duration > slowest.Duration {
+				slowest = system
+			}
+		}
+
+		Log.Warn(CategoryScene, "frame over budget", "frame", frame.Index, "duration", frame.Duration,
+			"slowest", slowest.Name, "system duration", slowest.Duration)
+	}
+}
+
+// AddDrawCalls adds to the draw call count of the current frame.
+func (p *Profiler) AddDrawCalls(n int) {
+	if p.current != nil {
+		p.current.DrawCalls += n
+	}
+}
+
+// RecordGPUTime records the GPU time of a system in the current frame.
+func (p *Profiler) RecordGPUTime(system string, duration time.Duration) {
+	if p.current == nil {
+		return
+	}
+
+	for i := range p.current.Systems {
+		if p.current.Systems[i].Name == system {
+			p.current.Systems[i].GPUTime = duration
+			return
+		}
+	}
+}
+
+// Frames returns the recorded frames, oldest first.
+func (p *Profiler) Frames() []FrameProfile {
+	frames := make([]FrameProfile, 0, len(p.frames))
+	if len(p.frames) < cap(p.frames) {
+		return append(frames, p.frames...)
+	}
+
+	frames = append(frames, p.frames[p.next:]...)
+	return append(frames, p.frames[:p.next]...)
+}
+
+// LastFrame returns the last recorded frame. If no frame was recorded yet,
+// ok is false.
+func (p *Profiler) LastFrame() (frame FrameProfile, ok bool) {
+	if len(p.frames) == 0 {
+		return frame, false
+	}
+
+	last := (p.next - 1 + cap(p.frames)) % cap(p.frames)
+	return p.frames[last], true
+}
+
+// SystemAverage returns the average CPU time of a system over all recorded frames.
+func (p *Profiler) SystemAverage(name string) time.Duration {
+	total := time.Duration(0)
+	n := 0
+	for _, frame := range p.frames {
+		for _, system := range frame.Systems {
+			if system.Name == name {
+				total += system.Duration
+				n++
+			}
+		}
+	}
+
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
+
+/*
+	Chrome Trace
+*/
+
+// a traceEvent is an event in the Chrome trace event format.
+type traceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat,omitempty"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur,omitempty"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the recorded frames in the Chrome trace event format.
+// The output can be opened with chrome://tracing or Perfetto.
+func (p *Profiler) WriteChromeTrace(w io.Writer) error {
+	events := []traceEvent{}
+	pid := os.Getpid()
+
+	for _, frame := range p.Frames() {
+		events = append(events, traceEvent{
+			Name:      "Frame",
+			Category:  "frame",
+			Phase:     "X",
+			Timestamp: frame.Start.UnixNano() / 1000,
+			Duration:  int64(frame.Duration / time.Microsecond),
+			PID:       pid,
+			TID:       1,
+			Args:      map[string]interface{}{"index": frame.Index},
+		}, traceEvent{
+			Name:      "DrawCalls",
+			Phase:     "C",
+			Timestamp: frame.Start.UnixNano() / 1000,
+			PID:       pid,
+			Args:      map[string]interface{}{"count": frame.DrawCalls},
+		})
+
+		for _, system := range frame.Systems {
+			args := map[string]interface{}{"gpu_us": int64(system.GPUTime / time.Microsecond)}
+			if system.Entities >= 0 {
+				args["entities"] = system.Entities
+			}
+
+			events = append(events, traceEvent{
+				Name:      system.Name,
+				Category:  "system",
+				Phase:     "X",
+				Timestamp: system.Start.UnixNano() / 1000,
+				Duration:  int64(system.Duration / time.Microsecond),
+				PID:       pid,
+				TID:       1,
+				Args:      args,
+			})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
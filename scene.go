@@ -1,6 +1,7 @@
 package gome
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/veandco/go-sdl2/sdl"
@@ -13,45 +14,102 @@ type Scene struct {
 	idCount       uint
 	isInitialized bool
 	glcontext     sdl.GLContext
+
+	// initialized are the systems whose Init succeeded, in the order they
+	// were initialized
+	initialized []System
+
 	WindowArgs    WindowArguments
+
+	// Profiler records the frame timings of the scene if it's not nil.
+	Profiler *Profiler
 }
 
 // Update gets called every frame.
 func (s *Scene) Update(delta time.Duration) {
+	if s.Profiler == nil {
+		for _, system := range s.systems {
+			system.Update(delta)
+		}
+		return
+	}
+
+	s.Profiler.beginFrame()
 	for _, system := range s.systems {
+		s.Profiler.beginSystem(system)
 		system.Update(delta)
+		s.Profiler.endSystem()
 	}
+	s.Profiler.endFrame()
 }
 
 // Init initializes the Scene, initializing the systems and adding
-// entities to them.
-func (s *Scene) Init(args WindowArguments) {
+// entities to them. If that fails, the systems that were already initialized
+// get shut down again and the scene stays uninitialized.
+func (s *Scene) Init(args WindowArguments) error {
 	// only initialize if it's not already initialized
 	if !s.isInitialized {
 		s.WindowArgs = args
+
+		if err := s.initSystems(); err != nil {
+			s.shutdownSystems()
+			return err
+		}
 		s.isInitialized = true
+	}
 
-		for _, system := range s.systems {
-			s.addSystemAfterInit(system)
+	for _, system := range s.systems {
+		system.Focus(s)
+	}
+
+	return nil
+}
+
+// initSystems initializes the systems and adds the entities to them.
+func (s *Scene) initSystems() error {
+	for _, system := range s.systems {
+		if err := s.addSystemAfterInit(system); err != nil {
+			return err
 		}
+	}
 
-		for _, entity := range s.entities {
-			s.addEntityAfterInit(entity)
+	for _, entity := range s.entities {
+		if err := s.addEntityAfterInit(entity); err != nil {
+			return err
 		}
 	}
 
-	for _, system := range s.systems {
-		system.Focus(s)
+	return nil
+}
+
+// Shutdown shuts down all initialized systems in the reverse order they were
+// initialized. The scene has to be initialized again before it can be used.
+func (s *Scene) Shutdown() {
+	if !s.isInitialized {
+		return
 	}
+
+	s.shutdownSystems()
+	s.isInitialized = false
+}
+
+// shutdownSystems shuts down the systems whose Init succeeded, in reverse order.
+func (s *Scene) shutdownSystems() {
+	for i := len(s.initialized) - 1; i >= 0; i-- {
+		s.initialized[i].Shutdown(s)
+	}
+	s.initialized = nil
 }
 
 // AddSystem adds a System to the Scene
-func (s *Scene) AddSystem(system System) {
+func (s *Scene) AddSystem(system System) error {
 	s.systems = append(s.systems, system)
 
 	if s.isInitialized {
-		s.addSystemAfterInit(system)
+		return s.addSystemAfterInit(system)
 	}
+
+	return nil
 }
 
 // HasSystem checks if there is a system of a specific type in this scene.
@@ -77,8 +135,11 @@ func (s *Scene) GetSystem(name string) System {
 	return nil
 }
 
-func (s *Scene) addSystemAfterInit(system System) {
-	system.Init(s)
+func (s *Scene) addSystemAfterInit(system System) error {
+	if err := system.Init(s); err != nil {
+		return fmt.Errorf("could not initialize system %s: %w", system.Name(), err)
+	}
+	s.initialized = append(s.initialized, system)
 
 	required := system.RequiredComponents()
 
@@ -94,16 +155,24 @@ func (s *Scene) addSystemAfterInit(system System) {
 
 		// if all the dependencies are satisfied, add the entity to the system
 		if len(required) == len(supply) {
-			system.Add(uint(entity.GetID()), supply)
+			if err := system.Add(entity.GetID(), supply); err != nil {
+				return addError(entity.GetID(), system, err)
+			}
 		}
 	}
+
+	return nil
 }
 
 // AddSystems adds multiple Systems to the Scene
-func (s *Scene) AddSystems(systems ...System) {
+func (s *Scene) AddSystems(systems ...System) error {
 	for _, system := range systems {
-		s.AddSystem(system)
+		if err := s.AddSystem(system); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 // An entityID uniquely identifies an Entity
@@ -116,17 +185,21 @@ func (s *Scene) newEntityID() uint {
 	return s.idCount
 }
 
-// AddEntity adds an Entity to the Scene
-func (s *Scene) AddEntity(entity Entity) {
+// AddEntity adds an Entity to the Scene. If the scene is already initialized
+// and a system fails to load the entity (e.g. because of a missing asset), the
+// error gets returned.
+func (s *Scene) AddEntity(entity Entity) error {
 	entity.setID(s.newEntityID())
 	s.entities = append(s.entities, entity)
 
 	if s.isInitialized {
-		s.addEntityAfterInit(entity)
+		return s.addEntityAfterInit(entity)
 	}
+
+	return nil
 }
 
-func (s *Scene) addEntityAfterInit(entity Entity) {
+func (s *Scene) addEntityAfterInit(entity Entity) error {
 	components := entity.GetComponents()
 	for _, system := range s.systems {
 		// don't add to systems the entity is already in
@@ -145,16 +218,24 @@ func (s *Scene) addEntityAfterInit(entity Entity) {
 
 		// if all the dependencies are satisfied, add the entity to the system
 		if len(required) == len(supply) {
-			system.Add(uint(entity.GetID()), supply)
+			if err := system.Add(entity.GetID(), supply); err != nil {
+				return addError(entity.GetID(), system, err)
+			}
 		}
 	}
+
+	return nil
 }
 
 // AddEntities adds multiple Entities to the Scene.
-func (s *Scene) AddEntities(entities ...Entity) {
+func (s *Scene) AddEntities(entities ...Entity) error {
 	for _, entity := range entities {
-		s.AddEntity(entity)
+		if err := s.AddEntity(entity); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 // RemoveEntity removes the Entity from all current systems and the entity list
@@ -178,7 +259,7 @@ func (s *Scene) RemoveEntity(id uint) {
 }
 
 // AddComponent adds a component to an existing entity.
-func (s *Scene) AddComponent(id uint, component Component) {
+func (s *Scene) AddComponent(id uint, component Component) error {
 	// add the component
 	var components map[string]Component
 	for i, entity := range s.entities {
@@ -207,9 +288,18 @@ func (s *Scene) AddComponent(id uint, component Component) {
 
 		// if all the dependencies are satisfied, add the entity to the system
 		if len(required) == len(supply) {
-			system.Add(id, supply)
+			if err := system.Add(id, supply); err != nil {
+				return addError(id, system, err)
+			}
 		}
 	}
+
+	return nil
+}
+
+// addError wraps an error returned by a system when adding an entity.
+func addError(id uint, system System, err error) error {
+	return fmt.Errorf("could not add entity %d to system %s: %w", id, system.Name(), err)
 }
 
 // RemoveComponent removes a Component from an existing Entity.
@@ -0,0 +1,72 @@
+package gome
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testSystem records its Init and Shutdown calls, optionally failing Init.
+type testSystem struct {
+	MultiSystem
+	name string
+	fail bool
+	log  *[]string
+}
+
+func (ts *testSystem) Init(scene *Scene) error {
+	*ts.log = append(*ts.log, "init "+ts.name)
+	if ts.fail {
+		return errors.New("failed")
+	}
+	return ts.MultiSystem.Init(scene)
+}
+
+func (ts *testSystem) Shutdown(scene *Scene) {
+	*ts.log = append(*ts.log, "shutdown "+ts.name)
+}
+
+func (ts *testSystem) Name() string { return ts.name }
+
+func TestSceneInitFailure(t *testing.T) {
+	log := []string{}
+	scene := &Scene{}
+	scene.AddSystems(
+		&testSystem{name: "a", log: &log},
+		&testSystem{name: "b", log: &log},
+		&testSystem{name: "c", fail: true, log: &log},
+		&testSystem{name: "d", log: &log},
+	)
+
+	if err := scene.Init(WindowArguments{}); err == nil {
+		t.Fatal("got no error from a failing system")
+	}
+	want := []string{"init a", "init b", "init c", "shutdown b", "shutdown a"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("got calls %v, want %v", log, want)
+	}
+
+	// the scene is not initialized, so shutting it down does nothing
+	log = log[:0]
+	scene.Shutdown()
+	if len(log) != 0 {
+		t.Errorf("got calls %v after Shutdown, want none", log)
+	}
+}
+
+func TestSceneShutdown(t *testing.T) {
+	log := []string{}
+	scene := &Scene{}
+	scene.AddSystems(&testSystem{name: "a", log: &log}, &testSystem{name: "b", log: &log})
+
+	if err := scene.Init(WindowArguments{}); err != nil {
+		t.Fatal(err)
+	}
+	scene.Shutdown()
+	scene.Shutdown()
+
+	want := []string{"init a", "init b", "shutdown b", "shutdown a"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("got calls %v, want %v", log, want)
+	}
+}
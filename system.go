@@ -19,8 +19,8 @@ type System interface {
 	// Add adds an entity to the system  or overwrites an existing one using
 	// its ID and the components required by the system in the same order as
 	// RequiredComponents demands. It should only be called when adding an Enttiy
-	// to the scene.
-	Add(id uint, components []Component)
+	// to the scene. If the entity could not be added, an error is returned.
+	Add(id uint, components []Component) error
 
 	// remove removes a single entity added to the system and its components.
 	// remove should only be called when removing an Entity from the scene.
@@ -30,7 +30,7 @@ type System interface {
 	Has(id uint) bool
 
 	// Init initializes the system.
-	Init(scene *Scene)
+	Init(scene *Scene) error
 
 	// Focus gets called when the scene gets shown.
 	Focus(scene *Scene)
@@ -39,6 +39,10 @@ type System interface {
 	// in miliseconds.
 	Update(delta time.Duration)
 
+	// Shutdown gets called once when the window closes. The system should
+	// release all resources it holds, e.g. GPU memory.
+	Shutdown(scene *Scene)
+
 	// Name returns the name of the system. (E.g. "Render")
 	Name() string
 }
@@ -66,8 +70,9 @@ func (ms *MultiSystem) GetComponent(id uint, name string) Component {
 // RequiredComponents should be overwritten.
 func (*MultiSystem) RequiredComponents() []string { return []string{} }
 
-func (ms *MultiSystem) Add(id uint, components []Component) {
+func (ms *MultiSystem) Add(id uint, components []Component) error {
 	ms.Entities[id] = components
+	return nil
 }
 
 func (ms *MultiSystem) Remove(id uint) {
@@ -79,14 +84,20 @@ func (ms *MultiSystem) Has(id uint) bool {
 	return exists
 }
 
-func (ms *MultiSystem) Init(scene *Scene) {
+func (ms *MultiSystem) Init(scene *Scene) error {
 	ms.Entities = make(map[uint][]Component)
+	return nil
 }
 
+// EntityCount returns the number of entities in the system.
+func (ms *MultiSystem) EntityCount() int { return len(ms.Entities) }
+
 func (ms *MultiSystem) Focus(scene *Scene) {}
 
 func (ms *MultiSystem) Update(delta time.Duration) {}
 
+func (ms *MultiSystem) Shutdown(scene *Scene) {}
+
 // A SingleSystem is a base system that can only hold one entity.
 // Use case would be a scrolling background for example.
 type SingleSystem struct {
@@ -106,10 +117,11 @@ func (ss *SingleSystem) GetComponent(id uint, name string) Component {
 	return nil
 }
 
-func (ss *SingleSystem) Add(id uint, components []Component) {
+func (ss *SingleSystem) Add(id uint, components []Component) error {
 	ss.ID = id
 	ss.Components = components
 	ss.Active = true
+	return nil
 }
 
 func (ss *SingleSystem) Remove(id uint) {
@@ -120,10 +132,21 @@ func (ss *SingleSystem) Has(id uint) bool {
 	return ss.ID == id
 }
 
-func (ss *SingleSystem) Init(scene *Scene) {
+func (ss *SingleSystem) Init(scene *Scene) error {
 	ss.Active = false
+	return nil
+}
+
+// EntityCount returns 1 if the system holds an entity, else 0.
+func (ss *SingleSystem) EntityCount() int {
+	if ss.Active {
+		return 1
+	}
+	return 0
 }
 
 func (ss *SingleSystem) Focus(scene *Scene) {}
 
 func (ss *SingleSystem) Update(delta time.Duration) {}
+
+func (ss *SingleSystem) Shutdown(scene *Scene) {}
@@ -79,12 +79,16 @@ func main() {
 	}
 
 	// ... and initialize it
-	win.Init()
+	if err := win.Init(); err != nil {
+		panic(err)
+	}
 
 	// make a new Scene
 	scene := &gome.Scene{}
 	// add it to the window
-	win.AddScene(scene)
+	if err := win.AddScene(scene); err != nil {
+		panic(err)
+	}
 
 	// make a new entity
 	entity := &CubeEntity{
@@ -112,5 +116,7 @@ func main() {
 	)
 
 	// start the window
-	win.Spawn()
+	if err := win.Spawn(); err != nil {
+		panic(err)
+	}
 }
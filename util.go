@@ -10,7 +10,7 @@ import (
 
 // Throw outputs an error to console and stops execution
 // it should only be used in top-level functions, as returning the
-// error is required for unit testing
+// error is required for unit testing. The engine itself never calls Throw.
 func Throw(err error, msg string) {
 	if err != nil {
 		debug.PrintStack()
@@ -20,6 +20,30 @@ func Throw(err error, msg string) {
 	}
 }
 
+/*
+	Errors
+*/
+
+// An ErrorHandler gets called with errors the engine could recover from, e.g. a
+// missing asset. If it returns nil, the error counts as handled and the engine
+// goes on (e.g. by using a placeholder), else the returned error gets passed on
+// to the caller.
+type ErrorHandler func(err error) error
+
+// HandleError is the ErrorHandler used by the engine. By default, every error gets
+// passed on. Replace it to change how recoverable errors are treated.
+var HandleError ErrorHandler = func(err error) error { return err }
+
+// An AssetError is returned when an asset file could not be loaded.
+type AssetError struct {
+	Path string
+	Err  error
+}
+
+func (ae *AssetError) Error() string { return "could not load asset " + ae.Path + ": " + ae.Err.Error() }
+
+func (ae *AssetError) Unwrap() error { return ae.Err }
+
 /*
 	MailBox
 */
@@ -69,6 +93,8 @@ type KeyboardMessage struct {
 func (KeyboardMessage) Name() string { return "Keyboard" }
 
 // A MouseButtonMessage is sent when a mouse button gets pressed or released.
+// X and Y are the position of the cursor from -1 to 1, from the bottom left to
+// the top right corner of the window.
 type MouseButtonMessage struct {
 	Button    uint8
 	State     uint8
@@ -78,7 +104,9 @@ type MouseButtonMessage struct {
 
 func (MouseButtonMessage) Name() string { return "MouseButton" }
 
-// A MouseMotionMessage is sent when the mouse gets moved.
+// A MouseMotionMessage is sent when the mouse gets moved. X and Y are the
+// position of the cursor like in a MouseButtonMessage, XRel and YRel how far
+// it moved in the same units.
 type MouseMotionMessage struct {
 	X, Y, XRel, YRel float32
 	Timestamp        uint32
@@ -102,6 +130,29 @@ type ChangeSceneMessage struct {
 
 func (ChangeSceneMessage) Name() string { return "ChangeScene" }
 
+// A QuitMessage is sent when the window is about to close, either because the
+// user closed it or because Window.Close was called. Listeners get called before
+// any scene is shut down, so it's the place to save progress.
+type QuitMessage struct{}
+
+func (QuitMessage) Name() string { return "Quit" }
+
+// A LoadProgressMessage is sent every time an asset loaded in the background
+// is ready or failed to load. It can be used to show a loading screen.
+type LoadProgressMessage struct {
+	Path string
+
+	// Err is set if the asset failed to load.
+	Err error
+
+	// Loaded is the number of assets finished since the last time all
+	// assets were loaded, Total the number of assets started since then.
+	Loaded int
+	Total  int
+}
+
+func (LoadProgressMessage) Name() string { return "LoadProgress" }
+
 /*
 	File Reader
 */
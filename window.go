@@ -2,6 +2,7 @@ package gome
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/veandco/go-sdl2/sdl"
@@ -13,6 +14,9 @@ type Window struct {
 	current     int
 	window      *sdl.Window
 	stopCurrent bool
+
+	// closing is set by Close, which may run on the event goroutine
+	closing atomic.Bool
 }
 
 type WindowArguments struct {
@@ -21,29 +25,32 @@ type WindowArguments struct {
 	Width  int32
 	Height int32
 	Title  string
-	Debug  bool
+
+	// Debug enables OpenGL debug output and lowers the log level to LevelDebug.
+	Debug bool
 }
 
-func (win *Window) AddScene(scene *Scene) {
+// AddScene adds a scene to the window, creating an OpenGL context for it.
+func (win *Window) AddScene(scene *Scene) error {
 	context, err := win.window.GLCreateContext()
 	if err != nil {
-		Throw(err, "Could not create OpenGL context")
+		return fmt.Errorf("could not create OpenGL context: %w", err)
 	}
 	scene.glcontext = context
 
 	win.scenes = append(win.scenes, scene)
+	return nil
 }
 
-func (win *Window) AddScenes(scenes ...*Scene) {
+// AddScenes adds multiple scenes to the window.
+func (win *Window) AddScenes(scenes ...*Scene) error {
 	for _, scene := range scenes {
-		context, err := win.window.GLCreateContext()
-		if err != nil {
-			Throw(err, "Could not create OpenGL contexts")
+		if err := win.AddScene(scene); err != nil {
+			return err
 		}
-		scene.glcontext = context
 	}
 
-	win.scenes = append(win.scenes, scenes...)
+	return nil
 }
 
 func (win *Window) GetScene(scene int) *Scene {
@@ -54,36 +61,78 @@ func (win *Window) Current() int {
 	return win.current
 }
 
-func (win *Window) Init() {
+func (win *Window) Init() error {
+	if win.Args.Debug {
+		Log.SetLevel(LevelDebug)
+	}
+
 	// init sdl (we use sdl for the window since it has more options than glfw)
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("could not initialize SDL: %w", err)
 	}
 
 	// create a new window with sdl
 	win.window, err = sdl.CreateWindow(win.Args.Title, win.Args.X, win.Args.Y,
-		win.Args.Height, win.Args.Height, sdl.WINDOW_OPENGL)
+		win.Args.Width, win.Args.Height, sdl.WINDOW_OPENGL)
 	if err != nil {
-		panic(err)
+		sdl.Quit()
+		return fmt.Errorf("could not create window: %w", err)
 	}
 
 	MailBox.open()
 
 	win.scenes = make([]*Scene, 0)
+	return nil
 }
 
-// Spawn spawns the window and makes it visible
-func (win *Window) Spawn() {
+// Spawn spawns the window and makes it visible. It returns after the window
+// got closed and all scenes were shut down.
+func (win *Window) Spawn() error {
 	defer sdl.Quit()
 	defer win.window.Destroy()
 
 	// run current scene
 	// when the current scene switches, the method will terminate
 	// and it will loop to the next current scene.
-	for {
-		win.runCurrentScene()
+	for !win.closing.Load() {
+		if err := win.runCurrentScene(); err != nil {
+			win.shutdown()
+			return err
+		}
+	}
+
+	return win.shutdown()
+}
+
+// Close requests the window to close. A QuitMessage gets sent first so systems
+// can save their state, then the current frame is finished and all scenes get
+// shut down before Spawn returns. It can be called from any goroutine, e.g.
+// from MailBox listeners.
+func (win *Window) Close() {
+	if win.closing.Swap(true) {
+		return
+	}
+
+	MailBox.Send(QuitMessage{})
+}
+
+// shutdown shuts down all initialized scenes and deletes their OpenGL contexts.
+func (win *Window) shutdown() (err error) {
+	for _, scene := range win.scenes {
+		if scene.isInitialized {
+			// the scenes resources belong to its own context
+			if cerr := win.window.GLMakeCurrent(scene.glcontext); cerr != nil && err == nil {
+				err = cerr
+			}
+			scene.Shutdown()
+		}
+
+		sdl.GLDeleteContext(scene.glcontext)
 	}
+
+	win.scenes = nil
+	return
 }
 
 // handleEvents handles all the SDL events
@@ -91,10 +140,12 @@ func (win *Window) handleEvents(quit chan bool) {
 	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 		switch event.(type) {
 		case *sdl.QuitEvent:
+			Log.Debug(CategoryInput, "quit requested")
 			quit <- true
 			return
 		case *sdl.KeyboardEvent:
 			kEvent := event.(*sdl.KeyboardEvent)
+			Log.Debug(CategoryInput, "key", "sym", kEvent.Keysym.Sym, "state", kEvent.State)
 			MailBox.Send(KeyboardMessage{
 				Key:       kEvent.Keysym,
 				State:     kEvent.State,
@@ -102,20 +153,21 @@ func (win *Window) handleEvents(quit chan bool) {
 			})
 		case *sdl.MouseButtonEvent:
 			mEvent := event.(*sdl.MouseButtonEvent)
+			Log.Debug(CategoryInput, "mouse button", "button", mEvent.Button, "state", mEvent.State)
 			MailBox.Send(MouseButtonMessage{
 				Button:    mEvent.Button,
 				State:     mEvent.State,
-				X:         float32(mEvent.X)/float32(win.Args.Height)*2 - 1,
-				Y:         -float32(mEvent.Y)/float32(win.Args.Width)*2 - 1,
+				X:         float32(mEvent.X)/float32(win.Args.Width)*2 - 1,
+				Y:         1 - float32(mEvent.Y)/float32(win.Args.Height)*2,
 				Timestamp: mEvent.GetTimestamp(),
 			})
 		case *sdl.MouseMotionEvent:
 			mEvent := event.(*sdl.MouseMotionEvent)
 			MailBox.Send(MouseMotionMessage{
-				X:         float32(mEvent.X)/float32(win.Args.Height)*2 - 1,
-				Y:         -float32(mEvent.Y)/float32(win.Args.Width)*2 - 1,
-				XRel:      float32(mEvent.XRel)/float32(win.Args.Height)*2 - 1,
-				YRel:      -float32(mEvent.YRel)/float32(win.Args.Width)*2 - 1,
+				X:         float32(mEvent.X)/float32(win.Args.Width)*2 - 1,
+				Y:         1 - float32(mEvent.Y)/float32(win.Args.Height)*2,
+				XRel:      float32(mEvent.XRel) / float32(win.Args.Width) * 2,
+				YRel:      -float32(mEvent.YRel) / float32(win.Args.Height) * 2,
 				Timestamp: mEvent.GetTimestamp(),
 			})
 		case *sdl.MouseWheelEvent:
@@ -133,10 +185,8 @@ func (win *Window) handleEvents(quit chan bool) {
 }
 
 // runScene initializes and then updates the scene for as long as it's running
-func (win *Window) runCurrentScene() {
-	if win.Args.Debug {
-		fmt.Println("Displaying Scene:", win.current)
-	}
+func (win *Window) runCurrentScene() error {
+	Log.Debug(CategoryScene, "displaying scene", "scene", win.current)
 
 	// listen for change scene message
 	MailBox.Listen("ChangeScene", func(msg Message) {
@@ -159,20 +209,20 @@ func (win *Window) runCurrentScene() {
 	// set OpenGL context
 	err := win.window.GLMakeCurrent(win.scenes[win.current].glcontext)
 	if err != nil {
-		Throw(err, "Could not set OpenGL context")
+		return fmt.Errorf("could not set OpenGL context: %w", err)
 	}
 
 	// initialize all systems
-	win.scenes[win.current].Init(win.Args)
+	if err := win.scenes[win.current].Init(win.Args); err != nil {
+		return err
+	}
 
 	// marks when the last frame occured
 	last := time.Now()
 
-	running := true
-
 	eventQuit := make(chan bool, 1)
 
-	for running && !win.stopCurrent {
+	for !win.closing.Load() && !win.stopCurrent {
 		// handle events; quit if requested
 		go win.handleEvents(eventQuit)
 
@@ -187,9 +237,13 @@ func (win *Window) runCurrentScene() {
 		win.window.GLSwap()
 
 		// wait for event handling to finish
-		<-eventQuit
+		if <-eventQuit {
+			win.Close()
+		}
 	}
 
 	// reset
 	win.stopCurrent = false
+
+	return nil
 }
@@ -0,0 +1,31 @@
+package gome
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWindowCloseConcurrent(t *testing.T) {
+	MailBox.open()
+	var quits atomic.Int32
+	MailBox.Listen(QuitMessage{}.Name(), func(Message) { quits.Add(1) })
+
+	win := &Window{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			win.Close()
+		}()
+	}
+	wg.Wait()
+
+	if !win.closing.Load() {
+		t.Error("window is not closing")
+	}
+	if got := quits.Load(); got != 1 {
+		t.Errorf("got %d QuitMessages, want 1", got)
+	}
+}